@@ -0,0 +1,69 @@
+package logrustash
+
+import "net"
+
+// target returns the protocol and the address of logstash, see SetAddress.
+func (h *Hook) target() (protocol, address string) {
+	h.addressLocker.RLock()
+	defer h.addressLocker.RUnlock()
+	return h.protocol, h.address
+}
+
+// SetAddress makes the hook send the messages to logstash listening on `protocol`://`address`,
+// e.g. when the address from service discovery changes. The current connection is closed
+// and the next message dials the new address; the messages waiting in the buffer of the async mode
+// or in the write-ahead log are sent there. SetAddress may be called from any goroutine and does
+// nothing if the address is unchanged. It doesn't affect hooks created with a transport.
+func (h *Hook) SetAddress(protocol, address string) {
+	if h.parent != nil {
+		// A child hook sends its messages through its parent.
+		h.parent.SetAddress(protocol, address)
+		return
+	}
+	if h.transport != nil {
+		return
+	}
+
+	h.addressLocker.Lock()
+	defer h.addressLocker.Unlock()
+	if h.protocol == protocol && h.address == address {
+		return
+	}
+	h.protocol, h.address = protocol, address
+
+	// Waits for the message being written to the current connection.
+	h.Lock()
+	conn := h.conn
+	h.conn = nil
+	h.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+	if isHTTP(protocol) {
+		// The requests don't need a connection of the hook.
+		h.setState(StateConnected)
+	} else {
+		h.setState(StateDisconnected)
+	}
+}
+
+// setConnTo replaces the connection to logstash with conn dialed to `protocol`://`address`,
+// unless SetAddress has changed the address or the hook has been closed in the meantime.
+// It returns false in that case.
+func (h *Hook) setConnTo(conn net.Conn, protocol, address string) bool {
+	h.addressLocker.RLock()
+	defer h.addressLocker.RUnlock()
+	if h.protocol != protocol || h.address != address {
+		return false
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	// Close sets the state before closing the connection, so the connection
+	// is either closed by Close or isn't set at all.
+	if h.ConnectionState() == StateClosed {
+		return false
+	}
+	h.conn = conn
+	return true
+}
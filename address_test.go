@@ -0,0 +1,79 @@
+package logrustash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestSetAddress(t *testing.T) {
+	first := logrustashtest.NewServer(t, "tcp")
+	second := logrustashtest.NewServer(t, "tcp")
+	hook, err := NewAsyncHook("tcp", first.Addr(), "address_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	fireMessages(t, hook, "one", "two")
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entries queued before the switch are sent to the new address.
+	hook.Pause()
+	fireMessages(t, hook, "three")
+	hook.SetAddress("tcp", second.Addr())
+	conn := hook.getConn()
+	hook.SetAddress("tcp", second.Addr())
+	if hook.getConn() != conn {
+		t.Error("expected SetAddress with the same address to keep the connection")
+	}
+	hook.Resume()
+	fireMessages(t, hook, "four")
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	checkEventMessages(t, first, "one", "two")
+	checkEventMessages(t, second, "three", "four")
+	if events := first.Events(); len(events) != 2 {
+		t.Errorf("expected no events sent to the old address after the switch but got %v", events)
+	}
+}
+
+func TestSetAddressConcurrent(t *testing.T) {
+	servers := []*logrustashtest.Server{logrustashtest.NewServer(t, "tcp"), logrustashtest.NewServer(t, "tcp")}
+	hook, err := NewAsyncHook("tcp", servers[0].Addr(), "address_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			hook.SetAddress("tcp", servers[i%2].Addr())
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	var messages []string
+	for i := 0; i < 200; i++ {
+		messages = append(messages, "message")
+	}
+	fireMessages(t, hook, messages...)
+	<-done
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(servers[0].Events())+len(servers[1].Events()) < len(messages) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(servers[0].Events()) + len(servers[1].Events()); n != len(messages) || hook.DroppedCount() != 0 {
+		t.Errorf("expected %d events but got %d with %d dropped", len(messages), n, hook.DroppedCount())
+	}
+}
@@ -0,0 +1,289 @@
+package logrustash
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FireBatchMode tells what FireBatch of an async hook does when the buffer can't take the whole batch.
+type FireBatchMode int
+
+const (
+	BatchAllOrNothing FireBatchMode = iota // drop the whole batch and return ErrBufferFull (the default)
+	BatchBestEffort                        // put the entries which fit, the rest are handled as if fired by Fire
+)
+
+// WithFireBatchMode sets what FireBatch of an async hook does when the buffer can't take the whole batch.
+func WithFireBatchMode(mode FireBatchMode) Option {
+	return func(h *Hook) {
+		h.fireBatchMode = mode
+	}
+}
+
+// batchMarker marks the entries put into the buffer by the same FireBatch call,
+// so the sender sends them together.
+type batchMarker struct{}
+
+// batchMarkerKey is the key of the context of an entry with its *batchMarker.
+type batchMarkerKey struct{}
+
+// batchKey is the key of the context of an entry which stands for the batch of entries
+// sent with a single write, so the drops are counted for each of them.
+type batchKey struct{}
+
+// FireBatch fires entries, e.g. the entries accumulated while processing a request, in one call.
+// The entries are sent in order and, over a stream connection (e.g. tcp or WithWriter), with a single write;
+// the protocols which send each message separately (udp, http, a Transport, etc.) and the write-ahead log
+// get them one by one. In async mode the buffer is reserved for the whole batch at once: if it can't
+// take all the entries (and the buffer full strategy isn't Block or DropOldest, which makes room),
+// the whole batch is dropped and ErrBufferFull is returned, unless WithFireBatchMode(BatchBestEffort)
+// is used. An unbuffered async hook hands the entries over one by one.
+// With the Block strategy, the entries fired by Fire and FireBatch in other goroutines wait
+// until the whole batch is in the buffer, so a large batch may block them for a while.
+// The entries are not modified, except that the fields with the hook only prefix are removed from them.
+func (h *Hook) FireBatch(entries []*logrus.Entry) error {
+	if h.parent != nil {
+		return h.fireChildBatch(entries)
+	}
+	if h.ConnectionState() == StateClosed {
+		return ErrHookClosed
+	}
+
+	batch := make([]*logrus.Entry, 0, len(entries))
+	var prepareErr error
+	for _, entry := range entries {
+		entry, err := h.prepareEntry(entry)
+		if err != nil && prepareErr == nil {
+			prepareErr = err
+		}
+		if entry != nil {
+			batch = append(batch, entry)
+		}
+	}
+	if len(batch) > 0 {
+		if err := h.enqueueBatch(batch); err != nil {
+			return err
+		}
+	}
+
+	return prepareErr
+}
+
+// fireChildBatch passes entries with the overlay of the child hook h to its parent.
+func (h *Hook) fireChildBatch(entries []*logrus.Entry) error {
+	if h.ConnectionState() == StateClosed {
+		return ErrHookClosed
+	}
+
+	overlaid := make([]*logrus.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if !h.levelEnabled(entry.Level) {
+			atomic.AddUint64(&h.filteredCount, 1)
+			continue
+		}
+		overlaid = append(overlaid, h.overlay(entry))
+	}
+	err := h.parent.FireBatch(overlaid)
+	for _, entry := range entries {
+		h.parent.filterHookOnly(entry)
+	}
+	return err
+}
+
+// enqueueBatch sends entries, which aren't shared with anyone else, or puts them
+// into the buffer of the async mode or the write-ahead log.
+func (h *Hook) enqueueBatch(entries []*logrus.Entry) error {
+	if h.wal != nil {
+		var firstErr error
+		for _, entry := range entries {
+			if err := h.appendWAL(entry); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	fireChannel, closeChan := h.channels()
+	if fireChannel == nil {
+		if h.Paused() {
+			for _, entry := range entries {
+				h.drop(entry, DropReasonPaused)
+				h.fallbackEntry(entry)
+			}
+			return ErrHookPaused
+		}
+		for range entries {
+			h.inFlight.add()
+		}
+		defer func() {
+			for range entries {
+				h.inFlight.done()
+			}
+		}()
+		return h.sendBatch(entries)
+	}
+
+	// No other entry may get into the buffer between the entries of the batch.
+	// With the Block strategy the lock is held while fireBufferFull waits for the sender,
+	// which doesn't take the lock, to make room for the rest of the batch.
+	h.enqueueLocker.Lock()
+	defer h.enqueueLocker.Unlock()
+
+	if !h.batchFits(len(entries), fireChannel) {
+		for _, entry := range entries {
+			h.drop(entry, DropReasonChannelFull)
+			h.fallbackEntry(entry)
+		}
+		return ErrBufferFull
+	}
+	marker := &batchMarker{}
+	var firstErr error
+	for _, entry := range entries {
+		entry.Context = context.WithValue(entryContext(entry), batchMarkerKey{}, marker)
+		h.inFlight.add()
+		select {
+		case fireChannel <- entry:
+			h.statsd.gauge("queue_depth", len(fireChannel))
+		default:
+			if err := h.fireBufferFull(entry, fireChannel, closeChan); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// batchFits reports whether n entries can be put into fireChannel according to the FireBatchMode.
+func (h *Hook) batchFits(n int, fireChannel chan *logrus.Entry) bool {
+	if h.fireBatchMode == BatchBestEffort || cap(fireChannel) == 0 {
+		return true
+	}
+	switch h.effectiveBufferFullStrategy() {
+	case Block:
+		return true
+	case DropOldest:
+		return n <= cap(fireChannel)
+	}
+	// Only the sender takes the entries from the buffer meanwhile, so the free space can only grow.
+	return n <= cap(fireChannel)-len(fireChannel)
+}
+
+// collectBatch returns entry and the entries of the same batch (see FireBatch) which follow it
+// in fireChannel. next is the entry taken from fireChannel which doesn't belong to the batch, if any.
+func collectBatch(entry *logrus.Entry, fireChannel <-chan *logrus.Entry) (entries []*logrus.Entry, next *logrus.Entry) {
+	entries = []*logrus.Entry{entry}
+	marker := entryBatchMarker(entry)
+	if marker == nil {
+		return entries, nil
+	}
+	for {
+		select {
+		case next := <-fireChannel:
+			if entryBatchMarker(next) != marker {
+				return entries, next
+			}
+			entries = append(entries, next)
+		default:
+			// The rest of the batch, if any, is still being put into the buffer.
+			return entries, nil
+		}
+	}
+}
+
+func entryBatchMarker(entry *logrus.Entry) *batchMarker {
+	if entry.Context == nil {
+		return nil
+	}
+	marker, _ := entry.Context.Value(batchMarkerKey{}).(*batchMarker)
+	return marker
+}
+
+// sendBatch sends entries with a single write if possible.
+func (h *Hook) sendBatch(entries []*logrus.Entry) error {
+	if len(entries) == 1 || h.hasGivenUp() {
+		return h.sendEach(entries)
+	}
+
+	transport, err := h.connectedSender()
+	if err != nil {
+		for _, entry := range entries {
+			h.fallbackEntry(entry)
+		}
+		return err
+	}
+	if transport == nil {
+		return nil
+	}
+	if !isStream(transport) {
+		// A datagram (or a request, a record, etc.) carries a single message.
+		return h.sendEach(entries)
+	}
+
+	var data []byte
+	var formatted []*logrus.Entry
+	var firstErr error
+	for _, entry := range entries {
+		err := h.processEntry(entry, func(entry *logrus.Entry) error {
+			dataBytes, err := h.formatMessage(entry)
+			if err != nil {
+				h.reportError(err, OperationFormat)
+				return err
+			}
+			data = append(data, dataBytes...)
+			formatted = append(formatted, entry)
+			return nil
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(formatted) > 0 {
+		if err := h.sendData(newBatchEntry(formatted), data); err != nil {
+			return err
+		}
+	}
+
+	return firstErr
+}
+
+// sendEach sends entries one by one and returns the first error.
+func (h *Hook) sendEach(entries []*logrus.Entry) error {
+	var firstErr error
+	for _, entry := range entries {
+		if err := h.sendMessage(entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// newBatchEntry returns an entry which stands for entries sent with a single write.
+func newBatchEntry(entries []*logrus.Entry) *logrus.Entry {
+	return &logrus.Entry{
+		Data:    logrus.Fields{},
+		Time:    entries[0].Time,
+		Level:   entries[0].Level,
+		Message: entries[0].Message,
+		Context: context.WithValue(context.Background(), batchKey{}, entries),
+	}
+}
+
+// batchEntries returns the entries of an entry created by newBatchEntry, or entry itself.
+func batchEntries(entry *logrus.Entry) []*logrus.Entry {
+	if entry != nil && entry.Context != nil {
+		if entries, ok := entry.Context.Value(batchKey{}).([]*logrus.Entry); ok {
+			return entries
+		}
+	}
+	return []*logrus.Entry{entry}
+}
+
+func entryContext(entry *logrus.Entry) context.Context {
+	if entry.Context == nil {
+		return context.Background()
+	}
+	return entry.Context
+}
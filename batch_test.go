@@ -0,0 +1,165 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+// writesConnMock records each write separately.
+type writesConnMock struct {
+	ConnMock
+	sync.Mutex
+	writes [][]byte
+}
+
+func (c *writesConnMock) Write(b []byte) (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *writesConnMock) Writes() [][]byte {
+	c.Lock()
+	defer c.Unlock()
+	return append([][]byte(nil), c.writes...)
+}
+
+func batchEntriesOf(messages ...string) []*logrus.Entry {
+	entries := make([]*logrus.Entry, 0, len(messages))
+	for _, message := range messages {
+		entries = append(entries, &logrus.Entry{Message: message, Data: logrus.Fields{}})
+	}
+	return entries
+}
+
+func checkBatchWrite(t *testing.T, data []byte, messages ...string) {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for _, expected := range messages {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message '%s' but got '%s'", expected, res["message"])
+		}
+	}
+	if dec.More() {
+		t.Errorf("expected no more messages in %q", data)
+	}
+}
+
+func TestFireBatch(t *testing.T) {
+	conn := &writesConnMock{}
+	hook, err := NewHookWithConn(conn, "batch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.SetLevels(logrus.PanicLevel)
+
+	entries := batchEntriesOf("first", "second", "third")
+	entries = append(entries, &logrus.Entry{Message: "filtered", Level: logrus.DebugLevel, Data: logrus.Fields{}})
+	if err := hook.FireBatch(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := conn.Writes()
+	if len(writes) != 1 {
+		t.Fatalf("expected a single write but got %d", len(writes))
+	}
+	checkBatchWrite(t, writes[0], "first", "second", "third")
+}
+
+func TestFireBatchAsync(t *testing.T) {
+	conn := &writesConnMock{}
+	hook, err := NewHookWithConn(conn, "batch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.AsyncBufferSize = 8
+	hook.makeAsync()
+	defer hook.Close()
+
+	hook.Pause()
+	if err := hook.Fire(&logrus.Entry{Message: "before", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.FireBatch(batchEntriesOf("first", "second", "third")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "after", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	hook.Resume()
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch is written at once, in order with the other entries.
+	writes := conn.Writes()
+	if len(writes) != 3 {
+		t.Fatalf("expected 3 writes but got %d", len(writes))
+	}
+	checkBatchWrite(t, writes[0], "before")
+	checkBatchWrite(t, writes[1], "first", "second", "third")
+	checkBatchWrite(t, writes[2], "after")
+}
+
+func TestFireBatchPartialCapacity(t *testing.T) {
+	for _, test := range []struct {
+		mode     FireBatchMode
+		err      error
+		dropped  uint64
+		messages []string
+	}{
+		{BatchAllOrNothing, ErrBufferFull, 3, []string{"queued 0", "queued 1"}},
+		{BatchBestEffort, nil, 1, []string{"queued 0", "queued 1", "batch 0", "batch 1"}},
+	} {
+		transport := logrustashtest.NewTransport()
+		hook, err := NewHookWithTransport(transport, "batch_test", WithFireBatchMode(test.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook.AsyncBufferSize = 4
+		hook.makeAsync()
+
+		// The buffer fills up while the hook is paused.
+		hook.Pause()
+		for i := 0; i < 2; i++ {
+			if err := hook.Fire(&logrus.Entry{Message: fmt.Sprint("queued ", i), Data: logrus.Fields{}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := hook.FireBatch(batchEntriesOf("batch 0", "batch 1", "batch 2")); err != test.err {
+			t.Errorf("%d: expected %v but got %v", test.mode, test.err, err)
+		}
+		if n := atomic.LoadUint64(&hook.droppedByReason[DropReasonChannelFull]); n != test.dropped {
+			t.Errorf("%d: expected %d dropped entries but got %d", test.mode, test.dropped, n)
+		}
+
+		hook.Resume()
+		if err := hook.Flush(5 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+		events := transport.Events()
+		if len(events) != len(test.messages) {
+			t.Fatalf("%d: expected %d events but got %v", test.mode, len(test.messages), events)
+		}
+		for i, event := range events {
+			if event["message"] != test.messages[i] {
+				t.Errorf("%d: expected message '%s' but got '%v'", test.mode, test.messages[i], event["message"])
+			}
+		}
+		hook.Close()
+	}
+}
@@ -0,0 +1,114 @@
+package logrustash
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBufferFull is returned by Fire when the buffer of the async mode is full
+// and the ReturnError strategy is used, see WithBufferFullStrategy.
+var ErrBufferFull = errors.New("Buffer of the hook is full")
+
+// BufferFullStrategy tells what Fire of an async hook does when the buffer is full.
+type BufferFullStrategy int
+
+const (
+	DropNewest  BufferFullStrategy = iota // drop the fired entry (the default)
+	Block                                 // wait until the buffer frees
+	DropOldest                            // drop the oldest entry in the buffer to make room for the fired one
+	ReturnError                           // drop the fired entry and return ErrBufferFull
+)
+
+var bufferFullStrategyNames = map[BufferFullStrategy]string{
+	DropNewest:  "drop_newest",
+	Block:       "block",
+	DropOldest:  "drop_oldest",
+	ReturnError: "return_error",
+}
+
+func (s BufferFullStrategy) String() string {
+	if name, ok := bufferFullStrategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("BufferFullStrategy(%d)", int(s))
+}
+
+// parseBufferFullStrategy returns the strategy named name, see BufferFullStrategy.String.
+func parseBufferFullStrategy(name string) (BufferFullStrategy, error) {
+	for s, n := range bufferFullStrategyNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown buffer full strategy %q", name)
+}
+
+// WithBufferFullStrategy sets what Fire of an async hook does when the buffer is full.
+// The entries dropped by any strategy are counted with DropReasonChannelFull.
+func WithBufferFullStrategy(strategy BufferFullStrategy) Option {
+	return func(h *Hook) {
+		h.bufferFullStrategy = strategy
+	}
+}
+
+// WithDropOldestOnFull makes Fire of an async hook drop the oldest entry in the full buffer,
+// which may be stale by the time it would be sent, instead of the fired one.
+// It is a shorthand for WithBufferFullStrategy(DropOldest).
+func WithDropOldestOnFull() Option {
+	return WithBufferFullStrategy(DropOldest)
+}
+
+func (h *Hook) effectiveBufferFullStrategy() BufferFullStrategy {
+	if h.WaitUntilBufferFrees {
+		return Block
+	}
+	return h.bufferFullStrategy
+}
+
+// fireBufferFull handles entry fired when fireChannel is full, see WithBufferFullStrategy.
+// The entry has been counted as in flight.
+func (h *Hook) fireBufferFull(entry *logrus.Entry, fireChannel chan *logrus.Entry, closeChan chan struct{}) error {
+	strategy := h.effectiveBufferFullStrategy()
+	switch strategy {
+	case Block:
+		// Blocks the goroutine because buffer is full.
+		select {
+		case fireChannel <- entry:
+			h.statsd.gauge("queue_depth", len(fireChannel))
+			return nil
+		case <-closeChan:
+			h.inFlight.done()
+			return ErrHookClosed
+		}
+	case DropOldest:
+		for {
+			select {
+			case fireChannel <- entry:
+				h.statsd.gauge("queue_depth", len(fireChannel))
+				return nil
+			default:
+			}
+			select {
+			case oldest := <-fireChannel:
+				h.drop(oldest, DropReasonChannelFull)
+				h.fallbackEntry(oldest)
+				h.inFlight.done()
+				continue
+			default:
+			}
+			// The sender has taken all the entries, but the buffer is full again
+			// (or there is no buffer at all), so the fired entry is dropped.
+			break
+		}
+	}
+
+	h.inFlight.done()
+	h.drop(entry, DropReasonChannelFull)
+	h.fallbackEntry(entry)
+	if strategy == ReturnError {
+		return ErrBufferFull
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package logrustash
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestBufferFullStrategy(t *testing.T) {
+	tt := map[BufferFullStrategy]struct {
+		err  error
+		sent []string
+	}{
+		DropNewest:  {nil, []string{"one", "two"}},
+		DropOldest:  {nil, []string{"two", "three"}},
+		ReturnError: {ErrBufferFull, []string{"one", "two"}},
+	}
+	for strategy, expected := range tt {
+		transport := logrustashtest.NewTransport()
+		hook, err := NewHookWithTransport(transport, "buffer_test", WithBufferFullStrategy(strategy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook.AsyncBufferSize = 2
+		hook.makeAsync()
+
+		// The buffer fills up while the hook is paused.
+		hook.Pause()
+		for i, message := range []string{"one", "two", "three"} {
+			err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}})
+			if i == 2 && err != expected.err {
+				t.Errorf("%s: expected '%v' for the entry which doesn't fit but got '%v'", strategy, expected.err, err)
+			}
+		}
+		if n := hook.droppedByReason[DropReasonChannelFull]; n != 1 {
+			t.Errorf("%s: expected 1 dropped entry but got %d", strategy, n)
+		}
+		hook.Resume()
+		if err := hook.Flush(5 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		var sent []string
+		for _, event := range transport.Events() {
+			sent = append(sent, fmt.Sprint(event["message"]))
+		}
+		if fmt.Sprint(sent) != fmt.Sprint(expected.sent) {
+			t.Errorf("%s: expected the messages %v but got %v", strategy, expected.sent, sent)
+		}
+		hook.Close()
+	}
+}
+
+func TestDropOldestOnFull(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewHookWithTransport(transport, "buffer_test", WithDropOldestOnFull())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.AsyncBufferSize = 3
+	hook.makeAsync()
+
+	hook.Pause()
+	var fired []string
+	for i := 0; i < 10; i++ {
+		fired = append(fired, fmt.Sprintf("message %d", i))
+	}
+	fireMessages(t, hook, fired...)
+	hook.Resume()
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The freshest entries are kept in order.
+	var sent []string
+	for _, event := range transport.Events() {
+		sent = append(sent, fmt.Sprint(event["message"]))
+	}
+	if fmt.Sprint(sent) != fmt.Sprint(fired[7:]) {
+		t.Errorf("expected the messages %v but got %v", fired[7:], sent)
+	}
+	if n := hook.droppedByReason[DropReasonChannelFull]; n != 7 {
+		t.Errorf("expected 7 dropped entries but got %d", n)
+	}
+}
+
+func TestBufferFullStrategyBlock(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewHookWithTransport(transport, "buffer_test", WithBufferFullStrategy(Block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.AsyncBufferSize = 1
+	hook.makeAsync()
+
+	hook.Pause()
+	fireMessages(t, hook, "one")
+	fired := make(chan error)
+	go func() {
+		fired <- hook.Fire(&logrus.Entry{Message: "two", Data: logrus.Fields{}})
+	}()
+	select {
+	case err := <-fired:
+		t.Fatalf("expected Fire to block while the buffer is full but got '%v'", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	hook.Resume()
+	if err := <-fired; err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if events := transport.Events(); len(events) != 2 || hook.DroppedCount() != 0 {
+		t.Errorf("expected 2 messages without drops but got %v with %d dropped", events, hook.DroppedCount())
+	}
+}
+
+func TestBufferFullStrategyString(t *testing.T) {
+	for _, strategy := range []BufferFullStrategy{DropNewest, Block, DropOldest, ReturnError} {
+		parsed, err := parseBufferFullStrategy(strategy.String())
+		if err != nil || parsed != strategy {
+			t.Errorf("expected '%s' to be parsed back but got %v, %v", strategy, parsed, err)
+		}
+	}
+	if s := BufferFullStrategy(42).String(); s != "BufferFullStrategy(42)" {
+		t.Errorf("expected 'BufferFullStrategy(42)' but got '%s'", s)
+	}
+}
@@ -0,0 +1,112 @@
+package logrustash
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// childAppNameKey is the key of the context value with the app name of the child hook
+// which has fired an entry.
+type childAppNameKey struct{}
+
+// ChildWithFields returns a child hook which sends its entries through the connection,
+// the buffer and the sender of h, but adds fields to them. The fields of the entries
+// win over fields, which win over the fields of h. WithField, WithFields and DeleteField
+// of the child change only the fields of the child.
+// Closing the child only detaches it (its Fire returns ErrHookClosed),
+// closing h closes all its children.
+func (h *Hook) ChildWithFields(fields logrus.Fields) *Hook {
+	child := h.newChild()
+	for k, v := range fields {
+		child.alwaysSentFields[k] = v
+	}
+	return child
+}
+
+// ChildWithAppName returns a child hook (see ChildWithFields) which sends name in the
+// "type" field instead of the appName of h, e.g. for a logical component of the process.
+// WithCustomAppName of h still applies to the entries of the child.
+func (h *Hook) ChildWithAppName(name string) *Hook {
+	child := h.newChild()
+	child.appName = name
+	return child
+}
+
+// Clone returns a child hook (see ChildWithFields) without fields of its own, e.g. for
+// request-scoped logging: WithField("request_id", id) on the clone doesn't affect h,
+// while the clone shares the connection and the buffer of h.
+func (h *Hook) Clone() *Hook {
+	return h.newChild()
+}
+
+func (h *Hook) newChild() *Hook {
+	root := h
+	if h.parent != nil {
+		root = h.parent
+	}
+
+	h.fieldsLocker.RLock()
+	defer h.fieldsLocker.RUnlock()
+	child := &Hook{
+		parent:           root,
+		alwaysSentFields: make(logrus.Fields, len(h.alwaysSentFields)),
+		closeChan:        make(chan struct{}),
+		levelMask:        atomic.LoadUint32(&h.levelMask),
+	}
+	if h.parent != nil {
+		// Children of children inherit the overlay of their parents.
+		child.appName = h.appName
+		for k, v := range h.alwaysSentFields {
+			child.alwaysSentFields[k] = v
+		}
+	}
+	return child
+}
+
+// fireChild passes entry with the overlay of the child hook h to its parent.
+func (h *Hook) fireChild(entry *logrus.Entry) error {
+	if h.ConnectionState() == StateClosed {
+		return ErrHookClosed
+	}
+	if !h.levelEnabled(entry.Level) {
+		h.parent.filterHookOnly(entry)
+		atomic.AddUint64(&h.filteredCount, 1)
+		return nil
+	}
+
+	err := h.parent.Fire(h.overlay(entry))
+	h.parent.filterHookOnly(entry)
+	return err
+}
+
+// overlay returns a copy of entry with the overlay of the child hook h.
+func (h *Hook) overlay(entry *logrus.Entry) *logrus.Entry {
+	overlaid := copyEntry(entry)
+	h.fieldsLocker.RLock()
+	for k, v := range h.alwaysSentFields {
+		if _, inMap := overlaid.Data[k]; !inMap {
+			overlaid.Data[k] = v
+		}
+	}
+	h.fieldsLocker.RUnlock()
+	if h.appName != "" {
+		ctx := overlaid.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		overlaid.Context = context.WithValue(ctx, childAppNameKey{}, h.appName)
+	}
+
+	return overlaid
+}
+
+// childAppName returns the app name of the child hook which has fired entry, if any.
+func childAppName(entry *logrus.Entry) string {
+	if entry.Context == nil {
+		return ""
+	}
+	name, _ := entry.Context.Value(childAppNameKey{}).(string)
+	return name
+}
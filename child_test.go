@@ -0,0 +1,108 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestChildHooks(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConnAndPrefix(conn, "parent", logrus.Fields{"host": "web-1", "component": "none"}, "_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billing := hook.ChildWithAppName("billing").ChildWithFields(logrus.Fields{"component": "billing"})
+	auth := hook.ChildWithAppName("auth")
+
+	entry := &logrus.Entry{Message: "charged", Data: logrus.Fields{"_secret": "x"}}
+	for _, fire := range []struct {
+		hook  *Hook
+		entry *logrus.Entry
+	}{
+		{billing, entry},
+		{auth, &logrus.Entry{Message: "logged in", Data: logrus.Fields{"component": "auth"}}},
+		{hook, &logrus.Entry{Message: "started", Data: logrus.Fields{}}},
+	} {
+		if err := fire.hook.Fire(fire.entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := entry.Data["_secret"]; ok {
+		t.Error("expected hook only fields to be removed from the entry of the child")
+	}
+	if err := billing.Flush(0); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []map[string]string{
+		{"message": "charged", "type": "billing", "component": "billing", "host": "web-1", "secret": "x"},
+		{"message": "logged in", "type": "auth", "component": "auth", "host": "web-1"},
+		{"message": "started", "type": "parent", "component": "none", "host": "web-1"},
+	} {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range expected {
+			if res[k] != v {
+				t.Errorf("expected %s to be '%s' but got '%v'", k, v, res[k])
+			}
+		}
+	}
+
+	// Closing a child only detaches it.
+	if err := auth.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != ErrHookClosed {
+		t.Errorf("expected ErrHookClosed from the closed child but got %v", err)
+	}
+	if err := billing.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != nil {
+		t.Errorf("expected the other child to work but got %v", err)
+	}
+	if hook.ConnectionState() != StateConnected {
+		t.Errorf("expected the parent to stay connected but got %s", hook.ConnectionState())
+	}
+
+	// Closing the parent closes its children.
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if billing.ConnectionState() != StateClosed {
+		t.Errorf("expected the child to be closed but got %s", billing.ConnectionState())
+	}
+	if err := billing.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != ErrHookClosed {
+		t.Errorf("expected ErrHookClosed from the child of a closed hook but got %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "clone_test", logrus.Fields{"host": "web-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := hook.Clone()
+	clone.WithField("request_id", "42")
+
+	for _, h := range []*Hook{clone, hook} {
+		if err := h.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, requestID := range []interface{}{"42", nil} {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["request_id"] != requestID || res["host"] != "web-1" || res["type"] != "clone_test" {
+			t.Errorf("expected request_id '%v', host 'web-1' and type 'clone_test' but got '%v'", requestID, res)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package logrustash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The limits of a PutLogEvents call.
+	cloudWatchLogsMaxBatchSize   = 1048576
+	cloudWatchLogsMaxBatchEvents = 10000
+	cloudWatchLogsEventOverhead  = 26 // added to the size of each message
+
+	cloudWatchLogsFlushInterval = time.Second
+)
+
+// CloudWatchLogsEvent is a log event sent with PutLogEvents.
+type CloudWatchLogsEvent struct {
+	Message   string
+	Timestamp int64 // milliseconds since the Unix epoch
+}
+
+// CloudWatchLogsClient sends a batch of events to a log stream of AWS CloudWatch Logs.
+// Implement it on top of the client of your choice, e.g. PutLogEvents of the
+// *cloudwatchlogs.Client of github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs.
+//
+// PutLogEvents returns the sequence token for the next call, which is passed back as sequenceToken.
+type CloudWatchLogsClient interface {
+	PutLogEvents(ctx context.Context, logGroupName, logStreamName string, events []CloudWatchLogsEvent, sequenceToken *string) (nextSequenceToken *string, err error)
+}
+
+// WithCloudWatchLogsTransport makes the hook send the messages to the log stream logStreamName
+// of the log group logGroupName of AWS CloudWatch Logs with client instead of a connection (see WithTransport).
+// The messages are sent in batches, when a batch reaches the limits of PutLogEvents
+// (1 MB or 10000 events), a second after its first message or when the hook is flushed or closed.
+// A message counts as sent once it is added to a batch; the errors of the batches sent
+// in the background are printed and the batch is sent again later.
+func WithCloudWatchLogsTransport(client CloudWatchLogsClient, logGroupName, logStreamName string) Option {
+	return WithTransport(&cloudWatchLogsTransport{
+		client:        client,
+		group:         logGroupName,
+		stream:        logStreamName,
+		flushInterval: cloudWatchLogsFlushInterval,
+		now:           time.Now,
+	})
+}
+
+// cloudWatchLogsTransport is a Transport which sends the messages in batches with PutLogEvents.
+type cloudWatchLogsTransport struct {
+	sync.Mutex
+	client        CloudWatchLogsClient
+	group         string
+	stream        string
+	sequenceToken *string
+	events        []CloudWatchLogsEvent
+	size          int
+	flushInterval time.Duration
+	timer         *time.Timer // sends the batch in the background
+	closed        bool        // Close has been called since the last Send, so the timer mustn't be armed
+	now           func() time.Time
+}
+
+func (t *cloudWatchLogsTransport) Send(ctx context.Context, data []byte) error {
+	message := strings.TrimSuffix(string(data), "\n")
+	size := len(message) + cloudWatchLogsEventOverhead
+	if size > cloudWatchLogsMaxBatchSize {
+		return fmt.Errorf("message of %d bytes exceeds the limit of CloudWatch Logs", len(message))
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if len(t.events) == cloudWatchLogsMaxBatchEvents || t.size+size > cloudWatchLogsMaxBatchSize {
+		if err := t.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	// The timestamp is the time of the entry, unless it's unknown.
+	sent, ok := entryTime(ctx)
+	if !ok {
+		sent = t.now()
+	}
+	timestamp := sent.UnixNano() / int64(time.Millisecond)
+	if n := len(t.events); n > 0 && timestamp < t.events[n-1].Timestamp {
+		// The events of a batch must be in chronological order.
+		timestamp = t.events[n-1].Timestamp
+	}
+	t.events = append(t.events, CloudWatchLogsEvent{Message: message, Timestamp: timestamp})
+	t.size += size
+	// The hook sends the messages again after Reset.
+	t.closed = false
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.flushInterval, t.flushInBackground)
+	}
+	return nil
+}
+
+// Flush sends the current batch. It is called by Flush of the hook.
+func (t *cloudWatchLogsTransport) Flush(ctx context.Context) error {
+	t.Lock()
+	defer t.Unlock()
+	return t.flush(ctx)
+}
+
+// Close sends the current batch and stops sending it in the background.
+func (t *cloudWatchLogsTransport) Close() error {
+	t.Lock()
+	defer t.Unlock()
+	t.closed = true
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	return t.flush(context.Background())
+}
+
+func (t *cloudWatchLogsTransport) flushInBackground() {
+	t.Lock()
+	defer t.Unlock()
+	t.timer = nil
+	if t.closed {
+		return
+	}
+	if err := t.flush(context.Background()); err != nil {
+		fmt.Println("Error during sending messages to CloudWatch Logs:", err)
+		t.timer = time.AfterFunc(t.flushInterval, t.flushInBackground)
+	}
+}
+
+// flush sends the current batch, if any. The batch is kept if it can't be sent.
+func (t *cloudWatchLogsTransport) flush(ctx context.Context) error {
+	if len(t.events) == 0 {
+		return nil
+	}
+
+	token, err := t.client.PutLogEvents(ctx, t.group, t.stream, t.events, t.sequenceToken)
+	if err != nil {
+		return err
+	}
+	t.sequenceToken = token
+	t.events = nil
+	t.size = 0
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package logrustash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cloudWatchLogsClientMock records the batches and checks the sequence tokens.
+type cloudWatchLogsClientMock struct {
+	sync.Mutex
+	batches [][]CloudWatchLogsEvent
+	tokens  []*string
+	err     error
+}
+
+func (c *cloudWatchLogsClientMock) PutLogEvents(ctx context.Context, logGroupName, logStreamName string, events []CloudWatchLogsEvent, sequenceToken *string) (*string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if logGroupName != "group" || logStreamName != "stream" {
+		return nil, fmt.Errorf("unexpected log stream %s/%s", logGroupName, logStreamName)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.batches = append(c.batches, append([]CloudWatchLogsEvent(nil), events...))
+	c.tokens = append(c.tokens, sequenceToken)
+	next := fmt.Sprint(len(c.batches))
+	return &next, nil
+}
+
+func (c *cloudWatchLogsClientMock) batchSizes() []int {
+	c.Lock()
+	defer c.Unlock()
+	var sizes []int
+	for _, batch := range c.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestCloudWatchLogsTransport(t *testing.T) {
+	client := &cloudWatchLogsClientMock{}
+	hook, err := NewHook("", "", "cloudwatch_test", WithCloudWatchLogsTransport(client, "group", "stream"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.transport.(*cloudWatchLogsTransport).flushInterval = time.Hour // only the limits, Flush and Close send batches
+
+	// A batch is limited by the size...
+	long := strings.Repeat("x", cloudWatchLogsMaxBatchSize/5)
+	for i := 0; i < 5; i++ {
+		if err := hook.Fire(&logrus.Entry{Message: long, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// ... and by the number of events.
+	transport := hook.transport.(*cloudWatchLogsTransport)
+	for i := 0; i < cloudWatchLogsMaxBatchEvents; i++ {
+		if err := transport.Send(context.Background(), []byte("{}\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if sizes := client.batchSizes(); fmt.Sprint(sizes) != fmt.Sprint([]int{4, cloudWatchLogsMaxBatchEvents, 1}) {
+		t.Errorf("expected batches of 4, %d and 1 events but got %v", cloudWatchLogsMaxBatchEvents, sizes)
+	}
+
+	// The sequence token of each call is passed to the next one.
+	if client.tokens[0] != nil || *client.tokens[1] != "1" || *client.tokens[2] != "2" {
+		t.Errorf("expected the sequence tokens <nil>, 1 and 2")
+	}
+	event := client.batches[0][0]
+	if !strings.HasPrefix(event.Message, "{") || strings.HasSuffix(event.Message, "\n") {
+		t.Errorf("expected a JSON message without the newline but got '%.20s...'", event.Message)
+	}
+	if age := time.Since(time.Unix(0, event.Timestamp*int64(time.Millisecond))); age < 0 || age > time.Minute {
+		t.Errorf("expected the timestamp to be the time the message was sent but got %d", event.Timestamp)
+	}
+
+	// The rest is sent on Close.
+	fired := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	if err := hook.Fire(&logrus.Entry{Message: "last", Data: logrus.Fields{}, Time: fired}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sizes := client.batchSizes(); len(sizes) != 4 || sizes[3] != 1 {
+		t.Fatalf("expected the last message to be sent on Close but got batches %v", sizes)
+	}
+	// The timestamp of an entry with a time is the time of the entry.
+	if timestamp := client.batches[3][0].Timestamp; timestamp != fired.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("expected the timestamp to be the time of the entry but got %d", timestamp)
+	}
+}
+
+func TestCloudWatchLogsTransportBackground(t *testing.T) {
+	client := &cloudWatchLogsClientMock{err: errors.New("throttled")}
+	transport := &cloudWatchLogsTransport{
+		client:        client,
+		group:         "group",
+		stream:        "stream",
+		flushInterval: 10 * time.Millisecond,
+		now:           time.Now,
+	}
+	if err := transport.Send(context.Background(), []byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed batch is sent again later.
+	time.Sleep(50 * time.Millisecond)
+	client.Lock()
+	client.err = nil
+	client.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.batchSizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sizes := client.batchSizes(); len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("expected the batch to be sent in the background but got batches %v", sizes)
+	}
+
+	if err := transport.Send(context.Background(), make([]byte, cloudWatchLogsMaxBatchSize)); err == nil {
+		t.Error("expected an error for a message exceeding the limit")
+	}
+	if err := transport.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch which can't be sent on Close isn't sent in the background anymore.
+	client.Lock()
+	client.err = errors.New("throttled")
+	client.Unlock()
+	if err := transport.Send(context.Background(), []byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Close(); err == nil {
+		t.Error("expected Close to fail")
+	}
+	client.Lock()
+	client.err = nil
+	client.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	transport.Lock()
+	timer := transport.timer
+	transport.Unlock()
+	if timer != nil {
+		t.Error("expected Close to stop the timer")
+	}
+	if sizes := client.batchSizes(); len(sizes) != 1 {
+		t.Errorf("expected no batches to be sent after Close but got batches %v", sizes)
+	}
+}
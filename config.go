@@ -0,0 +1,253 @@
+package logrustash
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// hookConfig is the configuration of a hook read from a string source (a URL or the environment).
+type hookConfig struct {
+	protocol   string
+	address    string
+	appName    string
+	prefix     string
+	fields     logrus.Fields
+	async      bool
+	bufferSize int
+	opts       []Option
+}
+
+// hookParams are the settings which can be configured with a string,
+// by the names of the query parameters of NewHookFromURL.
+var hookParams = map[string]func(c *hookConfig, value string) error{
+	"app": func(c *hookConfig, value string) error {
+		c.appName = value
+		return nil
+	},
+	"prefix": func(c *hookConfig, value string) error {
+		c.prefix = value
+		return nil
+	},
+	"buffer": func(c *hookConfig, value string) error {
+		n, err := parseNonNegativeInt(value)
+		if err != nil {
+			return err
+		}
+		c.async = true
+		c.bufferSize = n
+		return nil
+	},
+	"wait_buffer": func(c *hookConfig, value string) error {
+		wait, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, func(h *Hook) { h.WaitUntilBufferFrees = wait })
+		return nil
+	},
+	"buffer_full": func(c *hookConfig, value string) error {
+		strategy, err := parseBufferFullStrategy(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, WithBufferFullStrategy(strategy))
+		return nil
+	},
+	"timeout": func(c *hookConfig, value string) error {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, func(h *Hook) { h.Timeout = timeout })
+		return nil
+	},
+	"dial_timeout": func(c *hookConfig, value string) error {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, WithDialTimeout(timeout))
+		return nil
+	},
+	"max_send_retries": func(c *hookConfig, value string) error {
+		n, err := parseNonNegativeInt(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, WithMaxSendRetries(n))
+		return nil
+	},
+	"max_retry_elapsed_time": func(c *hookConfig, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, WithMaxRetryElapsedTime(d))
+		return nil
+	},
+	"reconnect_delay": func(c *hookConfig, value string) error {
+		delay, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, func(h *Hook) { h.ReconnectBaseDelay = delay })
+		return nil
+	},
+	"reconnect_multiplier": func(c *hookConfig, value string) error {
+		multiplier, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, func(h *Hook) { h.ReconnectDelayMultiplier = multiplier })
+		return nil
+	},
+	"max_reconnect_retries": func(c *hookConfig, value string) error {
+		n, err := parseNonNegativeInt(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, WithMaxReconnectRetries(n))
+		return nil
+	},
+	"max_datagram_size": func(c *hookConfig, value string) error {
+		n, err := parseNonNegativeInt(value)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, func(h *Hook) { h.MaxDatagramSize = n })
+		return nil
+	},
+	"time_format": func(c *hookConfig, value string) error {
+		c.opts = append(c.opts, func(h *Hook) { h.TimeFormat = value })
+		return nil
+	},
+}
+
+// NewHookFromURL creates a new hook configured by rawurl, e.g.
+// "tls://logstash.prod:5044?app=checkout&timeout=5s&buffer=10000&prefix=_ls_".
+// The scheme is the protocol: "tcp", "udp", "tls", "http", "https", "lumberjack" or "kafka";
+// the address is the host (with the path for "http", "https" and "kafka").
+// The query parameters are:
+//
+//	app                    appName
+//	prefix                 the hook only prefix
+//	buffer                 the size of the buffer of the async mode; without it the hook is synchronous
+//	wait_buffer            WaitUntilBufferFrees, e.g. "true"
+//	buffer_full            the BufferFullStrategy: "drop_newest", "block", "drop_oldest" or "return_error"
+//	timeout                Timeout, e.g. "5s"
+//	dial_timeout           DialTimeout, e.g. "5s"
+//	max_send_retries       MaxSendRetries
+//	max_retry_elapsed_time MaxRetryElapsedTime, e.g. "30s"
+//	reconnect_delay        ReconnectBaseDelay, e.g. "100ms"
+//	reconnect_multiplier   ReconnectDelayMultiplier, e.g. "2"
+//	max_reconnect_retries  MaxReconnectRetries (see WithMaxReconnectRetries)
+//	max_datagram_size      MaxDatagramSize
+//	time_format            TimeFormat
+//
+// Unknown and invalid parameters are reported all at once. opts are applied after the parameters.
+func NewHookFromURL(rawurl string, opts ...Option) (*Hook, error) {
+	c, err := parseHookURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.newHook(opts)
+}
+
+func parseHookURL(rawurl string) (*hookConfig, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid logstash URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Invalid logstash URL '%s': no host", rawurl)
+	}
+	if u.User != nil || u.Fragment != "" {
+		return nil, fmt.Errorf("Invalid logstash URL '%s': user info and fragments are not supported", rawurl)
+	}
+
+	c := &hookConfig{protocol: u.Scheme, fields: make(logrus.Fields)}
+	switch u.Scheme {
+	case "tcp", "udp", "tls", "lumberjack":
+		if u.Path != "" {
+			return nil, fmt.Errorf("Invalid logstash URL '%s': the %s protocol doesn't support paths", rawurl, u.Scheme)
+		}
+		c.address = u.Host
+	case "http", "https", "kafka":
+		c.address = u.Host + u.Path
+	default:
+		return nil, fmt.Errorf("Invalid logstash URL '%s': unsupported protocol '%s'", rawurl, u.Scheme)
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid logstash URL '%s': %w", rawurl, err)
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		values := query[name]
+		if len(values) > 1 {
+			errs = append(errs, fmt.Errorf("parameter '%s' is set %d times", name, len(values)))
+			continue
+		}
+		if err := c.set(name, values[0]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Invalid logstash URL '%s': %w", rawurl, errors.Join(errs...))
+	}
+
+	return c, nil
+}
+
+// set applies the parameter name (see NewHookFromURL) to the configuration.
+func (c *hookConfig) set(name, value string) error {
+	param, ok := hookParams[name]
+	if !ok {
+		return fmt.Errorf("unknown parameter '%s'", name)
+	}
+	if err := param(c, value); err != nil {
+		return fmt.Errorf("invalid value '%s' of parameter '%s': %w", value, name, err)
+	}
+
+	return nil
+}
+
+func (c *hookConfig) newHook(opts []Option) (*Hook, error) {
+	opts = append(c.opts[:len(c.opts):len(c.opts)], opts...)
+	hook, err := NewHookWithFieldsAndPrefix(c.protocol, c.address, c.appName, c.fields, c.prefix, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.async {
+		hook.AsyncBufferSize = c.bufferSize
+		hook.makeAsync()
+	}
+
+	return hook, nil
+}
+
+func parseNonNegativeInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.New("must not be negative")
+	}
+
+	return n, nil
+}
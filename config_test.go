@@ -0,0 +1,114 @@
+package logrustash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewHookFromURL(t *testing.T) {
+	tt := map[string]func(h *Hook) bool{
+		"app=checkout":               func(h *Hook) bool { return h.appName == "checkout" },
+		"prefix=_ls_":                func(h *Hook) bool { return h.prefix() == "_ls_" },
+		"buffer=10000":               func(h *Hook) bool { return h.AsyncBufferSize == 10000 && h.fireChannel != nil },
+		"wait_buffer=true":           func(h *Hook) bool { return h.WaitUntilBufferFrees },
+		"buffer_full=drop_oldest":    func(h *Hook) bool { return h.bufferFullStrategy == DropOldest },
+		"timeout=5s":                 func(h *Hook) bool { return h.Timeout == 5*time.Second },
+		"dial_timeout=2s":            func(h *Hook) bool { return h.DialTimeout == 2*time.Second },
+		"max_send_retries=3":         func(h *Hook) bool { return h.MaxSendRetries == 3 },
+		"reconnect_delay=100ms":      func(h *Hook) bool { return h.ReconnectBaseDelay == 100*time.Millisecond },
+		"reconnect_multiplier=1.5":   func(h *Hook) bool { return h.ReconnectDelayMultiplier == 1.5 },
+		"max_reconnect_retries=7":    func(h *Hook) bool { return h.MaxReconnectRetries == 7 && h.giveUpReconnecting },
+		"max_datagram_size=1400":     func(h *Hook) bool { return h.MaxDatagramSize == 1400 },
+		"time_format=2006-01-02":     func(h *Hook) bool { return h.TimeFormat == "2006-01-02" },
+		"app=a&timeout=1s&buffer=16": func(h *Hook) bool { return h.appName == "a" && h.Timeout == time.Second && h.AsyncBufferSize == 16 },
+	}
+	for query, check := range tt {
+		hook, err := NewHookFromURL("udp://127.0.0.1:9?" + query)
+		if err != nil {
+			t.Errorf("%s: %s", query, err)
+			continue
+		}
+		if hook.protocol != "udp" || hook.address != "127.0.0.1:9" {
+			t.Errorf("%s: expected udp://127.0.0.1:9 but got %s://%s", query, hook.protocol, hook.address)
+		}
+		if !check(hook) {
+			t.Errorf("%s: the parameter hasn't been applied", query)
+		}
+		hook.Close()
+	}
+
+	hook, err := NewHookFromURL("udp://127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if hook.fireChannel != nil {
+		t.Error("expected the hook to be synchronous without the buffer parameter")
+	}
+}
+
+func TestParseHookURL(t *testing.T) {
+	for rawurl, address := range map[string]string{
+		"tcp://logstash:5000": "logstash:5000",
+		"tls://logstash.prod:5044?app=checkout&timeout=5s&buffer=10000&prefix=_ls_": "logstash.prod:5044",
+		"https://logstash.example.com/ingest":                                       "logstash.example.com/ingest",
+		"kafka://kafka1:9092/logs":                                                  "kafka1:9092/logs",
+	} {
+		c, err := parseHookURL(rawurl)
+		if err != nil {
+			t.Errorf("%s: %s", rawurl, err)
+			continue
+		}
+		if c.address != address {
+			t.Errorf("%s: expected address '%s' but got '%s'", rawurl, address, c.address)
+		}
+	}
+}
+
+func TestParseHookURLErrors(t *testing.T) {
+	tt := map[string][]string{
+		"://logstash":                         {"Invalid logstash URL"},
+		"tcp://":                              {"no host"},
+		"ftp://logstash:21":                   {"unsupported protocol 'ftp'"},
+		"tcp://logstash:5044/path":            {"doesn't support paths"},
+		"tcp://user@logstash:5044":            {"user info"},
+		"tcp://logstash:5044?foo=1":           {"unknown parameter 'foo'"},
+		"tcp://logstash:5044?timeout=5":       {"invalid value '5' of parameter 'timeout'"},
+		"tcp://logstash:5044?buffer=-1":       {"must not be negative"},
+		"tcp://logstash:5044?app=a&app=b":     {"parameter 'app' is set 2 times"},
+		"tcp://logstash:5044?foo=1&timeout=x": {"unknown parameter 'foo'", "parameter 'timeout'"},
+	}
+	for rawurl, expected := range tt {
+		_, err := parseHookURL(rawurl)
+		if err == nil {
+			t.Errorf("%s: expected an error", rawurl)
+			continue
+		}
+		for _, s := range expected {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%s: expected the error to contain '%s' but got '%s'", rawurl, s, err)
+			}
+		}
+	}
+}
+
+func TestNewHookFromURLTLS(t *testing.T) {
+	listener, config := newTLSListener(t)
+	messages := make(chan string, 1)
+	go readTLSMessage(t, listener, messages)
+
+	hook, err := NewHookFromURL("tls://"+listener.Addr().String()+"?app=checkout&timeout=5s", WithTLSConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-messages; message != "hello" {
+		t.Errorf("expected message to be 'hello' but got '%s'", message)
+	}
+}
@@ -0,0 +1,114 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// HookConfig is the configuration of a hook read from a file by LoadConfig, e.g.
+//
+//	protocol: tcp
+//	address: logstash.prod:5044
+//	app_name: checkout
+//	fields:
+//	  region: ${AWS_REGION}
+//	buffer_size: 10000
+//	params:
+//	  timeout: 5s
+//	  max_reconnect_retries: "10"
+type HookConfig struct {
+	Protocol   string            `json:"protocol" yaml:"protocol"`       // "tcp" by default
+	Address    string            `json:"address" yaml:"address"`         // required
+	AppName    string            `json:"app_name" yaml:"app_name"`       // required
+	Prefix     string            `json:"prefix" yaml:"prefix"`           // the hook only prefix
+	Fields     map[string]string `json:"fields" yaml:"fields"`           // the always sent fields; environment variables like ${VAR} are substituted
+	BufferSize int               `json:"buffer_size" yaml:"buffer_size"` // the size of the buffer of the async mode; zero means the hook is synchronous
+	Params     map[string]string `json:"params" yaml:"params"`           // the other parameters of NewHookFromURL, e.g. "timeout": "5s"
+}
+
+// configParams are the parameters of NewHookFromURL which are set by the fields of HookConfig instead of Params.
+var configParams = map[string]string{
+	"app":    "app_name",
+	"prefix": "prefix",
+	"buffer": "buffer_size",
+}
+
+// LoadConfig reads the configuration of a hook from the file at path, which is JSON if its
+// extension is ".json" and YAML otherwise. Unknown keys are reported as errors.
+// Use HookConfig.Build to create the hook.
+func LoadConfig(path string) (*HookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &HookConfig{}
+	if filepath.Ext(path) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid logstash configuration in '%s': %w", path, err)
+	}
+
+	return c, nil
+}
+
+// Build creates a new hook configured by c. Missing and invalid settings are reported all at once.
+// opts are applied after the settings.
+func (c *HookConfig) Build(opts ...Option) (*Hook, error) {
+	hc := &hookConfig{protocol: c.Protocol, address: c.Address, appName: c.AppName, prefix: c.Prefix, fields: make(logrus.Fields)}
+	if hc.protocol == "" {
+		hc.protocol = "tcp"
+	}
+	for k, v := range c.Fields {
+		hc.fields[k] = os.ExpandEnv(v)
+	}
+	if c.BufferSize > 0 {
+		hc.async = true
+		hc.bufferSize = c.BufferSize
+	}
+
+	var errs []error
+	if c.Address == "" {
+		errs = append(errs, errors.New("address is not set"))
+	}
+	if c.AppName == "" {
+		errs = append(errs, errors.New("app_name is not set"))
+	}
+	if c.BufferSize < 0 {
+		errs = append(errs, errors.New("buffer_size must not be negative"))
+	}
+	names := make([]string, 0, len(c.Params))
+	for name := range c.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if field, ok := configParams[name]; ok {
+			errs = append(errs, fmt.Errorf("parameter '%s' must be set with %s", name, field))
+			continue
+		}
+		if err := hc.set(name, c.Params[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Invalid logstash configuration: %w", errors.Join(errs...))
+	}
+
+	return hc.newHook(opts)
+}
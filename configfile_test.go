@@ -0,0 +1,96 @@
+package logrustash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	t.Setenv("LOGRUSTASH_TEST_REGION", "eu-west-1")
+
+	files := map[string]string{
+		"hook.yaml": `
+address: ` + server.Addr() + `
+app_name: config_test
+fields:
+  region: ${LOGRUSTASH_TEST_REGION}
+buffer_size: 16
+params:
+  timeout: 5s
+`,
+		"hook.json": `{
+	"protocol": "tcp",
+	"address": "` + server.Addr() + `",
+	"app_name": "config_test",
+	"fields": {"region": "$LOGRUSTASH_TEST_REGION"},
+	"buffer_size": 16,
+	"params": {"timeout": "5s"}
+}`,
+	}
+	for name, content := range files {
+		c, err := LoadConfig(writeConfigFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		hook, err := c.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if hook.protocol != "tcp" || hook.Timeout != 5*time.Second || hook.AsyncBufferSize != 16 || hook.fireChannel == nil {
+			t.Errorf("%s: the configuration hasn't been applied", name)
+		}
+		fireMessages(t, hook, name)
+		if err := hook.Flush(5 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+		hook.Close()
+	}
+
+	events, err := server.WaitForEvents(2, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if event["region"] != "eu-west-1" || event["type"] != "config_test" {
+			t.Errorf("expected region 'eu-west-1' and type 'config_test' but got %v", event)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	if _, err := LoadConfig(writeConfigFile(t, "hook.yaml", "adress: logstash:5044\n")); err == nil || !strings.Contains(err.Error(), "adress") {
+		t.Errorf("expected an error for the unknown key but got %v", err)
+	}
+	if _, err := LoadConfig(writeConfigFile(t, "hook.json", `{"app": "x"}`)); err == nil {
+		t.Error("expected an error for the unknown key")
+	}
+
+	c, err := LoadConfig(writeConfigFile(t, "hook.yaml", "params:\n  timeout: soon\n  app: x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Build()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{"address is not set", "app_name is not set", "'timeout'", "'app' must be set with app_name"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain \"%s\" but got '%s'", expected, err)
+		}
+	}
+}
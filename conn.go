@@ -0,0 +1,296 @@
+package logrustash
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	gas "github.com/xaionaro-go/goautosocket"
+)
+
+// The reconnect parameters gas.Dial sets on its connections.
+const (
+	gasMaxRetries    = 10
+	gasRetryInterval = 10 * time.Millisecond
+)
+
+// connTransport is the Transport of a hook which isn't created with one (see WithTransport).
+// It writes the messages to the connection of the hook, dialing `protocol`://`address`
+// when there is none yet and reconnecting according to the reconnect parameters of the hook.
+// The connection is kept in the hook, so the hooks created with a connection use it as is.
+type connTransport struct {
+	h *Hook
+}
+
+// Send writes data to the connection of the hook before ctx is done.
+func (t connTransport) Send(ctx context.Context, data []byte) error {
+	_, err := t.sendFrom(ctx, data, 0)
+	return err
+}
+
+// sendFrom writes data starting from the offset written to the connection of the hook
+// before ctx is done and returns the new offset.
+func (t connTransport) sendFrom(ctx context.Context, data []byte, written int) (int, error) {
+	h := t.h
+	// The deadline and the writes must apply to the same connection,
+	// so reconnect must not replace it in between.
+	h.Lock()
+	defer h.Unlock()
+
+	if h.conn == nil {
+		return written, ErrNotConnected
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		h.conn.SetWriteDeadline(deadline)
+	} else if h.levelTimeouts != nil {
+		// Clear the deadline of the previous entry of a level with a timeout.
+		h.conn.SetWriteDeadline(time.Time{})
+	}
+	for written < len(data) {
+		n, err := h.conn.Write(data[written:])
+		if n > 0 { // goautosocket returns -1 on errors
+			written += n
+		}
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+
+	return written, nil
+}
+
+// isStream reports whether the current connection is a byte stream, so several messages
+// may be written at once. A datagram, a Kafka record, etc. carries a single message.
+func (t connTransport) isStream() bool {
+	if t.h.maxDatagramSize(t) > 0 {
+		return false
+	}
+	switch t.h.getConn().(type) {
+	case *kafkaConn, *lumberjackConn:
+		return false
+	}
+	return true
+}
+
+// Close closes the connection of the hook.
+func (t connTransport) Close() error {
+	if conn := t.h.getConn(); conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// connect establishes the connection if it has never been established.
+// It returns false without an error for a filteringHook, which doesn't send anything.
+func (t connTransport) connect() (bool, error) {
+	if t.h.getConn() != nil {
+		return true, nil
+	}
+	// For a filteringHook, stop here
+	if protocol, address := t.h.target(); protocol == "" || address == "" {
+		return false, nil
+	}
+
+	if err := t.reconnect(0); err != nil {
+		return false, &NetworkError{Err: fmt.Errorf("Couldn't connect to logstash: %w", err)}
+	}
+	return true, nil
+}
+
+// reconnect replaces the connection of the hook with a new one to `protocol`://`address`.
+// The hook will reconnect to Logstash several times with increasing sleep duration between each reconnect attempt.
+// Sleep duration calculated as product of ReconnectBaseDelay by ReconnectDelayMultiplier to the power of reconnectRetries.
+// reconnectRetries is the actual number of attempts to reconnect.
+func (t connTransport) reconnect(reconnectRetries int) error {
+	h := t.h
+	protocol, address := h.target()
+	if protocol == "" || address == "" {
+		return fmt.Errorf("Can't reconnect because current configuration doesn't support it")
+	}
+
+	h.setState(StateReconnecting)
+	conn, err := h.redial(protocol, address, reconnectRetries, h.notifyReconnect)
+	if err != nil {
+		h.setState(StateDisconnected)
+		if h.giveUpReconnecting {
+			h.giveUp()
+		}
+		return err
+	}
+
+	if !h.setConnTo(conn, protocol, address) {
+		conn.Close()
+		if h.ConnectionState() == StateClosed {
+			return ErrHookClosed
+		}
+		// The address has been changed by SetAddress while dialing.
+		return t.reconnect(reconnectRetries)
+	}
+	h.setState(StateConnected)
+	h.statsd.count("reconnects", 1)
+
+	return nil
+}
+
+// getConn returns the current connection to logstash (nil for a filtering hook).
+func (h *Hook) getConn() net.Conn {
+	h.RLock()
+	defer h.RUnlock()
+	return h.conn
+}
+
+// setConn replaces the connection to logstash.
+func (h *Hook) setConn(conn net.Conn) {
+	h.Lock()
+	defer h.Unlock()
+	h.conn = conn
+}
+
+// dial establishes a new connection to `protocol`://`address` and configures it.
+func (h *Hook) dial(protocol, address string) (net.Conn, error) {
+	conn, err := h.dialConn(protocol, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.configureConn(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// dialConn establishes a new connection to `protocol`://`address` (using the connection factory
+// if it is set) respecting DialTimeout.
+func (h *Hook) dialConn(protocol, address string) (net.Conn, error) {
+	if protocol == "lumberjack" {
+		return h.dialLumberjack(address)
+	}
+	if protocol == "kafka" {
+		return h.dialKafka(address)
+	}
+	if protocol == "tls" {
+		return h.dialTLS(address)
+	}
+	if h.connFactory != nil {
+		return h.dialFactory(protocol, address)
+	}
+	if h.useProxy(protocol) {
+		return h.dialProxy(protocol, address)
+	}
+
+	if !isIPProtocol(protocol) {
+		return h.dialer(protocol).Dial(protocol, address)
+	}
+	return h.dialResolved(protocol, address)
+}
+
+// dialIP establishes a new connection to `protocol`://`address`, where address has a resolved host,
+// within timeout (zero means the OS default).
+func (h *Hook) dialIP(protocol, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := h.dialer(protocol)
+	dialer.Timeout = timeout
+	if protocol != "tcp" || h.localAddr != nil {
+		// The connections of goautosocket reconnect without the local address,
+		// so the hook reconnects a bound connection itself.
+		return dialer.Dial(protocol, address)
+	}
+
+	if timeout <= 0 {
+		return gas.Dial("tcp", address)
+	}
+
+	// gas.Dial doesn't support timeouts, so the connection is dialed with the timeout
+	// and then wrapped to reconnect the same way as a connection of gas.Dial does.
+	conn, err := dialer.Dial(protocol, address)
+	if err != nil {
+		return nil, err
+	}
+	client := &gas.TCPClient{TCPConn: conn.(*net.TCPConn)}
+	client.SetMaxRetries(gasMaxRetries)
+	client.SetRetryInterval(gasRetryInterval)
+	return client, nil
+}
+
+// dialFactory establishes a new connection to `protocol`://`address` using the connection factory,
+// respecting DialTimeout. A connection the factory returns after the timeout is closed.
+func (h *Hook) dialFactory(protocol, address string) (net.Conn, error) {
+	if h.DialTimeout <= 0 {
+		return h.connFactory(protocol, address)
+	}
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	resultChan := make(chan dialResult, 1)
+	go func() {
+		conn, err := h.connFactory(protocol, address)
+		resultChan <- dialResult{conn, err}
+	}()
+
+	timer := time.NewTimer(h.DialTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-resultChan:
+		return result.conn, result.err
+	case <-timer.C:
+		go func() {
+			// Close the connection if it is established after all.
+			if result := <-resultChan; result.conn != nil {
+				result.conn.Close()
+			}
+		}()
+		return nil, fmt.Errorf("Dial %s://%s: timeout after %s", protocol, address, h.DialTimeout)
+	}
+}
+
+// redial dials `protocol`://`address` using the reconnect parameters of the hook.
+// onDial (if not nil) is called with the result of each attempt.
+func (h *Hook) redial(protocol, address string, reconnectRetries int, onDial func(error)) (net.Conn, error) {
+	// Sleep before reconnect.
+	delay := float64(h.ReconnectBaseDelay) * math.Pow(h.ReconnectDelayMultiplier, float64(reconnectRetries))
+	time.Sleep(time.Duration(delay))
+
+	conn, err := h.dial(protocol, address)
+	if err != nil {
+		h.reportError(err, OperationDial)
+	}
+	if onDial != nil {
+		onDial(err)
+	}
+
+	// Oops. Can't connect. No problem. Let's try again.
+	if err != nil {
+		if !h.isNeedToReconnect(reconnectRetries) {
+			// We have reached limit of re-connections.
+			return nil, err
+		}
+
+		return h.redial(protocol, address, reconnectRetries+1, onDial)
+	}
+
+	return conn, nil
+}
+
+// notifyReconnect reports the result of a reconnect attempt to the channel set by WithReconnectNotify.
+func (h *Hook) notifyReconnect(err error) {
+	if h.reconnectNotify == nil {
+		return
+	}
+
+	select {
+	case h.reconnectNotify <- err:
+	default:
+	}
+}
+
+func (h *Hook) isNeedToReconnect(reconnectRetries int) bool {
+	return reconnectRetries < h.MaxReconnectRetries
+}
@@ -0,0 +1,74 @@
+package logrustash
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithContextExtractor makes the hook add the fields returned by extractor
+// for the context of each entry (see logrus.Entry.WithContext).
+// Fields which are already set in the entry are not overridden.
+// Entries without a context are not passed to extractor, and if extractor
+// panics its fields are skipped.
+func WithContextExtractor(extractor func(ctx context.Context) logrus.Fields) Option {
+	return func(h *Hook) {
+		h.contextExtractor = extractor
+	}
+}
+
+// contextKeyField is a context value sent as a field (see WithContextKey).
+type contextKeyField struct {
+	key       interface{}
+	fieldName string
+}
+
+// WithContextKey makes the hook add the value of ctxKey in the context of each entry
+// (see logrus.Entry.WithContext) as the field fieldName. It may be used several times
+// to send several values. Fields which are already set in the entry are not overridden,
+// and nothing is added if the context has no value for ctxKey.
+func WithContextKey(ctxKey interface{}, fieldName string) Option {
+	return func(h *Hook) {
+		h.contextKeys = append(h.contextKeys, contextKeyField{key: ctxKey, fieldName: fieldName})
+	}
+}
+
+func (h *Hook) extractContextFields(entry *logrus.Entry) {
+	if entry.Context == nil {
+		return
+	}
+	for _, f := range h.contextKeys {
+		if _, inMap := entry.Data[f.fieldName]; inMap {
+			continue
+		}
+		if v := entry.Context.Value(f.key); v != nil {
+			entry.Data[f.fieldName] = v
+		}
+	}
+	if h.contextExtractor == nil {
+		return
+	}
+
+	fields, err := h.safeExtractContextFields(entry.Context)
+	if err != nil {
+		fmt.Println("Error during extracting fields from context:", err)
+		return
+	}
+
+	for k, v := range fields {
+		if _, inMap := entry.Data[k]; !inMap {
+			entry.Data[k] = v
+		}
+	}
+}
+
+func (h *Hook) safeExtractContextFields(ctx context.Context) (fields logrus.Fields, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("context extractor panicked: %v", r)
+		}
+	}()
+
+	return h.contextExtractor(ctx), nil
+}
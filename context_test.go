@@ -0,0 +1,116 @@
+package logrustash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey string
+
+func requestFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if requestID, ok := ctx.Value(contextKey("request_id")).(string); ok {
+		fields["request_id"] = requestID
+	}
+	if tenantID, ok := ctx.Value(contextKey("tenant_id")).(string); ok {
+		fields["tenant_id"] = tenantID
+	}
+	return fields
+}
+
+func TestContextExtractor(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "context_test", WithContextExtractor(requestFields))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.Out = bytes.NewBufferString("")
+	logger.Hooks.Add(hook)
+
+	for _, requestID := range []string{"req-1", "req-2"} {
+		ctx := context.WithValue(context.Background(), contextKey("request_id"), requestID)
+		ctx = context.WithValue(ctx, contextKey("tenant_id"), "tenant-from-context")
+		logger.WithContext(ctx).WithField("tenant_id", "explicit-tenant").Info("request done")
+	}
+	logger.Info("no context")
+
+	dec := json.NewDecoder(conn.buff)
+	for _, requestID := range []string{"req-1", "req-2"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["request_id"] != requestID {
+			t.Errorf("expected request_id to be '%s' but got '%s'", requestID, res["request_id"])
+		}
+		if res["tenant_id"] != "explicit-tenant" {
+			t.Errorf("expected entry fields to win but got tenant_id '%s'", res["tenant_id"])
+		}
+	}
+	var res map[string]string
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res["request_id"]; ok {
+		t.Errorf("expected entry without context to have no request_id but got '%s'", res["request_id"])
+	}
+}
+
+func TestContextExtractorPanic(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "context_test", WithContextExtractor(func(ctx context.Context) logrus.Fields {
+		panic("broken extractor")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{}, Context: context.Background()}
+	if err := hook.Fire(entry); err != nil {
+		t.Error(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "hello" {
+		t.Errorf("expected message to be sent despite the panic but got '%v'", res)
+	}
+}
+
+func TestContextKey(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "context_test",
+		WithContextKey(contextKey("request_id"), "request_id"),
+		WithContextKey(contextKey("tenant_id"), "tenant"),
+		WithContextKey(contextKey("user_id"), "user_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), contextKey("request_id"), "req-1")
+	ctx = context.WithValue(ctx, contextKey("tenant_id"), "tenant-from-context")
+	entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{"tenant": "explicit-tenant"}, Context: ctx}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["request_id"] != "req-1" {
+		t.Errorf("expected request_id to be 'req-1' but got '%s'", res["request_id"])
+	}
+	if res["tenant"] != "explicit-tenant" {
+		t.Errorf("expected entry fields to win but got tenant '%s'", res["tenant"])
+	}
+	if _, ok := res["user_id"]; ok {
+		t.Errorf("expected no user_id for a context without it but got '%s'", res["user_id"])
+	}
+}
@@ -0,0 +1,108 @@
+package logrustash
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultDeduplicationCacheSize = 1024
+
+// deduplicator drops entries with the same level and message as an entry sent recently.
+type deduplicator struct {
+	sync.Mutex
+	window     time.Duration
+	cacheSize  int
+	countField string
+	entries    map[dedupKey]*list.Element
+	lru        *list.List // of *dedupEntry, the most recently used first
+	now        func() time.Time
+}
+
+type dedupKey struct {
+	level   logrus.Level
+	message string
+}
+
+type dedupEntry struct {
+	key        dedupKey
+	sentAt     time.Time
+	suppressed uint64
+}
+
+// WithDeduplication makes the hook drop an entry if an entry with the same level and message
+// has been sent less than window ago. Dropped entries are counted in DroppedCount.
+// The recently sent entries are kept in an LRU cache (see WithDeduplicationCacheSize).
+func WithDeduplication(window time.Duration) Option {
+	return func(h *Hook) {
+		h.deduplicator().window = window
+	}
+}
+
+// WithDeduplicationCacheSize sets how many distinct recently sent entries are remembered
+// by WithDeduplication. Default: 1024.
+func WithDeduplicationCacheSize(n int) Option {
+	return func(h *Hook) {
+		h.deduplicator().cacheSize = n
+	}
+}
+
+// WithDeduplicationCountField makes the hook send the number of entries dropped
+// by WithDeduplication since the previous copy in the field fieldName.
+// The field is only sent if at least one entry has been dropped.
+func WithDeduplicationCountField(fieldName string) Option {
+	return func(h *Hook) {
+		h.deduplicator().countField = fieldName
+	}
+}
+
+func (h *Hook) deduplicator() *deduplicator {
+	if h.dedup == nil {
+		h.dedup = newDeduplicator()
+	}
+
+	return h.dedup
+}
+
+func newDeduplicator() *deduplicator {
+	return &deduplicator{
+		cacheSize: defaultDeduplicationCacheSize,
+		entries:   make(map[dedupKey]*list.Element),
+		lru:       list.New(),
+		now:       time.Now,
+	}
+}
+
+// check reports whether an entry with level and message must be sent and,
+// if so, how many identical entries have been dropped since the previous one was sent.
+func (d *deduplicator) check(level logrus.Level, message string) (send bool, suppressed uint64) {
+	d.Lock()
+	defer d.Unlock()
+
+	now := d.now()
+	key := dedupKey{level: level, message: message}
+	if el, ok := d.entries[key]; ok {
+		d.lru.MoveToFront(el)
+		e := el.Value.(*dedupEntry)
+		if now.Sub(e.sentAt) < d.window {
+			e.suppressed++
+			return false, 0
+		}
+
+		suppressed = e.suppressed
+		e.sentAt = now
+		e.suppressed = 0
+		return true, suppressed
+	}
+
+	d.entries[key] = d.lru.PushFront(&dedupEntry{key: key, sentAt: now})
+	for d.cacheSize > 0 && d.lru.Len() > d.cacheSize {
+		oldest := d.lru.Back()
+		d.lru.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dedupEntry).key)
+	}
+
+	return true, 0
+}
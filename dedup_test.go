@@ -0,0 +1,76 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDeduplication(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "dedup_test", WithDeduplication(time.Minute), WithDeduplicationCountField("repeated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	hook.dedup.now = func() time.Time { return now }
+
+	fire := func(level logrus.Level, message string) {
+		if err := hook.Fire(&logrus.Entry{Message: message, Level: level, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		fire(logrus.ErrorLevel, "connection refused")
+	}
+	fire(logrus.WarnLevel, "connection refused")
+	now = now.Add(time.Minute)
+	fire(logrus.ErrorLevel, "connection refused")
+	fire(logrus.ErrorLevel, "connection refused")
+
+	if hook.DroppedCount() != 5 {
+		t.Errorf("expected 5 dropped entries but got %d", hook.DroppedCount())
+	}
+	expected := []struct {
+		level    string
+		repeated interface{}
+	}{
+		{"error", nil},
+		{"warning", nil},
+		{"error", float64(4)},
+	}
+	dec := json.NewDecoder(conn.buff)
+	for _, e := range expected {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["level"] != e.level || res["repeated"] != e.repeated {
+			t.Errorf("expected level '%s' and repeated '%v' but got '%v' and '%v'", e.level, e.repeated, res["level"], res["repeated"])
+		}
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+}
+
+func TestDeduplicationCacheSize(t *testing.T) {
+	hook := NewFilterHook(WithDeduplication(time.Minute), WithDeduplicationCacheSize(2))
+	for _, message := range []string{"a", "b", "c", "a"} {
+		hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}})
+	}
+	// "a" has been evicted by "c", so it is not a duplicate.
+	if hook.DroppedCount() != 0 {
+		t.Errorf("expected no dropped entries but got %d", hook.DroppedCount())
+	}
+	if hook.dedup.lru.Len() != 2 {
+		t.Errorf("expected 2 cached entries but got %d", hook.dedup.lru.Len())
+	}
+	hook.Fire(&logrus.Entry{Message: "a", Data: logrus.Fields{}})
+	if hook.DroppedCount() != 1 {
+		t.Errorf("expected 1 dropped entry but got %d", hook.DroppedCount())
+	}
+}
@@ -0,0 +1,189 @@
+package logrustash
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultDropMetaEntryInterval = 10 * time.Second
+
+// DropReason is the reason why an entry has been dropped, see OnDropped.
+type DropReason int
+
+const (
+	DropReasonChannelFull       DropReason = iota // the buffer of the async mode was full
+	DropReasonSampling                            // see WithSamplingRate
+	DropReasonDeduplication                       // see WithDeduplication
+	DropReasonGaveUp                              // the hook has given up reconnecting, see WithMaxReconnectRetries
+	DropReasonWriteFailed                         // all the retries to send the message have failed, or its error isn't retryable
+	DropReasonClosed                              // the entry was left in the buffer of a closed hook
+	DropReasonMiddleware                          // see WithEntryMiddleware
+	DropReasonRetryTimeExceeded                   // see WithMaxRetryElapsedTime
+	DropReasonOversized                           // the message didn't fit into a single datagram
+	DropReasonWALFull                             // see WithWALMaxSize
+	DropReasonPaused                              // the hook was paused in sync mode, see Pause
+
+	dropReasonCount
+)
+
+var dropReasonNames = [dropReasonCount]string{
+	DropReasonChannelFull:       "channel_full",
+	DropReasonSampling:          "sampling",
+	DropReasonDeduplication:     "deduplication",
+	DropReasonGaveUp:            "gave_up",
+	DropReasonWriteFailed:       "write_failed",
+	DropReasonClosed:            "closed",
+	DropReasonMiddleware:        "middleware",
+	DropReasonRetryTimeExceeded: "retry_time_exceeded",
+	DropReasonOversized:         "oversized",
+	DropReasonWALFull:           "wal_full",
+	DropReasonPaused:            "paused",
+}
+
+func (r DropReason) String() string {
+	if r < 0 || r >= dropReasonCount {
+		return fmt.Sprintf("DropReason(%d)", int(r))
+	}
+	return dropReasonNames[r]
+}
+
+// dropMetaEntry periodically reports the dropped entries to logstash.
+type dropMetaEntry struct {
+	sync.Mutex // held by the running loop, so a loop restarted by Reset waits for the previous one
+	enabled    bool
+	interval   time.Duration
+	reported   [dropReasonCount]uint64
+}
+
+// WithDropMetaEntry makes the hook periodically (every 10 seconds by default, see
+// WithDropMetaEntryInterval) send an entry with the number of the entries dropped since
+// the previous report, if any, so drop rates can be alerted on in the logstash pipeline.
+// The entry has the fields "dropped_count" and "drop_reason_<reason>" for each reason:
+// "channel_full", "sampling", "deduplication", "gave_up", "write_failed", "closed"
+// (left in the buffer of a closed hook), "middleware" (see WithEntryMiddleware),
+// "retry_time_exceeded" (see WithMaxRetryElapsedTime), "oversized", "wal_full" (see WithWALMaxSize)
+// and "paused" (see Pause).
+func WithDropMetaEntry(enabled bool) Option {
+	return func(h *Hook) {
+		h.dropMetaEntry().enabled = enabled
+	}
+}
+
+// WithDropMetaEntryInterval sets how often the entry enabled by WithDropMetaEntry is sent.
+func WithDropMetaEntryInterval(interval time.Duration) Option {
+	return func(h *Hook) {
+		h.dropMetaEntry().interval = interval
+	}
+}
+
+func (h *Hook) dropMetaEntry() *dropMetaEntry {
+	if h.dropMeta == nil {
+		h.dropMeta = &dropMetaEntry{interval: defaultDropMetaEntryInterval}
+	}
+
+	return h.dropMeta
+}
+
+func (h *Hook) dropMetaEntryEnabled() bool {
+	return h.dropMeta != nil && h.dropMeta.enabled && h.dropMeta.interval > 0
+}
+
+// OnDropped sets fn to be called with every entry the hook drops and the reason, e.g. to stash
+// the lost entries somewhere else. fn is called synchronously by the goroutine which has dropped
+// the entry (e.g. the one calling Fire when the buffer is full), but never while a connection is locked,
+// and its panics are recovered. The entry may be shared with the other hooks of the logger,
+// so fn must not modify it. The entry is nil for the records of the write-ahead log (see WithWAL),
+// which don't keep their entries. A nil fn removes the callback.
+func (h *Hook) OnDropped(fn func(entry *logrus.Entry, reason DropReason)) {
+	if fn == nil {
+		h.onDropped.Store(nil)
+		return
+	}
+	h.onDropped.Store(&fn)
+}
+
+// drop counts entry dropped because of reason and passes it to the callback set by OnDropped.
+func (h *Hook) drop(entry *logrus.Entry, reason DropReason) {
+	if entries := batchEntries(entry); entries[0] != entry {
+		// The entries sent with a single write are dropped together, see FireBatch.
+		for _, entry := range entries {
+			h.drop(entry, reason)
+		}
+		return
+	}
+	atomic.AddUint64(&h.droppedCount, 1)
+	atomic.AddUint64(&h.droppedByReason[reason], 1)
+	h.statsd.count("dropped", 1)
+
+	if fn := h.onDropped.Load(); fn != nil {
+		h.callOnDropped(*fn, entry, reason)
+	}
+}
+
+func (h *Hook) callOnDropped(fn func(*logrus.Entry, DropReason), entry *logrus.Entry, reason DropReason) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Error during handling a dropped message:", r)
+		}
+	}()
+
+	fn(entry, reason)
+}
+
+func (d *dropMetaEntry) loop(h *Hook, closeChan <-chan struct{}) {
+	d.Lock()
+	defer d.Unlock()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.report(h); err != nil {
+				fmt.Println("Error during sending dropped entries to logstash:", err)
+			}
+		case <-closeChan:
+			return
+		}
+	}
+}
+
+// report sends the number of the entries dropped since the previous report, if any.
+func (d *dropMetaEntry) report(h *Hook) error {
+	if h.hasGivenUp() {
+		// The report would be dropped as well, so it waits for Reset.
+		return nil
+	}
+
+	var current [dropReasonCount]uint64
+	var total uint64
+	entry := &logrus.Entry{
+		Time:    time.Now(),
+		Level:   logrus.WarnLevel,
+		Message: "Entries have been dropped by the logstash hook",
+		Data:    make(logrus.Fields, dropReasonCount+1),
+	}
+	for reason := DropReason(0); reason < dropReasonCount; reason++ {
+		current[reason] = atomic.LoadUint64(&h.droppedByReason[reason])
+		count := current[reason] - d.reported[reason]
+		entry.Data["drop_reason_"+dropReasonNames[reason]] = count
+		total += count
+	}
+	if total == 0 {
+		return nil
+	}
+	entry.Data["dropped_count"] = total
+
+	h.inFlight.add()
+	defer h.inFlight.done()
+	if err := h.sendMessage(entry); err != nil {
+		// Reported next time.
+		return err
+	}
+	d.reported = current
+	return nil
+}
@@ -0,0 +1,172 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDropMetaEntry(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "drops_test",
+		WithDropMetaEntry(true),
+		WithDropMetaEntryInterval(time.Hour),
+		WithSamplingRate(logrus.DebugLevel, 0),
+		WithDeduplication(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	// Nothing is reported while nothing is dropped.
+	if err := hook.dropMeta.report(hook); err != nil {
+		t.Fatal(err)
+	}
+	if conn.buff.Len() != 0 {
+		t.Fatalf("expected no messages but got %q", conn.buff.String())
+	}
+
+	fire := func(level logrus.Level, message string) {
+		if err := hook.Fire(&logrus.Entry{Message: message, Level: level, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		fire(logrus.DebugLevel, "sampled")
+	}
+	for i := 0; i < 3; i++ {
+		fire(logrus.InfoLevel, "repeated")
+	}
+	conn.buff.Reset()
+
+	for _, expected := range []map[string]interface{}{
+		{"dropped_count": float64(5), "drop_reason_sampling": float64(3), "drop_reason_deduplication": float64(2), "drop_reason_channel_full": float64(0)},
+		{"dropped_count": float64(1), "drop_reason_sampling": float64(0), "drop_reason_deduplication": float64(1), "drop_reason_channel_full": float64(0)},
+	} {
+		if err := hook.dropMeta.report(hook); err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range expected {
+			if res[k] != v {
+				t.Errorf("expected %s to be '%v' but got '%v'", k, v, res[k])
+			}
+		}
+		if res["type"] != "drops_test" || res["level"] != "warning" {
+			t.Errorf("expected a warning of type 'drops_test' but got '%v' of type '%v'", res["level"], res["type"])
+		}
+		conn.buff.Reset()
+		fire(logrus.InfoLevel, "repeated")
+	}
+}
+
+func TestDropMetaEntryDisabled(t *testing.T) {
+	hook := NewFilterHook(WithDropMetaEntryInterval(time.Millisecond))
+	if hook.dropMetaEntryEnabled() {
+		t.Error("expected the meta entry to be disabled by default")
+	}
+	hook = NewFilterHook(WithDropMetaEntry(true), WithDropMetaEntry(false))
+	if hook.dropMetaEntryEnabled() {
+		t.Error("expected the meta entry to be disabled")
+	}
+}
+
+// droppedEntries records the entries passed to the callback set by OnDropped.
+type droppedEntries struct {
+	sync.Mutex
+	messages []string
+	reasons  []DropReason
+}
+
+func (d *droppedEntries) onDropped(entry *logrus.Entry, reason DropReason) {
+	d.Lock()
+	defer d.Unlock()
+	d.messages = append(d.messages, entry.Message)
+	d.reasons = append(d.reasons, reason)
+}
+
+func (d *droppedEntries) check(t *testing.T, messages []string, reasons []DropReason) {
+	t.Helper()
+	d.Lock()
+	defer d.Unlock()
+	if !reflect.DeepEqual(d.messages, messages) || !reflect.DeepEqual(d.reasons, reasons) {
+		t.Errorf("expected dropped entries %v with reasons %v but got %v with reasons %v", messages, reasons, d.messages, d.reasons)
+	}
+}
+
+func TestOnDropped(t *testing.T) {
+	// Buffer full.
+	conn := blockingConnMock{release: make(chan struct{})}
+	hook := newHook(conn, "drops_test", make(logrus.Fields), "", nil)
+	hook.AsyncBufferSize = 1
+	hook.makeAsync()
+	dropped := &droppedEntries{}
+	hook.OnDropped(dropped.onDropped)
+	for _, message := range []string{"sent", "buffered", "overflow"} {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	dropped.check(t, []string{"overflow"}, []DropReason{DropReasonChannelFull})
+
+	// Shutdown with unflushed entries. Close waits for the write of "sent".
+	closed := make(chan error)
+	go func() { closed <- hook.Close() }()
+	time.Sleep(10 * time.Millisecond)
+	close(conn.release)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	dropped.check(t, []string{"overflow", "buffered"}, []DropReason{DropReasonChannelFull, DropReasonClosed})
+
+	// Retries exhausted.
+	timeoutConn := &timeoutConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(timeoutConn, "drops_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped = &droppedEntries{}
+	hook.OnDropped(dropped.onDropped)
+	hook.Fire(&logrus.Entry{Message: "timeout", Data: logrus.Fields{}})
+	dropped.check(t, []string{"timeout"}, []DropReason{DropReasonWriteFailed})
+
+	// Oversized.
+	hook, err = NewHookWithConn(udpConnMock{ConnMock{buff: bytes.NewBufferString("")}}, "drops_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxDatagramSize = 100
+	dropped = &droppedEntries{}
+	hook.OnDropped(dropped.onDropped)
+	hook.Fire(&logrus.Entry{Message: strings.Repeat("x", 100), Data: logrus.Fields{}})
+	dropped.check(t, []string{strings.Repeat("x", 100)}, []DropReason{DropReasonOversized})
+
+	// A panicking callback doesn't break the hook.
+	hook.OnDropped(func(*logrus.Entry, DropReason) { panic("broken callback") })
+	if err := hook.Fire(&logrus.Entry{Message: strings.Repeat("x", 100), Data: logrus.Fields{}}); err == nil {
+		t.Error("expected fire to fail")
+	}
+	hook.OnDropped(nil)
+	if hook.DroppedCount() != 2 {
+		t.Errorf("expected 2 dropped entries but got %d", hook.DroppedCount())
+	}
+}
+
+func TestDropReasonString(t *testing.T) {
+	if s := DropReasonChannelFull.String(); s != "channel_full" {
+		t.Errorf("expected 'channel_full' but got '%s'", s)
+	}
+	if s := DropReason(100).String(); s != "DropReason(100)" {
+		t.Errorf("expected 'DropReason(100)' but got '%s'", s)
+	}
+}
@@ -0,0 +1,155 @@
+package logrustash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by NewHookFromEnv by default.
+const DefaultEnvPrefix = "LOGRUSTASH"
+
+// envParams maps the names of the environment variables (without the prefix)
+// to the parameters of NewHookFromURL, if they differ.
+var envParams = map[string]string{
+	"APP_NAME":    "app",
+	"BUFFER_SIZE": "buffer",
+}
+
+// NewHookFromEnv creates a new hook configured by the environment variables with prefix
+// (DefaultEnvPrefix if empty) followed by an underscore:
+//
+//	<prefix>_PROTOCOL        the protocol, "tcp" by default
+//	<prefix>_ADDRESS         the address, required
+//	<prefix>_APP_NAME        appName, required
+//	<prefix>_FIELDS          the always sent fields, e.g. "env=prod,region=eu-west-1"
+//	<prefix>_BUFFER_SIZE     the size of the buffer of the async mode; without it the hook is synchronous
+//
+// and the other parameters of NewHookFromURL in upper case, e.g. <prefix>_TIMEOUT or
+// <prefix>_MAX_RECONNECT_RETRIES. Missing and invalid variables are reported all at once.
+// Unknown variables are ignored, since other variables may have the same prefix
+// (e.g. the ones of WithEnvironmentVariableFields). opts are applied after the variables.
+func NewHookFromEnv(prefix string, opts ...Option) (*Hook, error) {
+	c, err := parseHookEnv(prefix, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.newHook(opts)
+}
+
+// parseHookEnv reads the configuration from environ, which has the format of os.Environ.
+func parseHookEnv(prefix string, environ []string) (*hookConfig, error) {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	prefix += "_"
+
+	vars := make(map[string]string)
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, prefix) {
+			continue
+		}
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			vars[kv[len(prefix):i]] = kv[i+1:]
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c := &hookConfig{protocol: "tcp", fields: make(logrus.Fields)}
+	var errs []error
+	for _, required := range []string{"ADDRESS", "APP_NAME"} {
+		if vars[required] == "" {
+			errs = append(errs, fmt.Errorf("%s%s is not set", prefix, required))
+		}
+	}
+	for _, name := range names {
+		value := vars[name]
+		var err error
+		switch name {
+		case "PROTOCOL":
+			if value != "" {
+				c.protocol = value
+			}
+		case "ADDRESS":
+			c.address = value
+		case "FIELDS":
+			err = parseEnvFields(c.fields, value)
+		default:
+			param, ok := envParams[name]
+			if !ok {
+				param = strings.ToLower(name)
+			}
+			if _, ok := hookParams[param]; !ok || isRenamedEnvParam(name) {
+				continue
+			}
+			err = hookParams[param](c, value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value '%s' of %s%s: %w", value, prefix, name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Invalid logstash configuration in the environment: %w", errors.Join(errs...))
+	}
+
+	return c, nil
+}
+
+// isRenamedEnvParam reports whether name is the name of a parameter of NewHookFromURL
+// which has another name in the environment, e.g. "APP" instead of "APP_NAME".
+func isRenamedEnvParam(name string) bool {
+	for envName, param := range envParams {
+		if name != envName && strings.ToLower(name) == param {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnvFields adds the comma-separated key=value pairs of value to fields.
+func parseEnvFields(fields logrus.Fields, value string) error {
+	if value == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return fmt.Errorf("expected key=value but got '%s'", pair)
+		}
+		fields[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+
+	return nil
+}
+
+// WithEnvironmentVariableFields makes the hook send the environment variables whose names start
+// with prefix, e.g. the cluster name or the region set at the start of a container, with every message.
+// The key of a field is the name of its variable without prefix in lower case, e.g. LOGSTASH_SERVICE_NAME
+// becomes "service_name" with the prefix "LOGSTASH_". The environment is read once, when the hook is created;
+// the fields set explicitly (e.g. by NewHookWithFields) are kept.
+func WithEnvironmentVariableFields(prefix string) Option {
+	return func(h *Hook) {
+		for _, kv := range os.Environ() {
+			i := strings.IndexByte(kv, '=')
+			if i <= len(prefix) || !strings.HasPrefix(kv[:i], prefix) {
+				continue
+			}
+			key := strings.ToLower(kv[len(prefix):i])
+			if h.alwaysSentFields == nil {
+				h.alwaysSentFields = make(logrus.Fields)
+			}
+			if _, ok := h.alwaysSentFields[key]; !ok {
+				h.alwaysSentFields[key] = kv[i+1:]
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewHookFromEnv(t *testing.T) {
+	t.Setenv("LOGRUSTASH_PROTOCOL", "udp")
+	t.Setenv("LOGRUSTASH_ADDRESS", "127.0.0.1:9")
+	t.Setenv("LOGRUSTASH_APP_NAME", "checkout")
+
+	hook, err := NewHookFromEnv("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.protocol != "udp" || hook.address != "127.0.0.1:9" || hook.appName != "checkout" {
+		t.Errorf("expected udp://127.0.0.1:9 and app name 'checkout' but got %s://%s and '%s'", hook.protocol, hook.address, hook.appName)
+	}
+	if hook.fireChannel != nil || hook.Timeout != 0 || len(hook.alwaysSentFields) != 0 {
+		t.Error("expected the defaults without the optional variables")
+	}
+	hook.Close()
+
+	t.Setenv("LOGRUSTASH_TIMEOUT", "5s")
+	t.Setenv("LOGRUSTASH_BUFFER_SIZE", "100")
+	t.Setenv("LOGRUSTASH_MAX_RECONNECT_RETRIES", "3")
+	t.Setenv("LOGRUSTASH_PREFIX", "_ls_")
+	t.Setenv("LOGRUSTASH_FIELDS", "env=prod, region=eu-west-1")
+	hook, err = NewHookFromEnv("LOGRUSTASH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if hook.Timeout != 5*time.Second || hook.AsyncBufferSize != 100 || hook.fireChannel == nil || hook.MaxReconnectRetries != 3 || hook.prefix() != "_ls_" {
+		t.Error("expected the variables to override the defaults")
+	}
+	if hook.alwaysSentFields["env"] != "prod" || hook.alwaysSentFields["region"] != "eu-west-1" {
+		t.Errorf("expected the fields env=prod and region=eu-west-1 but got %v", hook.alwaysSentFields)
+	}
+}
+
+func TestParseHookEnv(t *testing.T) {
+	c, err := parseHookEnv("APP_LOGS", []string{"APP_LOGS_ADDRESS=logstash:5000", "APP_LOGS_APP_NAME=api", "LOGRUSTASH_ADDRESS=other:5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.protocol != "tcp" || c.address != "logstash:5000" || c.appName != "api" {
+		t.Errorf("expected tcp://logstash:5000 and app name 'api' but got %s://%s and '%s'", c.protocol, c.address, c.appName)
+	}
+}
+
+func TestParseHookEnvErrors(t *testing.T) {
+	_, err := parseHookEnv("", []string{
+		"LOGRUSTASH_TIMEOUT=5",
+		"LOGRUSTASH_BUFFER_SIZE=-1",
+		"LOGRUSTASH_FIELDS=env",
+		"LOGRUSTASH_APP=api",
+		"LOGRUSTASH_TIMOUT=5s",
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		"LOGRUSTASH_ADDRESS is not set",
+		"LOGRUSTASH_APP_NAME is not set",
+		"invalid value '5' of LOGRUSTASH_TIMEOUT",
+		"invalid value '-1' of LOGRUSTASH_BUFFER_SIZE",
+		"invalid value 'env' of LOGRUSTASH_FIELDS",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain '%s' but got '%s'", expected, err)
+		}
+	}
+	// The unknown variables are ignored.
+	if strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected the unknown variables to be ignored but got '%s'", err)
+	}
+
+	c, err := parseHookEnv("", []string{"LOGRUSTASH_ADDRESS=logstash:5000", "LOGRUSTASH_APP_NAME=api", "LOGRUSTASH_CLUSTER=eu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.address != "logstash:5000" || c.appName != "api" {
+		t.Errorf("expected logstash:5000 and app name 'api' but got %s and '%s'", c.address, c.appName)
+	}
+}
+
+func TestEnvironmentVariableFields(t *testing.T) {
+	t.Setenv("LOGRUSTASH_TEST_SERVICE_NAME", "checkout")
+	t.Setenv("LOGRUSTASH_TEST_REGION", "eu-west-1")
+	t.Setenv("LOGRUSTASH_TEST_", "no key")
+
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	fields := logrus.Fields{"region": "us-east-1"}
+	hook, err := NewHookWithFieldsAndConn(conn, "env_test", fields, WithEnvironmentVariableFields("LOGRUSTASH_TEST_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["service_name"] != "checkout" {
+		t.Errorf("expected service_name to be 'checkout' but got '%s'", res["service_name"])
+	}
+	// The explicit fields are kept.
+	if res["region"] != "us-east-1" {
+		t.Errorf("expected region to be 'us-east-1' but got '%s'", res["region"])
+	}
+	if _, ok := res[""]; ok {
+		t.Error("expected no field with an empty key")
+	}
+	if len(fields) != 1 {
+		t.Errorf("expected the fields of the caller to be left intact but got %v", fields)
+	}
+}
@@ -0,0 +1,159 @@
+package logrustash
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatterError is returned when a message can't be formatted.
+type FormatterError struct {
+	Err error
+}
+
+func (e *FormatterError) Error() string {
+	return fmt.Sprintf("Couldn't format message: %s", e.Err)
+}
+
+// Unwrap returns the error of the formatter.
+func (e *FormatterError) Unwrap() error {
+	return e.Err
+}
+
+// NetworkError is returned when a message can't be sent because of a connection failure.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("Couldn't send message to logstash: %s", e.Err)
+}
+
+// Unwrap returns the error of the connection.
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// MessageTooLargeError is returned when a message doesn't fit into a single datagram.
+// It matches ErrMessageTooLong with errors.Is.
+type MessageTooLargeError struct {
+	Size  int   // Size of the message in bytes.
+	Limit int   // Limit which has been exceeded or zero if the message has been rejected by the OS.
+	Err   error // Error of the connection if the message has been rejected by the OS.
+}
+
+func (e *MessageTooLargeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: message of %d bytes: %s", ErrMessageTooLong, e.Size, e.Err)
+	}
+	return fmt.Sprintf("%s: %d bytes exceed the limit of %d bytes", ErrMessageTooLong, e.Size, e.Limit)
+}
+
+// Unwrap returns the error of the connection.
+func (e *MessageTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrMessageTooLong.
+func (e *MessageTooLargeError) Is(target error) bool {
+	return target == ErrMessageTooLong
+}
+
+// DroppedError describes a message which has been dropped by the hook without being sent
+// after an attempt to send it: when writing it to the writer of a hook created with NewHookWithWriter
+// has failed (a writer can't be reconnected), when it couldn't be sent within MaxRetryElapsedTime
+// (see WithMaxRetryElapsedTime) and when the transport has rejected a record of the write-ahead log
+// (see WithWAL). The entries dropped before sending, e.g. when the buffer of the async mode is full,
+// are reported by OnDropped only.
+type DroppedError struct {
+	Reason string
+	Err    error // Cause of the drop, if any.
+}
+
+func (e *DroppedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Message dropped: %s: %s", e.Reason, e.Err)
+	}
+	return fmt.Sprintf("Message dropped: %s", e.Reason)
+}
+
+// Unwrap returns the cause of the drop.
+func (e *DroppedError) Unwrap() error {
+	return e.Err
+}
+
+// Operation is the operation of the hook which has failed, see OnError.
+type Operation int
+
+const (
+	OperationDial   Operation = iota // connecting to logstash
+	OperationWrite                   // sending a message
+	OperationFormat                  // formatting a message
+	OperationFlush                   // waiting for the messages to be sent in Flush
+)
+
+var operationNames = [...]string{
+	OperationDial:   "dial",
+	OperationWrite:  "write",
+	OperationFormat: "format",
+	OperationFlush:  "flush",
+}
+
+func (op Operation) String() string {
+	if op < 0 || int(op) >= len(operationNames) {
+		return fmt.Sprintf("Operation(%d)", int(op))
+	}
+	return operationNames[op]
+}
+
+// OnError sets fn to be called with every error of op, even if the operation is retried,
+// e.g. to alert on a degraded connection to logstash before any message is lost.
+// fn is called synchronously by the goroutine which has got the error, but never while
+// a connection is locked, and its panics are recovered. fn should be fast or rate-limit itself,
+// since a failing connection may make the hook call it for every message. A nil fn removes the callback.
+func (h *Hook) OnError(fn func(err error, op Operation)) {
+	if fn == nil {
+		h.onError.Store(nil)
+		return
+	}
+	h.onError.Store(&fn)
+}
+
+// reportError passes err of op to the callback set by OnError
+// and records the failures of dialing and writing for Health.
+func (h *Hook) reportError(err error, op Operation) {
+	if op == OperationDial || op == OperationWrite {
+		h.health.failure(h.clock(), err)
+	}
+
+	fn := h.onError.Load()
+	if fn == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Error during handling an error of the hook:", r)
+		}
+	}()
+	(*fn)(err, op)
+}
+
+// warnOnce prints the warning described by format and args, unless a warning with key
+// has already been printed by the hook, so a recurring condition doesn't flood the output.
+func (h *Hook) warnOnce(key string, format string, args ...interface{}) {
+	if _, printed := h.warnings.LoadOrStore(key, struct{}{}); printed {
+		return
+	}
+	fmt.Printf("Warning: "+format+"\n", args...)
+}
+
+// ReservedFieldError is returned by the constructors of a hook if the keys of alwaysSentFields
+// are the keys of the fields set by the hook itself (e.g. "@timestamp" or "type"),
+// whose values would be replaced.
+type ReservedFieldError struct {
+	Keys []string
+}
+
+func (e *ReservedFieldError) Error() string {
+	return fmt.Sprintf("Fields %s are reserved by logstash", strings.Join(e.Keys, ", "))
+}
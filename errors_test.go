@@ -0,0 +1,130 @@
+package logrustash
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFireErrorTypes(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "errors_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{"ch": make(chan int)}})
+	var formatterErr *FormatterError
+	if !errors.As(err, &formatterErr) {
+		t.Errorf("expected a FormatterError but got '%v'", err)
+	}
+
+	timeoutConn := &timeoutConnMock{ConnMock: conn}
+	hook, err = NewHookWithConn(timeoutConn, "errors_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+	var networkErr *NetworkError
+	if !errors.As(err, &networkErr) {
+		t.Errorf("expected a NetworkError but got '%v'", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected the NetworkError to wrap the timeout but got '%v'", err)
+	}
+}
+
+func TestMessageTooLargeError(t *testing.T) {
+	err := error(&MessageTooLargeError{Size: 100, Limit: 10})
+	if !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("expected '%v' to match '%v'", err, ErrMessageTooLong)
+	}
+	if err.Error() != "Message is too long for a datagram: 100 bytes exceed the limit of 10 bytes" {
+		t.Errorf("unexpected error message '%s'", err)
+	}
+}
+
+func TestDroppedError(t *testing.T) {
+	cause := errors.New("buffer is full")
+	err := error(&DroppedError{Reason: "async", Err: cause})
+	if !errors.Is(err, cause) {
+		t.Errorf("expected '%v' to wrap '%v'", err, cause)
+	}
+	if err.Error() != "Message dropped: async: buffer is full" {
+		t.Errorf("unexpected error message '%s'", err)
+	}
+}
+
+func TestOnError(t *testing.T) {
+	var errs []Operation
+	var hook *Hook
+	onError := func(err error, op Operation) {
+		// The callback may use the hook.
+		hook.getConn()
+		errs = append(errs, op)
+	}
+
+	// Write errors, reported for each retry.
+	timeoutConn := &timeoutConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(timeoutConn, "errors_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxSendRetries = 1
+	hook.OnError(onError)
+	hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+	if len(errs) != 2 || errs[0] != OperationWrite || errs[1] != OperationWrite {
+		t.Errorf("expected 2 write errors but got %v", errs)
+	}
+
+	// Dial errors, reported for each reconnect attempt even though the last one succeeds.
+	dials := 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		dials++
+		if dials == 2 || dials == 3 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return brokenConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, writes: new(int)}, nil
+	}
+	hook, err = NewHook("tcp", "logstash:9999", "errors_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxReconnectRetries = 2
+	errs = nil
+	hook.OnError(onError)
+	hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+	if len(errs) < 3 || errs[0] != OperationWrite || errs[1] != OperationDial || errs[2] != OperationDial {
+		t.Errorf("expected a write error followed by 2 dial errors but got %v", errs)
+	}
+
+	// Flush timeouts and panics of the callback.
+	errs = nil
+	hook.OnError(func(err error, op Operation) {
+		onError(err, op)
+		panic(err)
+	})
+	hook.inFlight.add()
+	if err := hook.Flush(time.Millisecond); err != ErrFlushTimeout {
+		t.Errorf("expected '%v' but got '%v'", ErrFlushTimeout, err)
+	}
+	hook.inFlight.done()
+	hook.OnError(nil)
+	if len(errs) != 1 || errs[0] != OperationFlush {
+		t.Errorf("expected a flush error but got %v", errs)
+	}
+}
+
+func TestOperationString(t *testing.T) {
+	if s := OperationDial.String(); s != "dial" {
+		t.Errorf("expected 'dial' but got '%s'", s)
+	}
+	if s := Operation(100).String(); s != "Operation(100)" {
+		t.Errorf("expected 'Operation(100)' but got '%s'", s)
+	}
+}
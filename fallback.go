@@ -0,0 +1,102 @@
+package logrustash
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+const fallbackBufferSize = 1024
+
+// fallbackWriter receives the messages which the hook has failed to send.
+type fallbackWriter struct {
+	sync.Mutex // held by the running loop, so a loop restarted by Reset waits for the previous one
+	w          io.Writer
+	queue      chan []byte
+	count      uint64
+}
+
+// WithFallbackWriter makes the hook write the messages it drops (because the buffer
+// of the async mode is full, sending has failed after all the retries or the hook has
+// given up reconnecting) to w, e.g. os.Stderr or a local file.
+// Writes are performed in the background and never block logging: if w can't keep up,
+// the messages are lost. The number of written messages is returned by FallbackCount.
+func WithFallbackWriter(w io.Writer) Option {
+	return func(h *Hook) {
+		h.fallback = &fallbackWriter{
+			w:     w,
+			queue: make(chan []byte, fallbackBufferSize),
+		}
+		go h.fallback.loop(h.closeChan)
+	}
+}
+
+// FallbackCount returns how many messages have been written to the writer set by WithFallbackWriter.
+func (h *Hook) FallbackCount() uint64 {
+	if h.fallback == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&h.fallback.count)
+}
+
+// fallbackData passes the formatted message data to the fallback writer, if any.
+func (h *Hook) fallbackData(data []byte) {
+	if h.fallback != nil {
+		h.fallback.enqueue(data)
+	}
+}
+
+// fallbackEntry formats entry and passes it to the fallback writer, if any.
+// entry must not be shared with anyone else (see copyEntry).
+func (h *Hook) fallbackEntry(entry *logrus.Entry) {
+	if h.fallback == nil {
+		return
+	}
+
+	data, err := h.formatMessage(entry)
+	if err != nil {
+		return
+	}
+	h.fallback.enqueue(data)
+}
+
+// enqueue never blocks: if the fallback writer can't keep up, the message is dropped.
+func (f *fallbackWriter) enqueue(data []byte) {
+	select {
+	case f.queue <- data:
+	default:
+	}
+}
+
+func (f *fallbackWriter) loop(closeChan <-chan struct{}) {
+	f.Lock()
+	defer f.Unlock()
+
+	for {
+		select {
+		case data := <-f.queue:
+			f.write(data)
+		case <-closeChan:
+			// Write what has been dropped before closing.
+			for {
+				select {
+				case data := <-f.queue:
+					f.write(data)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (f *fallbackWriter) write(data []byte) {
+	if _, err := f.w.Write(data); err != nil {
+		fmt.Println("Error during writing message to the fallback writer:", err)
+		return
+	}
+	atomic.AddUint64(&f.count, 1)
+}
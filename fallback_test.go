@@ -0,0 +1,132 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	sync.Mutex
+	buff bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buff.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buff.String()
+}
+
+// blockingConnMock blocks writes until release is closed.
+type blockingConnMock struct {
+	ConnMock
+	release chan struct{}
+}
+
+func (c blockingConnMock) Write(b []byte) (int, error) {
+	<-c.release
+	return len(b), nil
+}
+
+func waitFallbackCount(t *testing.T, hook *Hook, expected uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.FallbackCount() < expected && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if count := hook.FallbackCount(); count != expected {
+		t.Fatalf("expected %d fallback writes but got %d", expected, count)
+	}
+}
+
+func fallbackMessages(t *testing.T, fallback *syncBuffer) []string {
+	var messages []string
+	dec := json.NewDecoder(strings.NewReader(fallback.String()))
+	for dec.More() {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, res["message"])
+	}
+	return messages
+}
+
+func TestFallbackWriterBufferFull(t *testing.T) {
+	fallback := &syncBuffer{}
+	conn := blockingConnMock{release: make(chan struct{})}
+	hook := newHook(conn, "fallback_test", make(logrus.Fields), "", []Option{WithFallbackWriter(fallback)})
+	hook.AsyncBufferSize = 1
+	hook.makeAsync()
+	defer hook.Close()
+
+	// The first message is stuck in the sender and the second one in the buffer.
+	for _, message := range []string{"sent", "buffered", "overflow 1", "overflow 2"} {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitFallbackCount(t, hook, 2)
+	close(conn.release)
+	if err := hook.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := fallbackMessages(t, fallback)
+	if len(messages) != 2 || messages[0] != "overflow 1" || messages[1] != "overflow 2" {
+		t.Errorf("expected the overflow to be written to the fallback writer but got %v", messages)
+	}
+	if hook.DroppedCount() != 2 {
+		t.Errorf("expected 2 dropped entries but got %d", hook.DroppedCount())
+	}
+}
+
+func TestFallbackWriterServerDown(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := &syncBuffer{}
+	hook, err := NewHookWithConn(conn, "fallback_test", WithFallbackWriter(fallback))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	if err := hook.Fire(&logrus.Entry{Message: "before", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.WaitForEvents(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	server.Stop()
+
+	// The messages written before the reset is noticed are lost, so keep logging until one fails.
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.Fire(&logrus.Entry{Message: "after", Data: logrus.Fields{}}) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected fire to fail after the server is down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitFallbackCount(t, hook, 1)
+	if messages := fallbackMessages(t, fallback); len(messages) != 1 || messages[0] != "after" {
+		t.Errorf("expected the failed message to be written to the fallback writer but got %v", messages)
+	}
+}
@@ -0,0 +1,141 @@
+package logrustash
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// AddressFamily selects the IP addresses of logstash the hook connects to, see WithAddressFamily.
+type AddressFamily int
+
+const (
+	AddressFamilyAuto       AddressFamily = iota // the addresses in the order of the resolver (the default)
+	AddressFamilyIPv4                            // only IPv4 addresses
+	AddressFamilyIPv6                            // only IPv6 addresses
+	AddressFamilyPreferIPv4                      // IPv4 addresses before IPv6 ones
+)
+
+// ipResolver resolves the host of the address of logstash. It is implemented by *net.Resolver.
+type ipResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// WithAddressFamily sets which IP addresses of the host of logstash the TCP and UDP connections use
+// when it resolves to both IPv4 and IPv6 ones. Each attempt to connect tries the addresses one by one,
+// so if the first one (e.g. IPv6 which is broken in the network) fails, the next one (e.g. IPv4) is used.
+// DialTimeout limits the whole attempt: each address gets an equal part of the time that is left.
+func WithAddressFamily(family AddressFamily) Option {
+	return func(h *Hook) {
+		h.addressFamily = family
+	}
+}
+
+func isIPProtocol(protocol string) bool {
+	switch protocol {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+		return true
+	default:
+		return false
+	}
+}
+
+// dialResolved resolves the host of address and dials its addresses allowed by the address family
+// of the hook until a connection is established. It returns the error of the first address otherwise.
+func (h *Hook) dialResolved(protocol, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return h.dialIP(protocol, address, h.DialTimeout)
+	}
+	var deadline time.Time
+	if h.DialTimeout > 0 {
+		deadline = time.Now().Add(h.DialTimeout)
+	}
+	ips, err := h.resolveHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, len(ips))
+	for i, ip := range ips {
+		addresses[i] = net.JoinHostPort(ip.String(), port)
+	}
+	return dialAddresses(addresses, deadline, func(address string, timeout time.Duration) (net.Conn, error) {
+		return h.dialIP(protocol, address, timeout)
+	})
+}
+
+// dialAddresses dials addresses one by one until a connection is established. If deadline is not zero,
+// each attempt gets an equal part of the time left until it (like net.Dialer does), so a host with many
+// unreachable addresses doesn't take DialTimeout for each of them. It returns the error of the first address otherwise.
+func dialAddresses(addresses []string, deadline time.Time, dial func(address string, timeout time.Duration) (net.Conn, error)) (net.Conn, error) {
+	var firstErr error
+	for i, address := range addresses {
+		var timeout time.Duration
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("DialTimeout exceeded before dialing %s", address)
+				}
+				break
+			}
+			timeout = remaining / time.Duration(len(addresses)-i)
+		}
+		conn, err := dial(address, timeout)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// resolveHost returns the IP addresses of host allowed by the address family of the hook, in the order to dial them.
+func (h *Hook) resolveHost(host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		ctx := context.Background()
+		if h.DialTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.DialTimeout)
+			defer cancel()
+		}
+		resolver := h.resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	filtered := ips[:0:0]
+	for _, ip := range ips {
+		isIPv4 := ip.To4() != nil
+		if (h.addressFamily == AddressFamilyIPv4 && !isIPv4) || (h.addressFamily == AddressFamilyIPv6 && isIPv4) {
+			continue
+		}
+		filtered = append(filtered, ip)
+	}
+	if h.addressFamily == AddressFamilyPreferIPv4 {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].To4() != nil && filtered[j].To4() == nil
+		})
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("No addresses of the family allowed by WithAddressFamily for %s: %v", host, ips)
+	}
+
+	return filtered, nil
+}
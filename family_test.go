@@ -0,0 +1,123 @@
+package logrustash
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+// resolverMock resolves every host to an IPv6 and an IPv4 loopback address.
+type resolverMock struct{}
+
+func (resolverMock) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.IPv6loopback}, {IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+func TestAddressFamily(t *testing.T) {
+	// Logstash listens only on IPv4.
+	server := logrustashtest.NewServer(t, "tcp")
+	_, port, _ := net.SplitHostPort(server.Addr())
+	address := net.JoinHostPort("logstash.test", port)
+
+	tt := map[AddressFamily]bool{
+		AddressFamilyAuto:       true, // falls back to IPv4 after IPv6 fails
+		AddressFamilyIPv4:       true,
+		AddressFamilyIPv6:       false,
+		AddressFamilyPreferIPv4: true,
+	}
+	for family, connects := range tt {
+		withResolver := func(h *Hook) { h.resolver = resolverMock{} }
+		hook, err := NewHook("tcp", address, "family_test", withResolver, WithAddressFamily(family))
+		if !connects {
+			if err == nil {
+				t.Errorf("%d: expected the hook not to connect", family)
+				hook.Close()
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %s", family, err)
+			continue
+		}
+		if host, _, _ := net.SplitHostPort(hook.getConn().RemoteAddr().String()); host != "127.0.0.1" {
+			t.Errorf("%d: expected a connection to 127.0.0.1 but got %s", family, host)
+		}
+		hook.Close()
+	}
+}
+
+func TestAddressFamilyOrder(t *testing.T) {
+	tt := map[AddressFamily]string{
+		AddressFamilyAuto:       "[::1 127.0.0.1]",
+		AddressFamilyIPv4:       "[127.0.0.1]",
+		AddressFamilyIPv6:       "[::1]",
+		AddressFamilyPreferIPv4: "[127.0.0.1 ::1]",
+	}
+	for family, expected := range tt {
+		hook := &Hook{addressFamily: family, resolver: resolverMock{}}
+		ips, err := hook.resolveHost("logstash.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(ips) != expected {
+			t.Errorf("%d: expected the addresses %s but got %v", family, expected, ips)
+		}
+	}
+
+	// An address literal isn't resolved.
+	hook := &Hook{addressFamily: AddressFamilyIPv4, resolver: resolverMock{}}
+	if _, err := hook.resolveHost("::1"); err == nil {
+		t.Error("expected an error for an IPv6 address with AddressFamilyIPv4")
+	}
+}
+
+func TestDialAddressesTimeout(t *testing.T) {
+	// Every address is unreachable: the attempts share the time until the deadline.
+	var timeouts []time.Duration
+	dialTimeout := 300 * time.Millisecond
+	start := time.Now()
+	_, err := dialAddresses([]string{"a", "b", "c"}, start.Add(dialTimeout), func(address string, timeout time.Duration) (net.Conn, error) {
+		timeouts = append(timeouts, timeout)
+		time.Sleep(timeout)
+		return nil, fmt.Errorf("%s is unreachable", address)
+	})
+	if err == nil || err.Error() != "a is unreachable" {
+		t.Errorf("expected the error of the first address but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > dialTimeout+100*time.Millisecond {
+		t.Errorf("expected dialing to take about %s but it took %s", dialTimeout, elapsed)
+	}
+	if len(timeouts) != 3 || timeouts[0] > dialTimeout/3 {
+		t.Errorf("expected 3 attempts with at most %s each but got %v", dialTimeout/3, timeouts)
+	}
+
+	// An address that fails fast leaves its time to the next ones.
+	timeouts = nil
+	conn, err := dialAddresses([]string{"a", "b"}, time.Now().Add(dialTimeout), func(address string, timeout time.Duration) (net.Conn, error) {
+		timeouts = append(timeouts, timeout)
+		if address == "a" {
+			return nil, fmt.Errorf("%s is unreachable", address)
+		}
+		return &ConnMock{}, nil
+	})
+	if err != nil || conn == nil {
+		t.Fatalf("expected a connection to the second address but got %v", err)
+	}
+	if len(timeouts) != 2 || timeouts[1] < dialTimeout*9/10 {
+		t.Errorf("expected the second attempt to get almost %s but got %v", dialTimeout, timeouts)
+	}
+
+	// Without a deadline every attempt uses the default timeout.
+	timeouts = nil
+	_, _ = dialAddresses([]string{"a", "b"}, time.Time{}, func(address string, timeout time.Duration) (net.Conn, error) {
+		timeouts = append(timeouts, timeout)
+		return nil, fmt.Errorf("%s is unreachable", address)
+	})
+	if fmt.Sprint(timeouts) != "[0s 0s]" {
+		t.Errorf("expected no timeouts but got %v", timeouts)
+	}
+}
@@ -0,0 +1,184 @@
+package logrustash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrFlushTimeout is returned by Flush if the messages haven't been sent within the timeout.
+var ErrFlushTimeout = errors.New("Timeout while flushing messages to logstash")
+
+// closedChan is returned by inFlight.idle when there are no messages in flight.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// inFlight counts the messages which have been fired but not sent yet.
+// The zero value is ready to use.
+type inFlight struct {
+	sync.Mutex
+	count  int
+	idleCh chan struct{} // closed when count drops to zero
+}
+
+func (f *inFlight) add() {
+	f.Lock()
+	defer f.Unlock()
+	if f.count == 0 {
+		f.idleCh = make(chan struct{})
+	}
+	f.count++
+}
+
+func (f *inFlight) done() {
+	f.Lock()
+	defer f.Unlock()
+	f.count--
+	if f.count == 0 {
+		close(f.idleCh)
+	}
+}
+
+// idle returns a channel which is closed when there are no messages in flight.
+func (f *inFlight) idle() <-chan struct{} {
+	f.Lock()
+	defer f.Unlock()
+	if f.count == 0 {
+		return closedChan
+	}
+	return f.idleCh
+}
+
+// Flush waits until all the messages fired before have been sent (or failed to be sent),
+// including the messages in the buffer of the async mode. Zero timeout means no limit.
+// It returns ErrFlushTimeout if the timeout expires, ErrHookClosed if the hook is closed
+// while waiting and ErrHookPaused if the hook is paused (see Pause) with messages left to send.
+func (h *Hook) Flush(timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := h.FlushContext(ctx)
+	if err == context.DeadlineExceeded {
+		return ErrFlushTimeout
+	}
+	return err
+}
+
+// FlushContext is like Flush, but waits until ctx is done instead of a timeout,
+// in which case it returns ctx.Err(). It may be called concurrently.
+func (h *Hook) FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if h.parent != nil {
+		// A child hook sends its messages through its parent.
+		return h.parent.FlushContext(ctx)
+	}
+
+	_, closeChan := h.channels()
+	for {
+		paused, changed := h.pause.state()
+		idle := h.inFlight.idle()
+		if paused {
+			select {
+			case <-idle:
+			default:
+				// The messages won't be sent until the hook is resumed.
+				return ErrHookPaused
+			}
+		}
+
+		select {
+		case <-changed:
+			continue
+		case <-idle:
+			if flusher, ok := h.sender().(transportFlusher); ok {
+				if err := flusher.Flush(ctx); err != nil {
+					h.reportError(err, OperationFlush)
+					return err
+				}
+			}
+			return h.flushMirror(ctx)
+		case <-ctx.Done():
+			h.reportError(ctx.Err(), OperationFlush)
+			return ctx.Err()
+		case <-closeChan:
+			return ErrHookClosed
+		}
+	}
+}
+
+// periodicFlush sends the partial batches of the transport of a hook periodically, see WithPeriodicFlush.
+type periodicFlush struct {
+	sync.Mutex // held by the running loop, so a loop restarted by Reset waits for the previous one
+	interval   time.Duration
+}
+
+// WithPeriodicFlush makes the hook send the partial batch of a transport which sends the messages
+// in batches (e.g. WithCloudWatchLogsTransport) every interval, so the messages don't wait in it
+// for long when few of them are fired. The background goroutine is stopped by Close,
+// which waits for it to finish the flush in progress, if any.
+// The transports which send each message right away aren't affected.
+func WithPeriodicFlush(interval time.Duration) Option {
+	return func(h *Hook) {
+		h.periodicFlush = &periodicFlush{interval: interval}
+	}
+}
+
+func (h *Hook) periodicFlushEnabled() bool {
+	return h.periodicFlush != nil && h.periodicFlush.interval > 0
+}
+
+func (f *periodicFlush) loop(h *Hook, closeChan <-chan struct{}) {
+	f.Lock()
+	defer f.Unlock()
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case <-closeChan:
+				// Both are ready, but the hook has been closed.
+				return
+			default:
+			}
+			if err := h.flushBatch(); err != nil {
+				fmt.Println("Error during flushing messages to logstash:", err)
+			}
+		case <-closeChan:
+			return
+		}
+	}
+}
+
+// flushBatch sends the partial batch of the transport, if it sends the messages in batches.
+// The batch is kept while the hook is paused.
+func (h *Hook) flushBatch() error {
+	flusher, ok := h.sender().(transportFlusher)
+	if !ok || h.Paused() {
+		return nil
+	}
+
+	ctx := context.Background()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	if err := flusher.Flush(ctx); err != nil {
+		h.reportError(err, OperationFlush)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package logrustash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slowConnMock writes 10 bytes at a time with a delay before each write.
+type slowConnMock struct {
+	sync.Mutex
+	buff  bytes.Buffer
+	delay time.Duration
+}
+
+func (c *slowConnMock) Write(b []byte) (int, error) {
+	time.Sleep(c.delay)
+	if len(b) > 10 {
+		b = b[:10]
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.buff.Write(b)
+}
+
+func (c *slowConnMock) Bytes() []byte {
+	c.Lock()
+	defer c.Unlock()
+	return append([]byte(nil), c.buff.Bytes()...)
+}
+
+func (c *slowConnMock) Read(b []byte) (int, error)         { return 0, nil }
+func (c *slowConnMock) Close() error                       { return nil }
+func (c *slowConnMock) LocalAddr() net.Addr                { return AddrMock{} }
+func (c *slowConnMock) RemoteAddr() net.Addr               { return AddrMock{} }
+func (c *slowConnMock) SetDeadline(t time.Time) error      { return nil }
+func (c *slowConnMock) SetReadDeadline(t time.Time) error  { return nil }
+func (c *slowConnMock) SetWriteDeadline(t time.Time) error { return nil }
+
+func TestFlush(t *testing.T) {
+	conn := &slowConnMock{delay: time.Millisecond}
+	hook, err := NewAsyncHookWithConn(conn, "flush_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.WaitUntilBufferFrees = true
+
+	for i := 0; i < 3; i++ {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{"i": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	data := conn.Bytes()
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		t.Fatalf("expected flush to wait for the last byte but got %q", data)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for i := 0; i < 3; i++ {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["i"] != float64(i) {
+			t.Errorf("expected i to be '%d' but got '%v'", i, res["i"])
+		}
+	}
+
+	// Nothing to flush.
+	if err := hook.Flush(time.Millisecond); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFlushTimeout(t *testing.T) {
+	conn := &slowConnMock{delay: 100 * time.Millisecond}
+	hook, err := NewAsyncHookWithConn(conn, "flush_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.WaitUntilBufferFrees = true
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(10 * time.Millisecond); err != ErrFlushTimeout {
+		t.Errorf("expected error to be '%v' but got '%v'", ErrFlushTimeout, err)
+	}
+}
+
+func TestFlushContext(t *testing.T) {
+	conn := &slowConnMock{delay: 100 * time.Millisecond}
+	hook, err := NewAsyncHookWithConn(conn, "flush_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.WaitUntilBufferFrees = true
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := hook.FlushContext(cancelled); err != context.Canceled {
+		t.Errorf("expected error to be '%v' but got '%v'", context.Canceled, err)
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancellation in the middle of draining.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- hook.FlushContext(ctx)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("expected error to be '%v' but got '%v'", context.Canceled, err)
+		}
+	}
+
+	// Concurrent flushes complete together.
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- hook.FlushContext(context.Background())
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if !bytes.HasSuffix(conn.Bytes(), []byte("\n")) {
+		t.Errorf("expected flush to wait for the last byte but got %q", conn.Bytes())
+	}
+}
+
+func TestPeriodicFlush(t *testing.T) {
+	client := &cloudWatchLogsClientMock{}
+	hook, err := NewHook("", "", "flush_test", WithCloudWatchLogsTransport(client, "group", "stream"),
+		WithPeriodicFlush(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.transport.(*cloudWatchLogsTransport).flushInterval = time.Hour // only the periodic flush sends the batch
+
+	if err := hook.Fire(&logrus.Entry{Message: "partial", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.batchSizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sizes := client.batchSizes(); len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("expected the partial batch to be sent by the periodic flush but got batches %v", sizes)
+	}
+
+	// Close stops the periodic flush: the batch of a closed hook stays unsent.
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client.Lock()
+	client.err = errors.New("unavailable")
+	client.Unlock()
+	transport := hook.transport.(*cloudWatchLogsTransport)
+	if err := transport.Send(context.Background(), []byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	client.Lock()
+	client.err = nil
+	client.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if sizes := client.batchSizes(); len(sizes) != 1 {
+		t.Errorf("expected no batches to be sent after Close but got batches %v", sizes)
+	}
+}
@@ -0,0 +1,79 @@
+package logrustash
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type framingKind int
+
+const (
+	framingNewline framingKind = iota
+	framingLengthPrefixBE32
+	framingDelimiter
+	framingNone
+)
+
+// Framing tells how the messages are separated from each other in the stream sent to logstash
+// (see WithFraming). The zero value is FramingNewline.
+type Framing struct {
+	kind      framingKind
+	delimiter byte
+}
+
+var (
+	// FramingNewline terminates each message with a newline, as expected by the json_lines codec.
+	FramingNewline = Framing{kind: framingNewline}
+
+	// FramingLengthPrefixBE32 prefixes each message with its length as a big-endian uint32.
+	FramingLengthPrefixBE32 = Framing{kind: framingLengthPrefixBE32}
+
+	// FramingNone sends the bare messages, e.g. over UDP where the datagram is the frame.
+	FramingNone = Framing{kind: framingNone}
+)
+
+// FramingDelimiter terminates each message with delimiter, e.g. 0 for the messages
+// read with the line codec of Logstash with delimiter => "\u0000".
+func FramingDelimiter(delimiter byte) Framing {
+	return Framing{kind: framingDelimiter, delimiter: delimiter}
+}
+
+func (f Framing) String() string {
+	switch f.kind {
+	case framingNewline:
+		return "newline"
+	case framingLengthPrefixBE32:
+		return "length_prefix_be32"
+	case framingDelimiter:
+		return fmt.Sprintf("delimiter(%#02x)", f.delimiter)
+	case framingNone:
+		return "none"
+	}
+	return fmt.Sprintf("Framing(%d)", int(f.kind))
+}
+
+// frame returns the serialized message data framed with f.
+func (f Framing) frame(data []byte) []byte {
+	switch f.kind {
+	case framingNewline:
+		return append(data, '\n')
+	case framingLengthPrefixBE32:
+		framed := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(framed, uint32(len(data)))
+		return append(framed, data...)
+	case framingDelimiter:
+		return append(data, f.delimiter)
+	}
+	return data
+}
+
+// WithFraming sets how the messages are framed in the stream sent to logstash
+// (FramingNewline by default). The framing is applied once to each serialized message,
+// so it is also what is written to the write-ahead log and passed to a Transport.
+// The protocols with their own framing (lumberjack, kafka and CloudWatch Logs)
+// expect FramingNewline or FramingNone.
+func WithFraming(framing Framing) Option {
+	return func(h *Hook) {
+		h.framing = framing
+	}
+}
@@ -0,0 +1,111 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFraming(t *testing.T) {
+	messages := []string{"first", "second", "third"}
+	tests := []struct {
+		framing Framing
+		decode  func(t *testing.T, data []byte) [][]byte
+	}{
+		{FramingNewline, func(t *testing.T, data []byte) [][]byte {
+			frames := bytes.SplitAfter(data, []byte("\n"))
+			if last := frames[len(frames)-1]; len(last) != 0 {
+				t.Errorf("expected the last message to end with a newline but got %q", last)
+			}
+			return frames[:len(frames)-1]
+		}},
+		{FramingDelimiter(0), func(t *testing.T, data []byte) [][]byte {
+			if !bytes.HasSuffix(data, []byte{0}) || bytes.Contains(data, []byte("\n")) {
+				t.Errorf("expected only NUL delimiters but got %q", data)
+			}
+			return bytes.Split(bytes.TrimSuffix(data, []byte{0}), []byte{0})
+		}},
+		{FramingLengthPrefixBE32, func(t *testing.T, data []byte) [][]byte {
+			var frames [][]byte
+			for len(data) > 0 {
+				if len(data) < 4 {
+					t.Fatalf("expected a length prefix but got %q", data)
+				}
+				n := binary.BigEndian.Uint32(data)
+				if int(n) > len(data)-4 {
+					t.Fatalf("the length prefix %d exceeds the %d bytes left", n, len(data)-4)
+				}
+				frames = append(frames, data[4:4+n])
+				data = data[4+n:]
+			}
+			return frames
+		}},
+	}
+
+	for _, test := range tests {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook, err := NewHookWithConn(conn, "framing_test", WithFraming(test.framing))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, message := range messages {
+			if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		frames := test.decode(t, conn.buff.Bytes())
+		if len(frames) != len(messages) {
+			t.Fatalf("%v: expected %d frames but got %d", test.framing, len(messages), len(frames))
+		}
+		for i, frame := range frames {
+			if test.framing == FramingNewline {
+				// No delimiter is added on top of the newline.
+				if bytes.Count(frame, []byte("\n")) != 1 {
+					t.Errorf("%v: expected exactly one newline but got %q", test.framing, frame)
+				}
+			} else if bytes.Contains(frame, []byte("\n")) {
+				t.Errorf("%v: expected no newline but got %q", test.framing, frame)
+			}
+			var res map[string]string
+			if err := json.Unmarshal(frame, &res); err != nil {
+				t.Fatalf("%v: %v in %q", test.framing, err, frame)
+			}
+			if res["message"] != messages[i] {
+				t.Errorf("%v: expected message '%s' but got '%s'", test.framing, messages[i], res["message"])
+			}
+		}
+	}
+}
+
+func TestFramingNone(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "framing_test", WithFraming(FramingNone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message is sent as is, e.g. as a datagram.
+	var res map[string]string
+	if err := json.Unmarshal(conn.buff.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if data := conn.buff.Bytes(); data[len(data)-1] != '}' {
+		t.Errorf("expected bare JSON but got %q", data)
+	}
+
+	// The formatter used on its own terminates the message with a newline, like the other logrus formatters.
+	formatted, err := (&LogstashFormatter{}).Format(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(formatted, []byte("}\n")) {
+		t.Errorf("expected JSON terminated with a single newline but got %q", formatted)
+	}
+}
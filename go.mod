@@ -2,9 +2,25 @@ module github.com/xaionaro-go/logrustash
 
 go 1.22
 
-require github.com/sirupsen/logrus v1.9.3
+require (
+	github.com/facebookincubator/go-belt v0.0.0-20250308011339-62fb7027b11f
+	github.com/sirupsen/logrus v1.9.3
+	go.opentelemetry.io/otel/trace v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/go-ng/slices v0.0.0-20230703171042-6195d35636a2 // indirect
+	github.com/go-ng/sort v0.0.0-20220617173827-2cc7cd04f7c7 // indirect
+	github.com/go-ng/xsort v0.0.0-20220617174223-1d146907bccc // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel v1.31.0 // indirect
+	golang.org/x/exp v0.0.0-20230519143937-03e91628a987 // indirect
+)
 
 require (
 	github.com/xaionaro-go/goautosocket v0.0.0-20240803221104-cef7f165571a
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/time v0.5.0
 )
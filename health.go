@@ -0,0 +1,73 @@
+package logrustash
+
+import (
+	"sync"
+	"time"
+)
+
+// Health describes how well the hook delivers the messages to logstash, e.g. for a readiness probe.
+type Health struct {
+	Connected           bool      // see ConnectionState
+	Address             string    // the address of logstash
+	LastSuccess         time.Time // when a message has been sent the last time, zero if never
+	LastFailure         time.Time // when dialing or writing has failed the last time, zero if never
+	LastError           string    // the error of the last failure
+	ConsecutiveFailures int       // the failures since the last message has been sent
+	QueueUtilization    float64   // the used part of the buffer of the async mode, from 0 to 1
+}
+
+// healthState tracks the results of the sends and the dials for Health.
+// The zero value is ready to use.
+type healthState struct {
+	sync.Mutex
+	lastSuccess         time.Time
+	lastFailure         time.Time
+	lastError           string
+	consecutiveFailures int
+}
+
+func (s *healthState) success(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.lastSuccess = now
+	s.consecutiveFailures = 0
+}
+
+func (s *healthState) failure(now time.Time, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.lastFailure = now
+	s.lastError = err.Error()
+	s.consecutiveFailures++
+}
+
+// Health returns the current health of the hook. It's cheap and may be called concurrently,
+// e.g. from an HTTP handler.
+func (h *Hook) Health() Health {
+	if h.parent != nil {
+		// A child hook sends its messages through its parent.
+		return h.parent.Health()
+	}
+
+	h.health.Lock()
+	health := Health{
+		LastSuccess:         h.health.lastSuccess,
+		LastFailure:         h.health.lastFailure,
+		LastError:           h.health.lastError,
+		ConsecutiveFailures: h.health.consecutiveFailures,
+	}
+	h.health.Unlock()
+
+	health.Connected = h.ConnectionState() == StateConnected
+	_, health.Address = h.target()
+	if conn := h.getConn(); health.Address == "" && conn != nil {
+		// The hook has been created with a connection, which may have no address.
+		if addr := conn.RemoteAddr(); addr != nil {
+			health.Address = addr.String()
+		}
+	}
+	if fireChannel, _ := h.channels(); cap(fireChannel) > 0 {
+		health.QueueUtilization = float64(len(fireChannel)) / float64(cap(fireChannel))
+	}
+	return health
+}
@@ -0,0 +1,121 @@
+package logrustash
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestHealth(t *testing.T) {
+	fail := false
+	writes := 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		if fail {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return ConnMock{buff: bytes.NewBufferString("")}, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "health_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.MaxReconnectRetries = 1
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	hook.now = func() time.Time { return now }
+	fire := func() error {
+		return hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+	}
+
+	if health := hook.Health(); !health.Connected || health.Address != "logstash:9999" || !health.LastSuccess.IsZero() {
+		t.Errorf("expected a connected hook without sent messages but got %+v", health)
+	}
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	if health := hook.Health(); !health.LastSuccess.Equal(now) || health.ConsecutiveFailures != 0 {
+		t.Errorf("expected the last success at %v but got %+v", now, health)
+	}
+
+	// The connection breaks and logstash can't be reached.
+	now = now.Add(time.Minute)
+	hook.setConn(brokenConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, writes: &writes})
+	fail = true
+	if err := fire(); err == nil {
+		t.Fatal("expected fire to fail")
+	}
+	health := hook.Health()
+	if health.Connected || !health.LastFailure.Equal(now) || health.LastError != "connection refused" {
+		t.Errorf("expected a disconnected hook with the last failure 'connection refused' at %v but got %+v", now, health)
+	}
+	if health.ConsecutiveFailures != 3 {
+		t.Errorf("expected 3 consecutive failures (a write and 2 dials) but got %d", health.ConsecutiveFailures)
+	}
+
+	// The hook reconnects.
+	now = now.Add(time.Minute)
+	fail = false
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	health = hook.Health()
+	if !health.Connected || !health.LastSuccess.Equal(now) || health.ConsecutiveFailures != 0 {
+		t.Errorf("expected a connected hook with the last success at %v but got %+v", now, health)
+	}
+	if !health.LastFailure.Equal(now) || health.LastError == "" {
+		t.Errorf("expected the last failure to be kept but got %+v", health)
+	}
+}
+
+func TestHealthQueueUtilization(t *testing.T) {
+	hook, err := NewAsyncHookWithTransport(logrustashtest.NewTransport(), "health_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	// The entries stay in the buffer while the hook is paused.
+	hook.Pause()
+	for i := 0; i < hook.AsyncBufferSize/4; i++ {
+		if err := hook.Fire(&logrus.Entry{Message: "queued", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if utilization := hook.Health().QueueUtilization; utilization != 0.25 {
+		t.Errorf("expected the queue utilization to be 0.25 but got %v", utilization)
+	}
+	hook.Resume()
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if utilization := hook.Health().QueueUtilization; utilization != 0 {
+		t.Errorf("expected the queue to be empty but got utilization %v", utilization)
+	}
+}
+
+// noAddrConn is a connection without an address, like some connections of factories.
+type noAddrConn struct {
+	ConnMock
+}
+
+func (noAddrConn) RemoteAddr() net.Addr {
+	return nil
+}
+
+func TestHealthWithoutRemoteAddr(t *testing.T) {
+	hook, err := NewHookWithConn(noAddrConn{ConnMock{buff: bytes.NewBufferString("")}}, "health_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	if health := hook.Health(); !health.Connected || health.Address != "" {
+		t.Errorf("expected a connected hook without an address but got %+v", health)
+	}
+}
@@ -0,0 +1,88 @@
+package logrustash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// WithHTTPClient sets the client used by the "http" and "https" protocols.
+// Default: http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(h *Hook) {
+		h.httpClient = client
+	}
+}
+
+// HTTPStatusError is returned when the Logstash http input responds with a non-2xx status.
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("Logstash responded with %s", e.Status)
+}
+
+// retryableError marks an error as temporary, so the message is resent.
+type retryableError struct {
+	error
+}
+
+func (e retryableError) Timeout() bool   { return false }
+func (e retryableError) Temporary() bool { return true }
+func (e retryableError) Unwrap() error   { return e.error }
+
+func isHTTP(protocol string) bool {
+	return protocol == "http" || protocol == "https"
+}
+
+// httpTransport is the Transport of the "http" and "https" protocols, which POSTs each message
+// to the http input of Logstash at `protocol`://`address` of the hook. Failed requests are not
+// retried by the transport itself: server errors (5xx), 429 and transport errors are reported
+// as temporary errors, so the message is resent according to MaxSendRetries after the delays
+// of WithRetryBackoff, while other statuses are reported as permanent errors.
+type httpTransport struct {
+	h *Hook
+}
+
+// Send sends data as the body of a single request.
+func (t httpTransport) Send(ctx context.Context, data []byte) error {
+	client := t.h.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	protocol, address := t.h.target()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, protocol+"://"+address, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	// Drain the body, so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	statusErr := &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return retryableError{statusErr}
+	}
+
+	return statusErr
+}
+
+// Close does nothing: the client of the transport isn't owned by the hook.
+func (t httpTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,134 @@
+package logrustash
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type logstashHTTPInput struct {
+	sync.Mutex
+	statuses []int // returned in order, then 200
+	messages []string
+	requests int
+}
+
+func (s *logstashHTTPInput) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests++
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var res map[string]string
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.messages = append(s.messages, res["message"])
+}
+
+func newHTTPHook(t *testing.T, server *httptest.Server) *Hook {
+	address := strings.TrimPrefix(server.URL, "http://") + "/logs"
+	hook, err := NewHook("http", address, "http_test", WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+func TestHTTP(t *testing.T) {
+	input := &logstashHTTPInput{}
+	server := httptest.NewServer(input)
+	defer server.Close()
+	hook := newHTTPHook(t, server)
+
+	for _, message := range []string{"first", "second"} {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Error(err)
+		}
+	}
+	if len(input.messages) != 2 || input.messages[0] != "first" || input.messages[1] != "second" {
+		t.Errorf("expected messages to be [first second] but got %v", input.messages)
+	}
+}
+
+func TestHTTPRetryableFailure(t *testing.T) {
+	input := &logstashHTTPInput{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(input)
+	defer server.Close()
+	hook := newHTTPHook(t, server)
+	hook.MaxSendRetries = 2
+
+	if err := hook.Fire(&logrus.Entry{Message: "retried", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	if input.requests != 3 {
+		t.Errorf("expected 3 requests but got %d", input.requests)
+	}
+	if len(input.messages) != 1 || input.messages[0] != "retried" {
+		t.Errorf("expected messages to be [retried] but got %v", input.messages)
+	}
+}
+
+func TestHTTPRetryBackoff(t *testing.T) {
+	input := &logstashHTTPInput{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(input)
+	defer server.Close()
+	hook := newHTTPHook(t, server)
+	hook.MaxSendRetries = 2
+	hook.RetryBaseDelay = time.Second
+	var delays []time.Duration
+	hook.after = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "retried", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	if len(delays) != 2 || delays[0] != time.Second || delays[1] != 2*time.Second {
+		t.Errorf("expected the resends to wait for [1s 2s] but got %v", delays)
+	}
+	if len(input.messages) != 1 || input.messages[0] != "retried" {
+		t.Errorf("expected messages to be [retried] but got %v", input.messages)
+	}
+}
+
+func TestHTTPPermanentFailure(t *testing.T) {
+	input := &logstashHTTPInput{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(input)
+	defer server.Close()
+	hook := newHTTPHook(t, server)
+	hook.MaxSendRetries = 2
+	hook.MaxReconnectRetries = 2
+
+	err := hook.Fire(&logrus.Entry{Message: "rejected", Data: logrus.Fields{}})
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a HTTPStatusError with status 400 but got '%v'", err)
+	}
+	if input.requests != 1 {
+		t.Errorf("expected 1 request but got %d", input.requests)
+	}
+	if n := hook.droppedByReason[DropReasonWriteFailed]; n != 1 {
+		t.Errorf("expected the rejected message to be dropped with reason write_failed but got %d", n)
+	}
+}
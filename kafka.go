@@ -0,0 +1,184 @@
+package logrustash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KafkaProducer publishes messages to Kafka. Implement it on top of the client of your choice,
+// e.g. the Writer of github.com/segmentio/kafka-go.
+//
+// Produce returns the partition the message has been (or would have been) published to,
+// or -1 if it is unknown. Errors with a Temporary method returning true make the hook
+// resend the message (see MaxSendRetries), other errors make it create a new producer
+// (see MaxReconnectRetries).
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) (partition int, err error)
+	Close() error
+}
+
+// WithKafkaProducer sets the function which creates producers for the "kafka" protocol.
+// The address of the hook has the form "broker1:9092,broker2:9092/topic".
+func WithKafkaProducer(newProducer func(brokers []string, topic string) (KafkaProducer, error)) Option {
+	return func(h *Hook) {
+		h.kafkaProducerFactory = newProducer
+		h.kafkaStats = &kafkaStats{partitionErrors: make(map[int]uint64)}
+	}
+}
+
+// ErrNoKafkaProducer is returned when the "kafka" protocol is used without WithKafkaProducer.
+var ErrNoKafkaProducer = errors.New("Kafka protocol requires WithKafkaProducer")
+
+// kafkaStats counts the failed deliveries per partition.
+type kafkaStats struct {
+	sync.Mutex
+	partitionErrors map[int]uint64
+}
+
+func (s *kafkaStats) addError(partition int) {
+	s.Lock()
+	defer s.Unlock()
+	s.partitionErrors[partition]++
+}
+
+// KafkaPartitionErrors returns how many messages have failed to be published
+// to each partition (-1 for errors without a known partition).
+func (h *Hook) KafkaPartitionErrors() map[int]uint64 {
+	res := make(map[int]uint64)
+	if h.kafkaStats == nil {
+		return res
+	}
+
+	h.kafkaStats.Lock()
+	defer h.kafkaStats.Unlock()
+	for partition, n := range h.kafkaStats.partitionErrors {
+		res[partition] = n
+	}
+	return res
+}
+
+// kafkaProduceError is a permanent error of a producer, which makes the hook reconnect.
+type kafkaProduceError struct {
+	err error
+}
+
+func (e kafkaProduceError) Error() string {
+	return fmt.Sprintf("Couldn't publish message to kafka: %s", e.err)
+}
+
+func (e kafkaProduceError) Unwrap() error   { return e.err }
+func (e kafkaProduceError) Timeout() bool   { return false }
+func (e kafkaProduceError) Temporary() bool { return false }
+
+// kafkaConn is a net.Conn which publishes each written message to a Kafka topic,
+// using the app name as the key.
+type kafkaConn struct {
+	producer KafkaProducer
+	topic    string
+	key      []byte
+	addr     kafkaAddr
+	stats    *kafkaStats
+	deadline time.Time
+}
+
+type kafkaAddr string
+
+func (a kafkaAddr) Network() string {
+	return "kafka"
+}
+
+func (a kafkaAddr) String() string {
+	return string(a)
+}
+
+// parseKafkaAddress splits "broker1:9092,broker2:9092/topic" into brokers and topic.
+func parseKafkaAddress(address string) (brokers []string, topic string, err error) {
+	i := strings.LastIndex(address, "/")
+	if i <= 0 || i == len(address)-1 {
+		return nil, "", fmt.Errorf("Invalid kafka address '%s', expected 'broker1:9092,broker2:9092/topic'", address)
+	}
+
+	return strings.Split(address[:i], ","), address[i+1:], nil
+}
+
+func (h *Hook) dialKafka(address string) (net.Conn, error) {
+	if h.kafkaProducerFactory == nil {
+		return nil, ErrNoKafkaProducer
+	}
+	brokers, topic, err := parseKafkaAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := h.kafkaProducerFactory(brokers, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaConn{
+		producer: producer,
+		topic:    topic,
+		key:      []byte(h.appName),
+		addr:     kafkaAddr(address),
+		stats:    h.kafkaStats,
+	}, nil
+}
+
+// Write publishes b without the trailing newline as a single message.
+func (c *kafkaConn) Write(b []byte) (int, error) {
+	ctx := context.Background()
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	value := b
+	if len(value) > 0 && value[len(value)-1] == '\n' {
+		value = value[:len(value)-1]
+	}
+	partition, err := c.producer.Produce(ctx, c.topic, c.key, value)
+	if err == nil {
+		return len(b), nil
+	}
+
+	c.stats.addError(partition)
+	if temporary, ok := err.(interface{ Temporary() bool }); ok && temporary.Temporary() {
+		return 0, retryableError{err}
+	}
+	return 0, kafkaProduceError{err}
+}
+
+func (c *kafkaConn) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (c *kafkaConn) Close() error {
+	return c.producer.Close()
+}
+
+func (c *kafkaConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *kafkaConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *kafkaConn) SetDeadline(t time.Time) error {
+	return c.SetWriteDeadline(t)
+}
+
+func (c *kafkaConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *kafkaConn) SetWriteDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
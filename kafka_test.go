@@ -0,0 +1,131 @@
+package logrustash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type kafkaMessage struct {
+	topic string
+	key   string
+	value string
+}
+
+type fakeKafkaProducer struct {
+	sync.Mutex
+	errs     []error // returned in order, then nil
+	messages []kafkaMessage
+	closed   bool
+}
+
+func (p *fakeKafkaProducer) Produce(ctx context.Context, topic string, key, value []byte) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return 1, err
+	}
+	p.messages = append(p.messages, kafkaMessage{topic: topic, key: string(key), value: string(value)})
+	return 0, nil
+}
+
+func (p *fakeKafkaProducer) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	p.closed = true
+	return nil
+}
+
+type temporaryKafkaError struct{}
+
+func (temporaryKafkaError) Error() string   { return "leader not available" }
+func (temporaryKafkaError) Temporary() bool { return true }
+
+func TestKafka(t *testing.T) {
+	producer := &fakeKafkaProducer{}
+	var brokers []string
+	var topic string
+	factory := func(b []string, t string) (KafkaProducer, error) {
+		brokers, topic = b, t
+		return producer, nil
+	}
+	hook, err := NewHook("kafka", "kafka1:9092,kafka2:9092/logs", "kafka_test", WithKafkaProducer(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(brokers) != 2 || brokers[0] != "kafka1:9092" || brokers[1] != "kafka2:9092" || topic != "logs" {
+		t.Errorf("expected brokers [kafka1:9092 kafka2:9092] and topic 'logs' but got %v and '%s'", brokers, topic)
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(producer.messages) != 1 {
+		t.Fatalf("expected 1 message but got %d", len(producer.messages))
+	}
+	message := producer.messages[0]
+	if message.topic != "logs" || message.key != "kafka_test" {
+		t.Errorf("expected topic 'logs' and key 'kafka_test' but got '%s' and '%s'", message.topic, message.key)
+	}
+	var res map[string]string
+	if err := json.Unmarshal([]byte(message.value), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "hello" {
+		t.Errorf("expected message to be 'hello' but got '%s'", res["message"])
+	}
+
+	hook.Close()
+	if !producer.closed {
+		t.Error("expected producer to be closed")
+	}
+}
+
+func TestKafkaErrors(t *testing.T) {
+	producers := 0
+	permanentErr := errors.New("topic authorization failed")
+	factory := func(brokers []string, topic string) (KafkaProducer, error) {
+		producers++
+		if producers == 1 {
+			return &fakeKafkaProducer{errs: []error{temporaryKafkaError{}, permanentErr}}, nil
+		}
+		return &fakeKafkaProducer{}, nil
+	}
+	hook, err := NewHook("kafka", "kafka:9092/logs", "kafka_test", WithKafkaProducer(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxSendRetries = 1
+	hook.MaxReconnectRetries = 1
+
+	// The temporary error is retried on the same producer, the permanent one makes the hook create a new producer.
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if producers != 2 {
+		t.Errorf("expected 2 producers but got %d", producers)
+	}
+	if errs := hook.KafkaPartitionErrors(); len(errs) != 1 || errs[1] != 2 {
+		t.Errorf("expected 2 errors for partition 1 but got %v", errs)
+	}
+}
+
+func TestKafkaInvalidAddress(t *testing.T) {
+	factory := func(brokers []string, topic string) (KafkaProducer, error) {
+		return &fakeKafkaProducer{}, nil
+	}
+	for _, address := range []string{"kafka:9092", "kafka:9092/", "/logs"} {
+		if _, err := NewHook("kafka", address, "kafka_test", WithKafkaProducer(factory)); err == nil {
+			t.Errorf("expected error for address '%s'", address)
+		}
+	}
+	if _, err := NewHook("kafka", "kafka:9092/logs", "kafka_test"); err != ErrNoKafkaProducer {
+		t.Errorf("expected '%v' but got '%v'", ErrNoKafkaProducer, err)
+	}
+}
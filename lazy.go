@@ -0,0 +1,43 @@
+package logrustash
+
+import "fmt"
+
+// LazyField is a field value which is computed when the entry is formatted
+// instead of when it is logged, e.g. the current number of goroutines:
+//
+//	hook.WithField("goroutines", logrustash.LazyField(func() interface{} {
+//		return runtime.NumGoroutine()
+//	}))
+//
+// LazyField values are recognized both in the always-sent fields of a hook and
+// in the entry fields. Note that logrus.Entry.WithField rejects function values,
+// so LazyField values must be put into entry.Data directly (e.g. by another hook).
+// The function is called once per formatted entry, so it is not called at all
+// for entries which are never sent.
+//
+// In async mode the function is called by the sender goroutine, possibly long
+// after Fire has returned, and in sync mode it is called by every goroutine
+// which logs, so it must be safe for concurrent use. If the function panics,
+// the field value is replaced by a string describing the panic.
+type LazyField func() interface{}
+
+// evaluate calls the function and recovers from its panics.
+func (f LazyField) evaluate() (value interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			value = fmt.Sprintf("LazyField panicked: %v", r)
+		}
+	}()
+
+	return f()
+}
+
+// SetFieldFunc makes the hook send the value returned by fn in the field key with every message,
+// e.g. the current deployment color or a snapshot of the feature flags which change at runtime.
+// It is a shorthand for WithField(key, LazyField(fn)): fn is called once per formatted entry
+// (by the sender in async mode, so not for the entries dropped before), the fields of the entry
+// with the same key win and a panic of fn is replaced by a string describing it.
+// It may be called while the hook is in use.
+func (h *Hook) SetFieldFunc(key string, fn func() interface{}) {
+	h.WithField(key, LazyField(fn))
+}
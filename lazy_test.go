@@ -0,0 +1,126 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestLogstashFormatterLazyField(t *testing.T) {
+	lf := LogstashFormatter{}
+	entry := &logrus.Entry{Data: logrus.Fields{
+		"depth": LazyField(func() interface{} { return 42 }),
+		"panic": LazyField(func() interface{} { panic("broken field") }),
+	}}
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["depth"] != float64(42) {
+		t.Errorf("expected depth to be '%v' but got '%v'", 42, data["depth"])
+	}
+	if data["panic"] != "LazyField panicked: broken field" {
+		t.Errorf("expected panic to be replaced with an error string but got '%v'", data["panic"])
+	}
+}
+
+func TestFireLazyField(t *testing.T) {
+	var hookCalls, entryCalls uint64
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "lazy_test", logrus.Fields{
+		"hook_calls": LazyField(func() interface{} { return atomic.AddUint64(&hookCalls, 1) }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{
+			"entry_calls": LazyField(func() interface{} { return atomic.AddUint64(&entryCalls, 1) }),
+		}}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if n := atomic.LoadUint64(&hookCalls); n != 3 {
+		t.Errorf("expected the always-sent lazy field to be evaluated 3 times but got %d", n)
+	}
+	if n := atomic.LoadUint64(&entryCalls); n != 3 {
+		t.Errorf("expected the entry lazy field to be evaluated 3 times but got %d", n)
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for i := 1; i <= 3; i++ {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["hook_calls"] != float64(i) {
+			t.Errorf("expected hook_calls to be '%d' but got '%v'", i, res["hook_calls"])
+		}
+		if res["entry_calls"] != float64(i) {
+			t.Errorf("expected entry_calls to be '%d' but got '%v'", i, res["entry_calls"])
+		}
+	}
+}
+
+func TestSetFieldFunc(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewAsyncHookWithTransport(transport, "lazy_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var color atomic.Value
+	color.Store("blue")
+
+	// The functions are registered while entries are being sent.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			hook.SetFieldFunc("color", func() interface{} { return color.Load() })
+			hook.SetFieldFunc("broken", func() interface{} { panic("no flags") })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	color.Store("green")
+	for _, data := range []logrus.Fields{{}, {"color": "entry"}} {
+		if err := hook.Fire(&logrus.Entry{Message: "last", Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	events := transport.Events()
+	if len(events) != 102 {
+		t.Fatalf("expected 102 events but got %d", len(events))
+	}
+	// The function is evaluated when the entry is sent and the field of the entry wins.
+	if last := events[100]; last["color"] != "green" || last["broken"] != "LazyField panicked: no flags" {
+		t.Errorf("expected the color 'green' and the panic as a string but got %v", last)
+	}
+	if last := events[101]; last["color"] != "entry" {
+		t.Errorf("expected the field of the entry to win but got '%v'", last["color"])
+	}
+	hook.Close()
+}
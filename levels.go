@@ -0,0 +1,96 @@
+package logrustash
+
+import (
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// levelsConfigured marks a levels mask which has been set by WithLevels, SetLevels or SetMinLevel.
+const levelsConfigured = 1 << 31
+
+// defaultLevels are the levels of a hook which hasn't been configured otherwise.
+var defaultLevels = []logrus.Level{
+	logrus.PanicLevel,
+	logrus.FatalLevel,
+	logrus.ErrorLevel,
+	logrus.WarnLevel,
+	logrus.InfoLevel,
+	logrus.DebugLevel,
+}
+
+// WithLevels makes the hook subscribe only to levels. See SetLevels.
+func WithLevels(levels ...logrus.Level) Option {
+	return func(h *Hook) {
+		h.SetLevels(levels...)
+	}
+}
+
+// SetLevels makes the hook send only the entries of levels.
+// logrus reads the levels of a hook once, when it is added with AddHook, so after that
+// the change is applied by Fire: the entries of the other levels are skipped.
+// They are not counted as dropped, but as filtered (see FilteredCount).
+func (h *Hook) SetLevels(levels ...logrus.Level) {
+	var mask uint32 = levelsConfigured
+	for _, level := range levels {
+		mask |= 1 << level
+	}
+	atomic.StoreUint32(&h.levelMask, mask)
+}
+
+// SetMinLevel makes the hook send only the entries of level and the more severe levels,
+// e.g. logrus.WarnLevel sends warnings, errors, fatal errors and panics. See SetLevels.
+func (h *Hook) SetMinLevel(level logrus.Level) {
+	var levels []logrus.Level
+	for _, l := range logrus.AllLevels {
+		if l <= level {
+			levels = append(levels, l)
+		}
+	}
+	h.SetLevels(levels...)
+}
+
+// SetDynamicMinLevel makes the hook skip the entries less severe than level in addition
+// to the levels set by WithLevels, SetLevels or SetMinLevel, e.g. to ship the debug entries
+// for a while during an incident. It is cheap and safe to call at any time from any goroutine.
+// The skipped entries are counted in FilteredCount.
+func (h *Hook) SetDynamicMinLevel(level logrus.Level) {
+	atomic.StoreUint32(&h.dynamicMinLevel, uint32(level)+1)
+}
+
+// FilteredCount returns how many entries have been skipped because of their levels
+// (see SetLevels and SetDynamicMinLevel).
+func (h *Hook) FilteredCount() uint64 {
+	return atomic.LoadUint64(&h.filteredCount)
+}
+
+// Levels specifies "active" log levels.
+// Log messages with this levels will be sent to logstash.
+// By default these are all the levels except logrus.TraceLevel.
+func (h *Hook) Levels() []logrus.Level {
+	mask := atomic.LoadUint32(&h.levelMask)
+	if mask&levelsConfigured == 0 {
+		return append([]logrus.Level(nil), defaultLevels...)
+	}
+
+	levels := []logrus.Level{}
+	for _, level := range logrus.AllLevels {
+		if mask&(1<<level) != 0 {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// levelEnabled reports whether the entries of level are sent.
+func (h *Hook) levelEnabled(level logrus.Level) bool {
+	if minLevel := atomic.LoadUint32(&h.dynamicMinLevel); minLevel != 0 && uint32(level) > minLevel-1 {
+		return false
+	}
+
+	mask := atomic.LoadUint32(&h.levelMask)
+	if mask&levelsConfigured == 0 {
+		return level <= logrus.DebugLevel
+	}
+	return level < 31 && mask&(1<<level) != 0
+}
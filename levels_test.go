@@ -0,0 +1,143 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithLevels(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "levels_test", WithLevels(logrus.ErrorLevel, logrus.TraceLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []logrus.Level{logrus.ErrorLevel, logrus.TraceLevel}
+	if res := hook.Levels(); !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected levels to be '%v' but got '%v'", expected, res)
+	}
+
+	for _, level := range []logrus.Level{logrus.ErrorLevel, logrus.InfoLevel, logrus.TraceLevel} {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Level: level, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hook.DroppedCount() != 0 {
+		t.Errorf("expected skipped entries to not be counted as dropped but got %d", hook.DroppedCount())
+	}
+	if hook.FilteredCount() != 1 {
+		t.Errorf("expected 1 filtered entry but got %d", hook.FilteredCount())
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"error", "trace"} {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["level"] != expected {
+			t.Errorf("expected level '%s' but got '%v'", expected, res["level"])
+		}
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+}
+
+func TestSetMinLevel(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "levels_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hook is added before the level is changed, so Fire has to skip the entries.
+	logger := logrus.New()
+	logger.Out = bytes.NewBufferString("")
+	logger.Level = logrus.TraceLevel
+	logger.Hooks.Add(hook)
+	hook.SetMinLevel(logrus.WarnLevel)
+
+	expected := []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+	if res := hook.Levels(); !reflect.DeepEqual(expected, res) {
+		t.Errorf("expected levels to be '%v' but got '%v'", expected, res)
+	}
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"warn", "error"} {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message '%s' but got '%v'", expected, res["message"])
+		}
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+}
+
+func TestSetDynamicMinLevel(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "levels_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fire := func(level logrus.Level) {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Level: level, Data: logrus.Fields{}}); err != nil {
+			t.Error(err)
+		}
+	}
+	hook.SetDynamicMinLevel(logrus.InfoLevel)
+	fire(logrus.DebugLevel)
+	fire(logrus.InfoLevel)
+	hook.SetDynamicMinLevel(logrus.DebugLevel)
+	fire(logrus.DebugLevel)
+	// The levels of the hook still apply.
+	hook.SetDynamicMinLevel(logrus.TraceLevel)
+	fire(logrus.TraceLevel)
+	if hook.FilteredCount() != 2 || hook.DroppedCount() != 0 {
+		t.Errorf("expected 2 filtered and no dropped entries but got %d and %d", hook.FilteredCount(), hook.DroppedCount())
+	}
+
+	// Toggle the level while logging.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				hook.SetDynamicMinLevel(logrus.DebugLevel)
+			} else {
+				hook.SetDynamicMinLevel(logrus.ErrorLevel)
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		fire(logrus.DebugLevel)
+	}
+	close(stop)
+	wg.Wait()
+
+	sent := uint64(bytes.Count(conn.buff.Bytes(), []byte("\n")))
+	if sent+hook.FilteredCount() != 1004 {
+		t.Errorf("expected 1004 sent and filtered entries but got %d sent and %d filtered", sent, hook.FilteredCount())
+	}
+}
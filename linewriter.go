@@ -0,0 +1,89 @@
+package logrustash
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxWriterLineSize is the size of the longest line buffered by the writer returned by Writer:
+// a longer line is sent in several messages.
+const maxWriterLineSize = 64 * 1024
+
+// lineWriter is an io.Writer which fires an entry for each line written to it.
+type lineWriter struct {
+	sync.Mutex
+	hook  *Hook
+	level logrus.Level
+	buf   []byte // the last incomplete line
+}
+
+// Writer returns an io.WriteCloser which fires an entry of level with each line written
+// to it as the message (without the trailing newline), e.g. for log.SetOutput of the standard
+// library or the libraries which only log to an io.Writer. The entries get the fields of the hook
+// like any other entry. A line split across several Write calls is buffered until its end
+// (or until it reaches 64 KB); Close sends the incomplete line, if any. Empty lines are skipped.
+// Write returns the error of firing the first line which can't be fired.
+func (h *Hook) Writer(level logrus.Level) io.WriteCloser {
+	return &lineWriter{hook: h, level: level}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	var firstErr error
+	fire := func(line []byte) {
+		if err := w.fire(line); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, data...)
+			for len(w.buf) >= maxWriterLineSize {
+				fire(w.buf[:maxWriterLineSize])
+				w.buf = append(w.buf[:0], w.buf[maxWriterLineSize:]...)
+			}
+			break
+		}
+		if len(w.buf) > 0 {
+			w.buf = append(w.buf, data[:i]...)
+			fire(w.buf)
+			w.buf = w.buf[:0]
+		} else {
+			fire(data[:i])
+		}
+		data = data[i+1:]
+	}
+
+	return len(p), firstErr
+}
+
+// Close sends the incomplete line, if any. It doesn't close the hook.
+func (w *lineWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	line := w.buf
+	w.buf = nil
+	return w.fire(line)
+}
+
+func (w *lineWriter) fire(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return nil
+	}
+
+	return w.hook.Fire(&logrus.Entry{
+		Time:    w.hook.clock(),
+		Level:   w.level,
+		Message: string(line),
+		Data:    logrus.Fields{},
+	})
+}
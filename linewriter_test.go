@@ -0,0 +1,52 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLineWriter(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "writer_test", logrus.Fields{"always": "sent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := hook.Writer(logrus.WarnLevel)
+
+	log.New(w, "", 0).Println("from the standard library")
+	// A line may be split across several writes, and a write may contain several lines.
+	for _, chunk := range []string{"hel", "lo\r\nwor", "ld\n\nlast ", "line"} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("expected %d bytes to be written but got %d, %v", len(chunk), n, err)
+		}
+	}
+	long := strings.Repeat("x", maxWriterLineSize+10)
+	if _, err := w.Write([]byte(long)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"from the standard library", "hello", "world", "last line" + long[:maxWriterLineSize-len("last line")], long[maxWriterLineSize-len("last line"):]} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message '%.30s' (%d bytes) but got '%.30s' (%d bytes)", expected, len(expected), res["message"], len(res["message"]))
+		}
+		if res["level"] != "warning" || res["always"] != "sent" {
+			t.Errorf("expected the level 'warning' and the fields of the hook but got %v", res)
+		}
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+}
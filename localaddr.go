@@ -0,0 +1,58 @@
+package logrustash
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// localAddr is the local address the connections are bound to, see WithLocalAddr.
+type localAddr struct {
+	ip   net.IP
+	port int
+}
+
+// WithLocalAddr binds the TCP and UDP connections to logstash to the local address addr,
+// an IP address with an optional port (e.g. "10.0.0.5" or "10.0.0.5:0"), so the traffic
+// leaves a multi-homed host through a specific interface. The constructors which dial
+// return an error if addr can't be parsed.
+func WithLocalAddr(addr string) Option {
+	return func(h *Hook) {
+		h.localAddr, h.localAddrErr = parseLocalAddr(addr)
+	}
+}
+
+func parseLocalAddr(addr string) (*localAddr, error) {
+	host, port := addr, "0"
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		host, port = h, p
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid local address %q: not an IP address", addr)
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid local address %q: %w", addr, err)
+	}
+
+	return &localAddr{ip: ip, port: int(n)}, nil
+}
+
+// netAddr returns the local address for a connection of protocol.
+func (a *localAddr) netAddr(protocol string) net.Addr {
+	if a == nil {
+		return nil
+	}
+	switch protocol {
+	case "udp", "udp4", "udp6":
+		return &net.UDPAddr{IP: a.ip, Port: a.port}
+	default:
+		return &net.TCPAddr{IP: a.ip, Port: a.port}
+	}
+}
+
+// dialer returns the dialer of the connections of protocol, respecting DialTimeout and WithLocalAddr.
+func (h *Hook) dialer(protocol string) *net.Dialer {
+	return &net.Dialer{Timeout: h.DialTimeout, LocalAddr: h.localAddr.netAddr(protocol)}
+}
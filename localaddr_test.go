@@ -0,0 +1,33 @@
+package logrustash
+
+import (
+	"net"
+	"testing"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestLocalAddr(t *testing.T) {
+	for _, protocol := range []string{"tcp", "udp"} {
+		server := logrustashtest.NewServer(t, protocol)
+		hook, err := NewHook(protocol, server.Addr(), "local_addr_test", WithLocalAddr("127.0.0.2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := hook.getConn().LocalAddr().String()
+		if host, _, _ := net.SplitHostPort(addr); host != "127.0.0.2" {
+			t.Errorf("%s: expected the connection to be bound to 127.0.0.2 but got %s", protocol, addr)
+		}
+		fireMessages(t, hook, "bound")
+		checkEventMessages(t, server, "bound")
+		hook.Close()
+	}
+}
+
+func TestLocalAddrInvalid(t *testing.T) {
+	for _, addr := range []string{"eth0", "127.0.0.2:http", "127.0.0.2:70000"} {
+		if _, err := NewHook("tcp", "127.0.0.1:9", "local_addr_test", WithLocalAddr(addr)); err == nil {
+			t.Errorf("expected an error for the local address '%s'", addr)
+		}
+	}
+}
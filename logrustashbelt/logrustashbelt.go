@@ -0,0 +1,95 @@
+// Package logrustashbelt sends the entries of a go-belt logger through a logrustash hook.
+// It is a separate package, so the hook itself doesn't depend on go-belt.
+package logrustashbelt
+
+import (
+	"fmt"
+
+	"github.com/facebookincubator/go-belt/pkg/field"
+	"github.com/facebookincubator/go-belt/tool/logger/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash"
+)
+
+// TraceIDsField is the field with the trace IDs of go-belt entries.
+const TraceIDsField = "trace_ids"
+
+// Emitter is a go-belt logger Emitter which sends the entries through a logrustash hook,
+// so the same connection, retries and buffer are used for go-belt as for logrus.
+type Emitter struct {
+	hook *logrustash.Hook
+}
+
+var _ types.Emitter = (*Emitter)(nil)
+
+// NewEmitter creates a go-belt logger Emitter which sends the entries through hook.
+// The fields of the entries are sent the same way as the fields of logrus entries,
+// including the hook only prefix filtering. The trace IDs are sent in TraceIDsField.
+// The entries of the levels which the hook doesn't send are discarded (see hook.FilteredCount).
+func NewEmitter(hook *logrustash.Hook) *Emitter {
+	return &Emitter{hook: hook}
+}
+
+// Level maps a go-belt level onto a logrus level.
+// ok is false for the levels which don't describe entries (LevelNone and LevelUndefined).
+func Level(level types.Level) (logrusLevel logrus.Level, ok bool) {
+	switch level {
+	case types.LevelTrace:
+		return logrus.TraceLevel, true
+	case types.LevelDebug:
+		return logrus.DebugLevel, true
+	case types.LevelInfo:
+		return logrus.InfoLevel, true
+	case types.LevelWarning:
+		return logrus.WarnLevel, true
+	case types.LevelError:
+		return logrus.ErrorLevel, true
+	case types.LevelPanic:
+		return logrus.PanicLevel, true
+	case types.LevelFatal:
+		return logrus.FatalLevel, true
+	default:
+		return 0, false
+	}
+}
+
+// Emit implements types.Emitter. It never panics or exits, even for the Panic and Fatal levels.
+func (e *Emitter) Emit(entry *types.Entry) {
+	level, ok := Level(entry.Level)
+	if !ok {
+		return
+	}
+
+	logrusEntry := &logrus.Entry{
+		Time:    entry.Timestamp,
+		Level:   level,
+		Message: entry.Message,
+		Data:    make(logrus.Fields),
+	}
+	if entry.Fields != nil {
+		entry.Fields.ForEachField(func(f *field.Field) bool {
+			logrusEntry.Data[f.Key] = f.Value
+			return true
+		})
+	}
+	if len(entry.TraceIDs) > 0 {
+		traceIDs := make([]string, len(entry.TraceIDs))
+		for i, traceID := range entry.TraceIDs {
+			traceIDs[i] = string(traceID)
+		}
+		logrusEntry.Data[TraceIDsField] = traceIDs
+	}
+	if entry.Caller.Defined() {
+		logrusEntry.Caller = entry.Caller.Frame()
+	}
+
+	if err := e.hook.Fire(logrusEntry); err != nil {
+		fmt.Println("Error during sending go-belt entry to logstash:", err)
+	}
+}
+
+// Flush implements types.Emitter. It waits until all the entries emitted before are sent.
+func (e *Emitter) Flush() {
+	e.hook.Flush(0)
+}
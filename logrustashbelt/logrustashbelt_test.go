@@ -0,0 +1,102 @@
+package logrustashbelt
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/facebookincubator/go-belt"
+	"github.com/facebookincubator/go-belt/pkg/field"
+	"github.com/facebookincubator/go-belt/tool/logger/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash"
+)
+
+func TestLevel(t *testing.T) {
+	tt := map[types.Level]logrus.Level{
+		types.LevelTrace:   logrus.TraceLevel,
+		types.LevelDebug:   logrus.DebugLevel,
+		types.LevelInfo:    logrus.InfoLevel,
+		types.LevelWarning: logrus.WarnLevel,
+		types.LevelError:   logrus.ErrorLevel,
+		types.LevelPanic:   logrus.PanicLevel,
+		types.LevelFatal:   logrus.FatalLevel,
+	}
+	for level, expected := range tt {
+		if res, ok := Level(level); !ok || res != expected {
+			t.Errorf("expected %s to be mapped to %s but got %s", level, expected, res)
+		}
+	}
+	for _, level := range []types.Level{types.LevelNone, types.LevelUndefined} {
+		if _, ok := Level(level); ok {
+			t.Errorf("expected %s to not be mapped", level)
+		}
+	}
+}
+
+func TestEmitter(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	hook, err := logrustash.NewHookWithWriter(buff, "belt_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.WithFields(logrus.Fields{"env": "test"})
+	hook.WithPrefix("_")
+	emitter := NewEmitter(hook)
+
+	fields := field.Fields{
+		{Key: "user", Value: "alice"},
+		{Key: "attempt", Value: 3},
+		{Key: "_host", Value: "web-1"},
+	}
+	emitter.Emit(&types.Entry{
+		Timestamp: time.Date(2009, time.November, 10, 13, 4, 0, 0, time.UTC),
+		Level:     types.LevelWarning,
+		Message:   "retrying",
+		Fields:    fields,
+		TraceIDs:  belt.TraceIDs{"abc", "def"},
+	})
+	// Trace entries are discarded, since the hook doesn't have the trace level.
+	emitter.Emit(&types.Entry{Level: types.LevelTrace, Message: "discarded"})
+	emitter.Emit(&types.Entry{Level: types.LevelFatal, Message: "fatal"})
+	emitter.Flush()
+
+	dec := json.NewDecoder(buff)
+	var res map[string]interface{}
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"message":    "retrying",
+		"level":      "warning",
+		"@timestamp": "2009-11-10T13:04:00Z",
+		"user":       "alice",
+		"attempt":    float64(3),
+		"host":       "web-1",
+		"env":        "test",
+		"type":       "belt_test",
+	}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%v' but got '%v'", k, v, res[k])
+		}
+	}
+	if traceIDs, _ := res[TraceIDsField].([]interface{}); len(traceIDs) != 2 || traceIDs[0] != "abc" || traceIDs[1] != "def" {
+		t.Errorf("expected trace IDs to be [abc def] but got %v", res[TraceIDsField])
+	}
+	if fields[2].Key != "_host" {
+		t.Error("expected the fields of the entry to be left intact")
+	}
+
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "fatal" || res["level"] != "fatal" {
+		t.Errorf("expected fatal entry but got '%v' of level '%v'", res["message"], res["level"])
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+}
@@ -0,0 +1,45 @@
+// Package logrustashotel adds OpenTelemetry trace correlation fields to the messages
+// of a logrustash hook, so the logs can be linked to the traces (e.g. in Jaeger).
+// It is a separate package, so the hook itself doesn't depend on OpenTelemetry.
+package logrustashotel
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/xaionaro-go/logrustash"
+)
+
+// The names of the fields, as defined by the Elastic Common Schema.
+const (
+	TraceIDField    = "trace.id"
+	SpanIDField     = "span.id"
+	TraceFlagsField = "trace.flags"
+)
+
+// WithTraceFields makes the hook send the trace correlation fields (see Fields)
+// of the span in the context of each entry (see logrus.Entry.WithContext).
+// It replaces the extractor set by logrustash.WithContextExtractor, so Fields
+// should be called from the extractor instead if both are needed.
+func WithTraceFields() logrustash.Option {
+	return logrustash.WithContextExtractor(Fields)
+}
+
+// Fields returns the trace ID, the span ID and the trace flags of the span in ctx
+// hex-encoded as TraceIDField, SpanIDField and TraceFlagsField. If ctx doesn't have
+// a valid span context or the span is neither recording nor sampled, it returns nil.
+func Fields(ctx context.Context) logrus.Fields {
+	span := trace.SpanFromContext(ctx)
+	spanContext := span.SpanContext()
+	if !spanContext.IsValid() || (!span.IsRecording() && !spanContext.IsSampled()) {
+		return nil
+	}
+
+	return logrus.Fields{
+		TraceIDField:    spanContext.TraceID().String(),
+		SpanIDField:     spanContext.SpanID().String(),
+		TraceFlagsField: spanContext.TraceFlags().String(),
+	}
+}
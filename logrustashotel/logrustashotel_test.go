@@ -0,0 +1,69 @@
+package logrustashotel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/xaionaro-go/logrustash"
+)
+
+func spanContext(flags trace.TraceFlags) trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: flags,
+	})
+}
+
+func TestFields(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext(trace.FlagsSampled))
+	fields := Fields(ctx)
+	expected := logrus.Fields{
+		TraceIDField:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanIDField:     "00f067aa0ba902b7",
+		TraceFlagsField: "01",
+	}
+	if len(fields) != len(expected) {
+		t.Errorf("expected %v but got %v", expected, fields)
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("expected %s to be '%v' but got '%v'", k, v, fields[k])
+		}
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"no span":     context.Background(),
+		"not sampled": trace.ContextWithSpanContext(context.Background(), spanContext(0)),
+		"invalid":     trace.ContextWithSpanContext(context.Background(), trace.SpanContext{}),
+	} {
+		if fields := Fields(ctx); fields != nil {
+			t.Errorf("%s: expected no fields but got %v", name, fields)
+		}
+	}
+}
+
+func TestWithTraceFields(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	hook, err := logrustash.NewHookWithWriter(buff, "otel_test", WithTraceFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext(trace.FlagsSampled))
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}, Context: ctx}); err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res[TraceIDField] != "4bf92f3577b34da6a3ce929d0e0e4736" || res[SpanIDField] != "00f067aa0ba902b7" || res[TraceFlagsField] != "01" {
+		t.Errorf("expected the trace fields but got %v", res)
+	}
+}
@@ -0,0 +1,247 @@
+// Package logrustashtest provides a Logstash server for the tests of the code which
+// ships logs with logrustash, so the shipped events can be asserted.
+package logrustashtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const maxEventSize = 1 << 20
+
+// Server is a Logstash input with the json_lines codec, which listens on an ephemeral
+// TCP or UDP port of the loopback interface and records the received events.
+type Server struct {
+	network string
+	address string
+
+	sync.Mutex
+	listener   net.Listener   // tcp
+	packetConn net.PacketConn // udp
+	conns      map[net.Conn]struct{}
+	events     []map[string]interface{}
+	changed    chan struct{} // closed when an event is received
+	wg         sync.WaitGroup
+}
+
+// NewServer starts a server on network "tcp" or "udp". The server is closed
+// when the test and its subtests complete.
+func NewServer(t testing.TB, network string) *Server {
+	t.Helper()
+	if network != "tcp" && network != "udp" {
+		t.Fatalf("logrustashtest: unsupported network '%s'", network)
+	}
+
+	s := &Server{
+		network: network,
+		address: "127.0.0.1:0",
+		conns:   make(map[net.Conn]struct{}),
+		changed: make(chan struct{}),
+	}
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	s.address = s.Addr()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Network returns the network of the server, "tcp" or "udp".
+func (s *Server) Network() string {
+	return s.network
+}
+
+// Addr returns the address the server listens on, e.g. "127.0.0.1:34567".
+// It stays the same after Restart.
+func (s *Server) Addr() string {
+	s.Lock()
+	defer s.Unlock()
+	switch {
+	case s.listener != nil:
+		return s.listener.Addr().String()
+	case s.packetConn != nil:
+		return s.packetConn.LocalAddr().String()
+	default:
+		return s.address
+	}
+}
+
+// Events returns the events received so far, in the order of receiving.
+func (s *Server) Events() []map[string]interface{} {
+	s.Lock()
+	defer s.Unlock()
+	return append([]map[string]interface{}(nil), s.events...)
+}
+
+// WaitForEvents waits until at least n events have been received and returns all of them.
+// It returns an error with the events received so far if the timeout expires.
+func (s *Server) WaitForEvents(n int, timeout time.Duration) ([]map[string]interface{}, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.Lock()
+		events := append([]map[string]interface{}(nil), s.events...)
+		changed := s.changed
+		s.Unlock()
+		if len(events) >= n {
+			return events, nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return events, fmt.Errorf("logrustashtest: received %d events instead of %d within %s", len(events), n, timeout)
+		}
+	}
+}
+
+// Disconnect resets the established TCP connections, while the server keeps listening,
+// so the clients have to reconnect. It does nothing for UDP.
+func (s *Server) Disconnect() {
+	s.Lock()
+	defer s.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Stop stops listening and resets the established connections, like a Logstash which is down.
+// The received events are kept. The server can be started again with Restart.
+func (s *Server) Stop() {
+	s.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	if s.packetConn != nil {
+		s.packetConn.Close()
+		s.packetConn = nil
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.Unlock()
+
+	s.wg.Wait()
+}
+
+// Restart starts listening on the address of the server again after Stop.
+func (s *Server) Restart() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.listener != nil || s.packetConn != nil {
+		return nil
+	}
+
+	if s.network == "udp" {
+		packetConn, err := net.ListenPacket("udp", s.address)
+		if err != nil {
+			return fmt.Errorf("logrustashtest: %w", err)
+		}
+		s.packetConn = packetConn
+		s.wg.Add(1)
+		go s.servePackets(packetConn)
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("logrustashtest: %w", err)
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go s.serve(listener)
+	return nil
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.Stop()
+}
+
+func (s *Server) serve(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.Lock()
+		if s.listener != listener {
+			// Stopped while accepting the connection.
+			s.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.Lock()
+		delete(s.conns, conn)
+		s.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		s.record(scanner.Bytes())
+	}
+}
+
+func (s *Server) servePackets(packetConn net.PacketConn) {
+	defer s.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := packetConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			s.record(line)
+		}
+	}
+}
+
+// record decodes an event. Invalid events are recorded with the "_logrustashtest_error"
+// and "_logrustashtest_raw" fields, so they can be asserted as well.
+func (s *Server) record(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	event := decodeEvent(line)
+
+	s.Lock()
+	defer s.Unlock()
+	s.events = append(s.events, event)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func decodeEvent(data []byte) map[string]interface{} {
+	data = bytes.TrimSpace(data)
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return map[string]interface{}{
+			"_logrustashtest_error": err.Error(),
+			"_logrustashtest_raw":   string(data),
+		}
+	}
+	return event
+}
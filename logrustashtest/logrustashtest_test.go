@@ -0,0 +1,94 @@
+package logrustashtest
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		t.Run(network, func(t *testing.T) {
+			server := NewServer(t, network)
+			conn, err := net.Dial(network, server.Addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if _, err := conn.Write([]byte("{\"message\":\"first\"}\n")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Write([]byte("{\"message\":\"second\"}\nnot json\n")); err != nil {
+				t.Fatal(err)
+			}
+			events, err := server.WaitForEvents(3, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if events[0]["message"] != "first" || events[1]["message"] != "second" {
+				t.Errorf("expected the messages 'first' and 'second' but got %v", events)
+			}
+			if events[2]["_logrustashtest_raw"] != "not json" {
+				t.Errorf("expected the invalid event to be recorded but got %v", events[2])
+			}
+			if len(server.Events()) != 3 {
+				t.Errorf("expected 3 events but got %v", server.Events())
+			}
+			if _, err := server.WaitForEvents(4, 10*time.Millisecond); err == nil {
+				t.Error("expected WaitForEvents to time out")
+			}
+		})
+	}
+}
+
+func TestServerDisconnect(t *testing.T) {
+	server := NewServer(t, "tcp")
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.WaitForEvents(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Disconnect()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the connection to be closed")
+	}
+
+	// The server still accepts connections.
+	conn, err = net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	address := server.Addr()
+	server.Stop()
+	if _, err := net.Dial("tcp", address); err == nil {
+		t.Error("expected the stopped server to refuse connections")
+	}
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Addr() != address {
+		t.Errorf("expected the restarted server to listen on %s but got %s", address, server.Addr())
+	}
+	conn, err = net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.WaitForEvents(2, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
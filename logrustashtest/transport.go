@@ -0,0 +1,93 @@
+package logrustashtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTransportClosed is returned by Transport.Send after Close.
+var ErrTransportClosed = errors.New("logrustashtest: transport is closed")
+
+// Transport is a fake logrustash.Transport which records the sent messages in memory
+// and can be made to fail, e.g. to test the retries of the hook.
+type Transport struct {
+	sync.Mutex
+	messages [][]byte
+	failures []error
+	attempts int
+	closed   bool
+}
+
+// NewTransport creates a fake transport.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// FailNext makes the next len(errs) calls of Send return errs, one by one.
+func (t *Transport) FailNext(errs ...error) {
+	t.Lock()
+	defer t.Unlock()
+	t.failures = append(t.failures, errs...)
+}
+
+// Send implements logrustash.Transport.
+func (t *Transport) Send(ctx context.Context, data []byte) error {
+	t.Lock()
+	defer t.Unlock()
+	t.attempts++
+	if t.closed {
+		return ErrTransportClosed
+	}
+	if len(t.failures) > 0 {
+		err := t.failures[0]
+		t.failures = t.failures[1:]
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t.messages = append(t.messages, append([]byte(nil), data...))
+	return nil
+}
+
+// Close implements logrustash.Transport.
+func (t *Transport) Close() error {
+	t.Lock()
+	defer t.Unlock()
+	t.closed = true
+	return nil
+}
+
+// Closed reports whether Close has been called.
+func (t *Transport) Closed() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.closed
+}
+
+// Attempts returns how many times Send has been called, including the failed calls.
+func (t *Transport) Attempts() int {
+	t.Lock()
+	defer t.Unlock()
+	return t.attempts
+}
+
+// Messages returns the messages which have been sent successfully.
+func (t *Transport) Messages() [][]byte {
+	t.Lock()
+	defer t.Unlock()
+	return append([][]byte(nil), t.messages...)
+}
+
+// Events returns the sent messages decoded from JSON. Invalid messages are returned
+// with the "_logrustashtest_error" and "_logrustashtest_raw" fields, like by Server.
+func (t *Transport) Events() []map[string]interface{} {
+	messages := t.Messages()
+	events := make([]map[string]interface{}, 0, len(messages))
+	for _, message := range messages {
+		events = append(events, decodeEvent(message))
+	}
+	return events
+}
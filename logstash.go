@@ -1,16 +1,22 @@
 package logrustash
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net"
+	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	gas "github.com/xaionaro-go/goautosocket"
+	"golang.org/x/time/rate"
 )
 
 // Hook represents a connection to a Logstash instance
@@ -19,86 +25,194 @@ type Hook struct {
 	conn                     net.Conn
 	protocol                 string
 	address                  string
+	addressLocker            sync.RWMutex // protects protocol and address, which are replaced by SetAddress
 	appName                  string
 	alwaysSentFields         logrus.Fields
+	levelFields              map[logrus.Level]logrus.Fields
+	levelTimeouts            map[logrus.Level]time.Duration
+	fieldsLocker             sync.RWMutex // protects alwaysSentFields and hookOnlyPrefix
 	hookOnlyPrefix           string
 	TimeFormat               string
 	fireChannel              chan *logrus.Entry
+	channelsLocker           sync.RWMutex // protects fireChannel and closeChan, which are replaced by Reset
 	AsyncBufferSize          int
-	WaitUntilBufferFrees     bool
+	WaitUntilBufferFrees     bool          // Deprecated: use WithBufferFullStrategy(Block).
 	Timeout                  time.Duration // Timeout for sending message.
+	DialTimeout              time.Duration // Timeout for establishing a connection. Zero means the OS default.
+	ConnectionReadTimeout    time.Duration // Timeout for reading the acknowledgements of logstash. Zero means Timeout.
 	MaxSendRetries           int           // Declares how many times we will try to resend message.
+	MaxRetryElapsedTime      time.Duration // Declares how long we will try to send a message. Zero means no limit.
+	RetryBaseDelay           time.Duration // First resend delay, doubled for each next resend. Zero means no delay.
+	RetryMaxDelay            time.Duration // Longest resend delay. Zero means no limit.
+	RetryJitter              float64       // Randomized fraction of each resend delay, from 0 to 1.
 	ReconnectBaseDelay       time.Duration // First reconnect delay.
 	ReconnectDelayMultiplier float64       // Base multiplier for delay before reconnect.
 	MaxReconnectRetries      int           // Declares how many times we will try to reconnect.
+	MaxDatagramSize          int           // Larger UDP messages are dropped. Zero means DefaultMaxDatagramSize.
+	UDPMaxPacketSize         int           // Larger UDP messages cause a warning (see WithUDPMessageTruncation). Zero means DefaultUDPMaxPacketSize, negative disables it.
+	shadow                   *shadowEndpoint
+	formatter                LogstashFormatter // template for the formatter of each message
+	sanitizedCount           uint64
+	keyConflictCount         uint64
+	warnings                 sync.Map // the keys of the warnings printed by warnOnce
+	connFactory              func(protocol, address string) (net.Conn, error)
+	reconnectNotify          chan<- error
+	state                    int32 // HookState
+	closeChan                chan struct{}
+	contextExtractor         func(ctx context.Context) logrus.Fields
+	contextKeys              []contextKeyField
+	pause                    pauseState
+	bufferFullStrategy       BufferFullStrategy
+	localAddr                *localAddr
+	localAddrErr             error // the error of parsing the address of WithLocalAddr
+	addressFamily            AddressFamily
+	resolver                 ipResolver // net.DefaultResolver if nil
+	health                   healthState
+	framing                  Framing
+	validateRaw              bool
+	sequenceField            string
+	sequence                 uint64
+	sampler                  *sampler
+	droppedCount             uint64
+	droppedByReason          [dropReasonCount]uint64
+	dropMeta                 *dropMetaEntry
+	periodicFlush            *periodicFlush
+	dedup                    *deduplicator
+	oversizedCount           uint64
+	retryBudget              *rate.Limiter
+	writeBufferSize          int
+	keepAlive                bool
+	keepAlivePeriod          time.Duration
+	inFlight                 inFlight
+	proxyURL                 *url.URL
+	tlsConfig                *tls.Config
+	httpClient               *http.Client
+	lumberjackCompression    int
+	giveUpReconnecting       bool
+	gaveUp                   int32 // 1 if the hook has given up reconnecting
+	kafkaProducerFactory     func(brokers []string, topic string) (KafkaProducer, error)
+	kafkaStats               *kafkaStats
+	closeWriter              bool
+	enqueueLocker            sync.RWMutex // taken exclusively by FireBatch to reserve the buffer for a batch
+	fireBatchMode            FireBatchMode
+	middlewaresLocker        sync.RWMutex // protects middlewares
+	alwaysSentFieldsOverride bool
+	fallback                 *fallbackWriter
+	mirror                   *Hook
+	mirrorFlush              bool
+	customAppName            func(*logrus.Entry) string
+	typeOverrideField        string
+	middlewares              []Middleware
+	levelMask                uint32 // see SetLevels
+	dynamicMinLevel          uint32 // the level + 1 or 0 if not set, see SetDynamicMinLevel
+	filteredCount            uint64
+	parent                   *Hook // the hook which sends the entries of a child hook, see ChildWithFields
+	transport                Transport
+	statsd                   *statsdMetrics
+	wal                      *writeAheadLog
+	truncateUDPMessages      bool
+	orderedDelivery          bool       // see WithOrderedDelivery
+	orderLocker              sync.Mutex // held while sending an entry in sync mode with orderedDelivery
+	onDropped                atomic.Pointer[func(*logrus.Entry, DropReason)]
+	onError                  atomic.Pointer[func(error, Operation)]
+	now                      func() time.Time                       // the clock of MaxRetryElapsedTime, time.Now if nil
+	after                    func(d time.Duration) <-chan time.Time // the timer of the resend delays, time.After if nil
 }
 
+// ErrHookClosed is returned by Fire when the hook has been closed.
+var ErrHookClosed = errors.New("Hook is closed")
+
+// ErrGaveUpReconnecting is returned by Fire when the hook has given up reconnecting
+// to logstash (see WithMaxReconnectRetries) and hasn't been reset since.
+var ErrGaveUpReconnecting = errors.New("Hook has given up reconnecting to logstash")
+
+// ErrNotConnected is returned when a message is sent by a hook without a connection.
+var ErrNotConnected = errors.New("Hook is not connected to logstash")
+
 // NewHook creates a new hook to a Logstash instance, which listens on
 // `protocol`://`address`.
-func NewHook(protocol, address, appName string) (*Hook, error) {
-	return NewHookWithFields(protocol, address, appName, make(logrus.Fields))
+func NewHook(protocol, address, appName string, opts ...Option) (*Hook, error) {
+	return NewHookWithFields(protocol, address, appName, make(logrus.Fields), opts...)
 }
 
 // NewAsyncHook creates a new hook to a Logstash instance, which listens on
 // `protocol`://`address`.
 // Logs will be sent asynchronously.
-func NewAsyncHook(protocol, address, appName string) (*Hook, error) {
-	return NewAsyncHookWithFields(protocol, address, appName, make(logrus.Fields))
+func NewAsyncHook(protocol, address, appName string, opts ...Option) (*Hook, error) {
+	return NewAsyncHookWithFields(protocol, address, appName, make(logrus.Fields), opts...)
 }
 
 // NewHookWithConn creates a new hook to a Logstash instance, using the supplied connection.
-func NewHookWithConn(conn net.Conn, appName string) (*Hook, error) {
-	return NewHookWithFieldsAndConn(conn, appName, make(logrus.Fields))
+func NewHookWithConn(conn net.Conn, appName string, opts ...Option) (*Hook, error) {
+	return NewHookWithFieldsAndConn(conn, appName, make(logrus.Fields), opts...)
 }
 
 // NewAsyncHookWithConn creates a new hook to a Logstash instance, using the supplied connection.
 // Logs will be sent asynchronously.
-func NewAsyncHookWithConn(conn net.Conn, appName string) (*Hook, error) {
-	return NewAsyncHookWithFieldsAndConn(conn, appName, make(logrus.Fields))
+func NewAsyncHookWithConn(conn net.Conn, appName string, opts ...Option) (*Hook, error) {
+	return NewAsyncHookWithFieldsAndConn(conn, appName, make(logrus.Fields), opts...)
 }
 
 // NewHookWithFields creates a new hook to a Logstash instance, which listens on
 // `protocol`://`address`. alwaysSentFields will be sent with every log entry.
-func NewHookWithFields(protocol, address, appName string, alwaysSentFields logrus.Fields) (*Hook, error) {
-	return NewHookWithFieldsAndPrefix(protocol, address, appName, alwaysSentFields, "")
+func NewHookWithFields(protocol, address, appName string, alwaysSentFields logrus.Fields, opts ...Option) (*Hook, error) {
+	return NewHookWithFieldsAndPrefix(protocol, address, appName, alwaysSentFields, "", opts...)
 }
 
 // NewAsyncHookWithFields creates a new hook to a Logstash instance, which listens on
 // `protocol`://`address`. alwaysSentFields will be sent with every log entry.
 // Logs will be sent asynchronously.
-func NewAsyncHookWithFields(protocol, address, appName string, alwaysSentFields logrus.Fields) (*Hook, error) {
-	return NewAsyncHookWithFieldsAndPrefix(protocol, address, appName, alwaysSentFields, "")
+func NewAsyncHookWithFields(protocol, address, appName string, alwaysSentFields logrus.Fields, opts ...Option) (*Hook, error) {
+	return NewAsyncHookWithFieldsAndPrefix(protocol, address, appName, alwaysSentFields, "", opts...)
 }
 
 // NewHookWithFieldsAndPrefix creates a new hook to a Logstash instance, which listens on
 // `protocol`://`address`. alwaysSentFields will be sent with every log entry. prefix is used to select fields to filter.
-func NewHookWithFieldsAndPrefix(protocol, address, appName string, alwaysSentFields logrus.Fields, prefix string) (*Hook, error) {
-	var (
-		conn net.Conn
-		err  error
-	)
-	switch protocol {
-	case "tcp":
-		conn, err = gas.Dial("tcp", address)
-	default:
-		conn, err = net.Dial(protocol, address)
+func NewHookWithFieldsAndPrefix(protocol, address, appName string, alwaysSentFields logrus.Fields, prefix string, opts ...Option) (*Hook, error) {
+	hook := newHook(nil, appName, alwaysSentFields, prefix, opts)
+	hook.protocol = protocol
+	hook.address = address
+	if hook.localAddrErr != nil {
+		hook.Close()
+		return nil, hook.localAddrErr
 	}
-	if err != nil {
+	if err := hook.checkReservedFields(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+	if err := hook.checkOrderedDelivery(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+	if _, ok := hook.sender().(connTransport); !ok {
+		// The transport doesn't need a connection of the hook.
+		hook.setState(StateConnected)
+	} else {
+		conn, err := hook.dial(protocol, address)
+		if err != nil && hook.wal == nil {
+			// Stop the goroutines started by the options.
+			hook.Close()
+			return nil, err
+		}
+		if err == nil {
+			hook.setConn(conn)
+			hook.setState(StateConnected)
+		}
+	}
+	// With a write-ahead log, the entries wait on the disk until logstash is available.
+	if err := hook.startWAL(); err != nil {
+		hook.Close()
 		return nil, err
 	}
 
-	hook, err := NewHookWithFieldsAndConnAndPrefix(conn, appName, alwaysSentFields, prefix)
-	hook.protocol = protocol
-	hook.address = address
-
-	return hook, err
+	return hook, nil
 }
 
 // NewAsyncHookWithFieldsAndPrefix creates a new hook to a Logstash instance, which listens on
 // `protocol`://`address`. alwaysSentFields will be sent with every log entry. prefix is used to select fields to filter.
 // Logs will be sent asynchronously.
-func NewAsyncHookWithFieldsAndPrefix(protocol, address, appName string, alwaysSentFields logrus.Fields, prefix string) (*Hook, error) {
-	hook, err := NewHookWithFieldsAndPrefix(protocol, address, appName, alwaysSentFields, prefix)
+func NewAsyncHookWithFieldsAndPrefix(protocol, address, appName string, alwaysSentFields logrus.Fields, prefix string, opts ...Option) (*Hook, error) {
+	hook, err := NewHookWithFieldsAndPrefix(protocol, address, appName, alwaysSentFields, prefix, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -109,71 +223,258 @@ func NewAsyncHookWithFieldsAndPrefix(protocol, address, appName string, alwaysSe
 }
 
 // NewHookWithFieldsAndConn creates a new hook to a Logstash instance using the supplied connection.
-func NewHookWithFieldsAndConn(conn net.Conn, appName string, alwaysSentFields logrus.Fields) (*Hook, error) {
-	return NewHookWithFieldsAndConnAndPrefix(conn, appName, alwaysSentFields, "")
+func NewHookWithFieldsAndConn(conn net.Conn, appName string, alwaysSentFields logrus.Fields, opts ...Option) (*Hook, error) {
+	return NewHookWithFieldsAndConnAndPrefix(conn, appName, alwaysSentFields, "", opts...)
 }
 
 // NewAsyncHookWithFieldsAndConn creates a new hook to a Logstash instance using the supplied connection.
 // Logs will be sent asynchronously.
-func NewAsyncHookWithFieldsAndConn(conn net.Conn, appName string, alwaysSentFields logrus.Fields) (*Hook, error) {
-	return NewAsyncHookWithFieldsAndConnAndPrefix(conn, appName, alwaysSentFields, "")
+func NewAsyncHookWithFieldsAndConn(conn net.Conn, appName string, alwaysSentFields logrus.Fields, opts ...Option) (*Hook, error) {
+	return NewAsyncHookWithFieldsAndConnAndPrefix(conn, appName, alwaysSentFields, "", opts...)
 }
 
 // NewHookWithFieldsAndConnAndPrefix creates a new hook to a Logstash instance using the suppolied connection and prefix.
-func NewHookWithFieldsAndConnAndPrefix(conn net.Conn, appName string, alwaysSentFields logrus.Fields, prefix string) (*Hook, error) {
-	return &Hook{conn: conn, appName: appName, alwaysSentFields: alwaysSentFields, hookOnlyPrefix: prefix}, nil
+func NewHookWithFieldsAndConnAndPrefix(conn net.Conn, appName string, alwaysSentFields logrus.Fields, prefix string, opts ...Option) (*Hook, error) {
+	hook := newHook(conn, appName, alwaysSentFields, prefix, opts)
+	if err := hook.checkReservedFields(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+	if err := hook.checkOrderedDelivery(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+	if err := hook.startWAL(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+
+	return hook, nil
 }
 
 // NewAsyncHookWithFieldsAndConnAndPrefix creates a new hook to a Logstash instance using the suppolied connection and prefix.
 // Logs will be sent asynchronously.
-func NewAsyncHookWithFieldsAndConnAndPrefix(conn net.Conn, appName string, alwaysSentFields logrus.Fields, prefix string) (*Hook, error) {
-	hook := &Hook{conn: conn, appName: appName, alwaysSentFields: alwaysSentFields, hookOnlyPrefix: prefix}
+func NewAsyncHookWithFieldsAndConnAndPrefix(conn net.Conn, appName string, alwaysSentFields logrus.Fields, prefix string, opts ...Option) (*Hook, error) {
+	hook, err := NewHookWithFieldsAndConnAndPrefix(conn, appName, alwaysSentFields, prefix, opts...)
+	if err != nil {
+		return nil, err
+	}
 	hook.makeAsync()
 
 	return hook, nil
 }
 
 // NewFilterHook makes a new hook which does not forward to logstash, but simply enforces the prefix rules.
-func NewFilterHook() *Hook {
-	return NewFilterHookWithPrefix("")
+func NewFilterHook(opts ...Option) *Hook {
+	return NewFilterHookWithPrefix("", opts...)
 }
 
 // NewAsyncFilterHook makes a new hook which does not forward to logstash, but simply enforces the prefix rules.
 // Logs will be sent asynchronously.
-func NewAsyncFilterHook() *Hook {
-	return NewAsyncFilterHookWithPrefix("")
+func NewAsyncFilterHook(opts ...Option) *Hook {
+	return NewAsyncFilterHookWithPrefix("", opts...)
 }
 
 // NewFilterHookWithPrefix make a new hook which does not forward to logstash, but simply enforces the specified prefix.
-func NewFilterHookWithPrefix(prefix string) *Hook {
-	return &Hook{conn: nil, appName: "", alwaysSentFields: make(logrus.Fields), hookOnlyPrefix: prefix}
+func NewFilterHookWithPrefix(prefix string, opts ...Option) *Hook {
+	hook := newHook(nil, "", make(logrus.Fields), prefix, opts)
+	hook.wal = nil // there is nothing to send
+
+	return hook
 }
 
 // NewAsyncFilterHookWithPrefix make a new hook which does not forward to logstash, but simply enforces the specified prefix.
 // Logs will be sent asynchronously.
-func NewAsyncFilterHookWithPrefix(prefix string) *Hook {
-	hook := NewFilterHookWithPrefix(prefix)
+func NewAsyncFilterHookWithPrefix(prefix string, opts ...Option) *Hook {
+	hook := NewFilterHookWithPrefix(prefix, opts...)
 	hook.makeAsync()
 
 	return hook
 }
 
+func newHook(conn net.Conn, appName string, alwaysSentFields logrus.Fields, prefix string, opts []Option) *Hook {
+	// The options and WithField add to the fields, so the map of the caller is copied.
+	fields := make(logrus.Fields, len(alwaysSentFields))
+	for k, v := range alwaysSentFields {
+		fields[k] = v
+	}
+	hook := &Hook{
+		conn:             conn,
+		appName:          appName,
+		alwaysSentFields: fields,
+		hookOnlyPrefix:   prefix,
+		closeChan:        make(chan struct{}),
+	}
+	if conn == nil {
+		hook.state = int32(StateDisconnected)
+	}
+	hook.typeOverrideField = DefaultTypeOverrideField
+	for _, opt := range opts {
+		opt(hook)
+	}
+	if hook.transport != nil {
+		// The connection, if any, isn't used.
+		hook.conn = nil
+		hook.state = int32(StateConnected)
+	}
+	if hook.dropMetaEntryEnabled() {
+		go hook.dropMeta.loop(hook, hook.closeChan)
+	}
+	if hook.periodicFlushEnabled() {
+		go hook.periodicFlush.loop(hook, hook.closeChan)
+	}
+
+	return hook
+}
+
+// checkReservedFields returns a ReservedFieldError if the fields of the hook have the keys
+// of the fields set by the formatter, so their values would be replaced or moved.
+func (h *Hook) checkReservedFields() error {
+	reserved := map[string]bool{
+		"@timestamp": true,
+		"@version":   true,
+		stringOrDefault(h.formatter.MessageFieldName, defaultMessageFieldName): true,
+		stringOrDefault(h.formatter.LevelFieldName, defaultLevelFieldName):     true,
+	}
+	if h.appName != "" || h.customAppName != nil {
+		reserved[stringOrDefault(h.formatter.TypeKey, defaultTypeKey)] = true
+	}
+
+	var keys []string
+	for k := range h.alwaysSentFields {
+		// The key is sent like in LogstashFormatter.FormatWithPrefix.
+		key := k
+		if prefix := h.prefix(); prefix != "" {
+			key = strings.TrimPrefix(key, prefix)
+		}
+		if h.formatter.KeyTransform != nil {
+			key = h.formatter.KeyTransform(key)
+		}
+		if reserved[key] {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return &ReservedFieldError{Keys: keys}
+}
+
+// readTimeout returns the timeout of each read from a connection, see ConnectionReadTimeout.
+func (h *Hook) readTimeout() time.Duration {
+	if h.ConnectionReadTimeout > 0 {
+		return h.ConnectionReadTimeout
+	}
+	return h.Timeout
+}
+
+// writeTimeout returns the timeout of sending entry, see WithLevelBasedTimeout.
+// The entries sent with a single write (see FireBatch) get the longest timeout of them.
+func (h *Hook) writeTimeout(entry *logrus.Entry) time.Duration {
+	if h.levelTimeouts == nil {
+		return h.Timeout
+	}
+
+	var longest time.Duration
+	for i, entry := range batchEntries(entry) {
+		timeout, ok := h.levelTimeouts[entry.Level]
+		if !ok {
+			timeout = h.Timeout
+		}
+		if timeout <= 0 {
+			// No timeout at all.
+			return 0
+		}
+		if i == 0 || timeout > longest {
+			longest = timeout
+		}
+	}
+	return longest
+}
+
 func (h *Hook) makeAsync() {
 	h.fireChannel = make(chan *logrus.Entry, h.AsyncBufferSize)
+	go h.runSender(h.fireChannel, h.closeChan)
+}
 
-	go func() {
-		for entry := range h.fireChannel {
-			if err := h.sendMessage(entry); err != nil {
-				fmt.Println("Error during sending message to logstash:", err)
+// runSender sends the entries from fireChannel until closeChan is closed.
+func (h *Hook) runSender(fireChannel <-chan *logrus.Entry, closeChan <-chan struct{}) {
+	for {
+		// The entries wait in fireChannel while the hook is paused.
+		if !h.waitResumed(closeChan) {
+			return
+		}
+		select {
+		case entry := <-fireChannel:
+			for entry != nil {
+				// The entries of a batch are sent together, see FireBatch.
+				var entries []*logrus.Entry
+				entries, entry = collectBatch(entry, fireChannel)
+				if err := h.safeSendBatch(entries); err != nil {
+					fmt.Println("Error during sending message to logstash:", err)
+				}
+				for range entries {
+					h.inFlight.done()
+				}
 			}
+		case <-closeChan:
+			return
 		}
-	}()
+	}
+}
+
+// channels returns the buffer of the async mode (nil in sync mode) and the channel
+// closed by Close. Both are replaced when a closed hook is reset.
+func (h *Hook) channels() (fireChannel chan *logrus.Entry, closeChan chan struct{}) {
+	h.channelsLocker.RLock()
+	defer h.channelsLocker.RUnlock()
+	return h.fireChannel, h.closeChan
+}
+
+// Close stops sending messages and closes the connection to logstash.
+// Messages which are still in the buffer of an async hook are dropped.
+func (h *Hook) Close() error {
+	h.channelsLocker.Lock()
+	if HookState(atomic.SwapInt32(&h.state, int32(StateClosed))) == StateClosed {
+		h.channelsLocker.Unlock()
+		return nil
+	}
+	close(h.closeChan)
+	fireChannel := h.fireChannel
+	h.channelsLocker.Unlock()
+	h.dropBuffered(fireChannel, DropReasonClosed)
+
+	if h.mirror != nil {
+		h.mirror.Close()
+	}
+
+	if h.periodicFlushEnabled() {
+		// Wait for the flush in progress, so the transport isn't flushed after it's closed.
+		h.periodicFlush.Lock()
+		h.periodicFlush.Unlock()
+	}
+
+	conn := h.getConn()
+	err := h.sender().Close()
+	if h.wal != nil {
+		// Wait until the sender of the write-ahead log persists its state,
+		// so the next hook with the same directory may open it.
+		h.wal.running.Lock()
+		h.wal.running.Unlock()
+		if newConn := h.getConn(); newConn != nil && newConn != conn {
+			// The sender has reconnected in the meantime.
+			newConn.Close()
+		}
+	}
+
+	return err
 }
 
 func (h *Hook) filterHookOnly(entry *logrus.Entry) {
-	if h.hookOnlyPrefix != "" {
+	if prefix := h.prefix(); prefix != "" {
 		for key := range entry.Data {
-			if strings.HasPrefix(key, h.hookOnlyPrefix) {
+			if strings.HasPrefix(key, prefix) {
 				delete(entry.Data, key)
 			}
 		}
@@ -183,171 +484,444 @@ func (h *Hook) filterHookOnly(entry *logrus.Entry) {
 
 // WithPrefix sets a prefix filter to use in all subsequent logging
 func (h *Hook) WithPrefix(prefix string) {
+	h.fieldsLocker.Lock()
+	defer h.fieldsLocker.Unlock()
 	h.hookOnlyPrefix = prefix
 }
 
+func (h *Hook) prefix() string {
+	h.fieldsLocker.RLock()
+	defer h.fieldsLocker.RUnlock()
+	return h.hookOnlyPrefix
+}
+
 // WithField add field with value that will be sent with each message
 func (h *Hook) WithField(key string, value interface{}) {
+	h.fieldsLocker.Lock()
+	defer h.fieldsLocker.Unlock()
 	h.alwaysSentFields[key] = value
 }
 
 // WithFields add fields with values that will be sent with each message
 func (h *Hook) WithFields(fields logrus.Fields) {
+	h.fieldsLocker.Lock()
+	defer h.fieldsLocker.Unlock()
 	// Add all the new fields to the 'alwaysSentFields', possibly overwriting existing fields
 	for key, value := range fields {
 		h.alwaysSentFields[key] = value
 	}
 }
 
+// DeleteField removes a field previously added with WithField or WithFields
+func (h *Hook) DeleteField(key string) {
+	h.fieldsLocker.Lock()
+	defer h.fieldsLocker.Unlock()
+	delete(h.alwaysSentFields, key)
+}
+
 // Fire send message to logstash.
-// In async mode log message will be dropped if message buffer is full.
-// If you want wait until message buffer frees – set WaitUntilBufferFrees to true.
+// In async mode log message will be dropped if message buffer is full,
+// unless another strategy is set with WithBufferFullStrategy.
+// The entry is not modified, except that the fields with the hook only prefix are removed from it.
 func (h *Hook) Fire(entry *logrus.Entry) error {
-	if h.fireChannel != nil { // Async mode.
-		select {
-		case h.fireChannel <- entry:
-		default:
-			if h.WaitUntilBufferFrees {
-				h.fireChannel <- entry // Blocks the goroutine because buffer is full.
+	if h.parent != nil {
+		return h.fireChild(entry)
+	}
+	if h.ConnectionState() == StateClosed {
+		return ErrHookClosed
+	}
+	entry, err := h.prepareEntry(entry)
+	if entry == nil {
+		return err
+	}
 
-				return nil
-			}
+	return h.enqueue(entry)
+}
+
+// prepareEntry applies the levels, the mirror, sampling and deduplication to entry
+// and returns a copy to be sent, or nil if the entry mustn't be sent.
+func (h *Hook) prepareEntry(entry *logrus.Entry) (*logrus.Entry, error) {
+	if !h.levelEnabled(entry.Level) {
+		// The levels have been changed after the hook has been added to the logger.
+		h.filterHookOnly(entry)
+		atomic.AddUint64(&h.filteredCount, 1)
+		return nil, nil
+	}
+	h.fireMirror(entry)
+
+	if h.hasGivenUp() {
+		if h.fallback != nil {
+			h.fallbackEntry(copyEntry(entry))
+		}
+		h.filterHookOnly(entry)
+		h.drop(entry, DropReasonGaveUp)
+		return nil, ErrGaveUpReconnecting
+	}
+
+	send, suppressed := h.shouldSend(entry)
+	if !send {
+		h.filterHookOnly(entry)
+		return nil, nil
+	}
+
+	// The entry is shared with other hooks and the formatter of the logger,
+	// so the hook adds its fields to a copy.
+	original := entry
+	entry = copyEntry(original)
+	if suppressed > 0 && h.dedup.countField != "" {
+		entry.Data[h.dedup.countField] = suppressed
+	}
+	// Clear the hook only fields from the original entry synchronously,
+	// while no other hook or formatter uses it.
+	h.filterHookOnly(original)
+
+	return entry, nil
+}
+
+// enqueue sends entry, which isn't shared with anyone else, or puts it into the buffer
+// of the async mode or the write-ahead log.
+func (h *Hook) enqueue(entry *logrus.Entry) error {
+	if h.wal != nil {
+		return h.appendWAL(entry)
+	}
 
-			// Drop message by default.
+	h.inFlight.add()
+	if fireChannel, closeChan := h.channels(); fireChannel != nil { // Async mode.
+		h.enqueueLocker.RLock()
+		defer h.enqueueLocker.RUnlock()
+		select {
+		case fireChannel <- entry:
+			h.statsd.gauge("queue_depth", len(fireChannel))
+		default:
+			return h.fireBufferFull(entry, fireChannel, closeChan)
 		}
 
 		return nil
 	}
 
+	if h.Paused() {
+		h.inFlight.done()
+		h.drop(entry, DropReasonPaused)
+		h.fallbackEntry(entry)
+		return ErrHookPaused
+	}
+
+	defer h.inFlight.done()
+	if h.orderedDelivery {
+		// The entries fired concurrently are sent one at a time, see WithOrderedDelivery.
+		h.orderLocker.Lock()
+		defer h.orderLocker.Unlock()
+	}
 	return h.sendMessage(entry)
 }
 
-func (h *Hook) sendMessage(entry *logrus.Entry) error {
-	// Make sure we always clear the hook only fields from the entry
-	defer h.filterHookOnly(entry)
+// shouldSend applies sampling and deduplication to entry and counts the dropped entries.
+// suppressed is the number of identical entries dropped by deduplication since
+// the previous one has been sent.
+func (h *Hook) shouldSend(entry *logrus.Entry) (send bool, suppressed uint64) {
+	if h.sampler != nil && !h.sampler.sample(entry.Level) {
+		h.drop(entry, DropReasonSampling)
+		return false, 0
+	}
+	if h.dedup != nil && h.dedup.window > 0 {
+		send, suppressed = h.dedup.check(entry.Level, entry.Message)
+		if !send {
+			h.drop(entry, DropReasonDeduplication)
+		}
+		return send, suppressed
+	}
 
-	// Add in the alwaysSentFields. We don't override fields that are already set.
-	for k, v := range h.alwaysSentFields {
-		if _, inMap := entry.Data[k]; !inMap {
-			entry.Data[k] = v
+	return true, 0
+}
+
+// safeSendBatch calls sendBatch and recovers from its panics,
+// so a single broken message can't stop the sender goroutine of the async mode.
+func (h *Hook) safeSendBatch(entries []*logrus.Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Panic during sending message to logstash: %v", r)
 		}
+	}()
+
+	return h.sendBatch(entries)
+}
+
+// copyEntry returns a shallow copy of entry with its own Data.
+func copyEntry(entry *logrus.Entry) *logrus.Entry {
+	c := *entry
+	c.Data = make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		c.Data[k] = v
 	}
 
-	// For a filteringHook, stop here
-	h.RLock()
-	if h.conn == nil {
-		h.RUnlock()
+	return &c
+}
 
-		return nil
+// sendMessage adds the fields of the hook to entry, formats and sends it.
+// entry must not be shared with anyone else (see copyEntry).
+func (h *Hook) sendMessage(entry *logrus.Entry) error {
+	if h.hasGivenUp() {
+		// The entry has been queued before the hook gave up.
+		h.drop(entry, DropReasonGaveUp)
+		h.fallbackEntry(entry)
+		return ErrGaveUpReconnecting
 	}
-	h.RUnlock()
 
-	formatter := LogstashFormatter{Type: h.appName}
-	if h.TimeFormat != "" {
-		formatter.TimestampFormat = h.TimeFormat
+	return h.processEntry(entry, h.formatAndSend)
+}
+
+// formatAndSend formats entry, which has passed the middlewares, and sends it.
+func (h *Hook) formatAndSend(entry *logrus.Entry) error {
+	transport, err := h.connectedSender()
+	if err != nil {
+		h.fallbackEntry(entry)
+		return err
+	}
+	if transport == nil {
+		return nil
 	}
 
-	dataBytes, err := formatter.FormatWithPrefix(entry, h.hookOnlyPrefix)
+	dataBytes, err := h.formatMessage(entry)
 	if err != nil {
+		h.reportError(err, OperationFormat)
+		return err
+	}
+	dataBytes = h.checkPacketSize(transport, entry, dataBytes)
+	if err := h.checkDatagramSize(transport, dataBytes); err != nil {
+		h.drop(entry, DropReasonOversized)
+		h.fallbackData(dataBytes)
 		return err
 	}
 
-	return h.performSend(dataBytes, 0)
+	return h.sendData(entry, dataBytes)
 }
 
-// performSend tries to send data recursively.
-// sendRetries is the actual number of attempts to resend message.
-func (h *Hook) performSend(data []byte, sendRetries int) error {
-	if h.Timeout > 0 {
-		h.Lock()
-		h.conn.SetWriteDeadline(time.Now().Add(h.Timeout))
-		h.Unlock()
+// sendData sends data, the formatted entry (or the batch of entries, see FireBatch).
+func (h *Hook) sendData(entry *logrus.Entry, dataBytes []byte) error {
+	if h.shadow != nil {
+		h.shadow.enqueue(dataBytes)
 	}
 
-	h.Lock()
-	_, err := h.conn.Write(data)
-	h.Unlock()
-
+	err := h.performSend(entry, dataBytes, h.clock(), 0, 0)
+	if err == ErrNotConnected {
+		// SetAddress has closed the connection after the message has been formatted.
+		if err = (connTransport{h}).reconnect(0); err == nil {
+			err = h.performSend(entry, dataBytes, h.clock(), 0, 0)
+		} else {
+			err = &NetworkError{Err: fmt.Errorf("Couldn't connect to logstash: %w", err)}
+		}
+	}
 	if err != nil {
-		file := fmt.Sprintf("/tmp/logrustash-%d.tmp", time.Now().UnixNano())
-		ioutil.WriteFile(file, data, 0644)
-		fmt.Printf("Wrote message content to %s\n", file)
-		return h.processSendError(err, data, sendRetries)
+		h.fallbackData(dataBytes)
+		return err
 	}
-
+	h.statsd.count("sent", len(batchEntries(entry)))
+	h.health.success(h.clock())
 	return nil
 }
 
-func (h *Hook) processSendError(err error, data []byte, sendRetries int) error {
-	netErr, ok := err.(net.Error)
-	if !ok {
-		return err
+// formatMessage adds the fields of the hook to entry and formats it.
+// entry must not be shared with anyone else (see copyEntry).
+func (h *Hook) formatMessage(entry *logrus.Entry) ([]byte, error) {
+	if data, ok := rawMessage(entry); ok {
+		return h.framing.frame(append([]byte(nil), data...)), nil
 	}
 
-	if h.isNeedToResendMessage(netErr, sendRetries) {
-		return h.performSend(data, sendRetries+1)
+	// Add in the fields from the context of the entry. We don't override fields that are already set.
+	h.extractContextFields(entry)
+
+	// Add in the alwaysSentFields. We don't override fields that are already set,
+	// unless WithAlwaysSentFieldsOverride is used.
+	h.fieldsLocker.RLock()
+	for k, v := range h.alwaysSentFields {
+		if _, inMap := entry.Data[k]; !inMap || h.alwaysSentFieldsOverride {
+			entry.Data[k] = v
+		}
 	}
+	h.fieldsLocker.RUnlock()
 
-	if !netErr.Temporary() && h.MaxReconnectRetries > 0 {
-		if err := h.reconnect(0); err != nil {
-			return fmt.Errorf("Couldn't reconnect to logstash: %s. The reason of reconnect: %s", err, netErr)
+	// Add in the fields of the level of the entry. We don't override fields that are already set.
+	for k, v := range h.levelFields[entry.Level] {
+		if _, inMap := entry.Data[k]; !inMap {
+			entry.Data[k] = v
 		}
+	}
 
-		return h.performSend(data, 0)
+	if h.sequenceField != "" {
+		entry.Data[h.sequenceField] = atomic.AddUint64(&h.sequence, 1)
 	}
 
-	return err
+	return h.formatEntry(entry)
+}
+
+// formatEntry formats entry, which already has the fields of the hook.
+func (h *Hook) formatEntry(entry *logrus.Entry) ([]byte, error) {
+	formatter := h.newFormatter()
+	if appName := childAppName(entry); appName != "" {
+		formatter.Type = appName
+	}
+	if h.customAppName != nil {
+		if appName := h.customAppName(entry); appName != "" {
+			formatter.Type = appName
+		}
+	}
+	prefix := h.prefix()
+	if h.typeOverrideField != "" {
+		for _, key := range []string{h.typeOverrideField, prefix + h.typeOverrideField} {
+			v, ok := entry.Data[key]
+			if !ok {
+				continue
+			}
+			if appName, ok := v.(string); ok && appName != "" {
+				formatter.Type = appName
+			}
+			// The field is only for the hook, so it isn't sent. entry may be formatted again
+			// (see checkPacketSize), so the field is removed from a copy.
+			entry = copyEntry(entry)
+			delete(entry.Data, key)
+		}
+	}
+	dataBytes, err := formatter.formatJSON(entry, prefix)
+	if err != nil {
+		return nil, &FormatterError{Err: err}
+	}
+	dataBytes = h.framing.frame(dataBytes)
+	if n := formatter.SanitizedCount(); n > 0 {
+		atomic.AddUint64(&h.sanitizedCount, n)
+	}
+	if n := formatter.KeyConflictCount(); n > 0 {
+		atomic.AddUint64(&h.keyConflictCount, n)
+	}
+
+	return dataBytes, nil
 }
 
-// TODO Check reconnect for NOT ASYNC mode.
-// The hook will reconnect to Logstash several times with increasing sleep duration between each reconnect attempt.
-// Sleep duration calculated as product of ReconnectBaseDelay by ReconnectDelayMultiplier to the power of reconnectRetries.
-// reconnectRetries is the actual number of attempts to reconnect.
-func (h *Hook) reconnect(reconnectRetries int) error {
-	if h.protocol == "" || h.address == "" {
-		return fmt.Errorf("Can't reconnect because current configuration doesn't support it")
+func (h *Hook) newFormatter() *LogstashFormatter {
+	formatter := h.formatter
+	formatter.Type = h.appName
+	formatter.onKeyConflict = h.warnKeyConflict
+	if h.TimeFormat != "" {
+		formatter.TimestampFormat = h.TimeFormat
 	}
 
-	// Sleep before reconnect.
-	delay := float64(h.ReconnectBaseDelay) * math.Pow(h.ReconnectDelayMultiplier, float64(reconnectRetries))
-	time.Sleep(time.Duration(delay))
+	return &formatter
+}
 
-	conn, err := gas.Dial(h.protocol, h.address)
+// SanitizedCount returns how many log messages have been modified by the WithSanitize option.
+func (h *Hook) SanitizedCount() uint64 {
+	return atomic.LoadUint64(&h.sanitizedCount)
+}
+
+// DroppedCount returns how many log messages have been dropped by sampling
+// (see WithSamplingRate), deduplication (see WithDeduplication) or because
+// the buffer of the async mode was full.
+func (h *Hook) DroppedCount() uint64 {
+	return atomic.LoadUint64(&h.droppedCount)
+}
+
+// KeyConflictCount returns how many fields have been dropped because the key transform
+// set by WithKeyTransform transformed their keys to ones of other fields.
+func (h *Hook) KeyConflictCount() uint64 {
+	return atomic.LoadUint64(&h.keyConflictCount)
+}
+
+// warnKeyConflict warns once for each pair of fields whose keys the key transform
+// set by WithKeyTransform transforms to the same key.
+func (h *Hook) warnKeyConflict(key, kept, dropped string) {
+	h.warnOnce("key_conflict:"+kept+"\x00"+dropped, "the fields %q and %q are both sent as %q, so only the field %q is sent; "+
+		"the other conflicts of these fields aren't reported", kept, dropped, key, kept)
+}
 
-	// Oops. Can't connect. No problem. Let's try again.
+// performSend tries to send data with the transport of the hook recursively.
+// written is the number of bytes of data which have already been written to the current connection
+// (see streamTransport).
+// sendRetries is the actual number of attempts to resend message.
+// started is when the first attempt to send data was made, see MaxRetryElapsedTime.
+// entry is the entry formatted into data, which is reported if data is dropped.
+func (h *Hook) performSend(entry *logrus.Entry, data []byte, started time.Time, written, sendRetries int) error {
+	ctx := context.Background()
+	if entry != nil {
+		ctx = withEntryTime(ctx, entry.Time)
+	}
+	written, err := h.send(ctx, h.sender(), data, written, h.writeTimeout(entry))
+	if err == ErrNotConnected {
+		return err
+	}
 	if err != nil {
-		if !h.isNeedToReconnect(reconnectRetries) {
-			// We have reached limit of re-connections.
+		h.reportError(err, OperationWrite)
+		file := fmt.Sprintf("/tmp/logrustash-%d.tmp", time.Now().UnixNano())
+		ioutil.WriteFile(file, data, 0644)
+		fmt.Printf("Wrote message content to %s\n", file)
+		return h.processSendError(err, entry, data, started, written, sendRetries)
+	}
+
+	return nil
+}
+
+func (h *Hook) processSendError(err error, entry *logrus.Entry, data []byte, started time.Time, written, sendRetries int) error {
+	if isMessageTooLong(err) {
+		// Neither resending nor reconnecting help, so drop the message.
+		atomic.AddUint64(&h.oversizedCount, 1)
+		h.drop(entry, DropReasonOversized)
+		return &MessageTooLargeError{Size: len(data), Err: err}
+	}
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) {
+		// The error isn't retryable (e.g. logstash has rejected the message), so the message is lost.
+		h.drop(entry, DropReasonWriteFailed)
+		return &NetworkError{Err: err}
+	}
+
+	if h.isNeedToResendMessage(netErr, sendRetries) {
+		if err := h.waitRetryDelay(sendRetries); err != nil {
+			return err
+		}
+		if h.retryTimeExceeded(started) {
+			return h.abandonRetries(entry, netErr)
+		}
+		if err := h.waitRetryBudget(); err != nil {
 			return err
 		}
+		h.statsd.count("retried", 1)
+		// Resume from where the failed write stopped, otherwise the peer
+		// would get the beginning of the message twice.
+		return h.performSend(entry, data, started, written, sendRetries+1)
+	}
 
-		return h.reconnect(reconnectRetries + 1)
+	if errors.As(err, new(writerError)) {
+		// A writer can't be reconnected, so the message is lost.
+		h.drop(entry, DropReasonWriteFailed)
+		return &DroppedError{Reason: "write failed", Err: netErr}
 	}
 
-	h.Lock()
-	h.conn = conn
-	h.Unlock()
+	// A reconnect counts as a retry, otherwise a connection which is established
+	// but fails every write would make the hook resend the message forever.
+	// The message is resent over a new connection once more than MaxSendRetries though,
+	// so a lost connection doesn't take away the retries of temporary errors.
+	// Only the connection of the hook can be reconnected, the other transports manage their own.
+	conn, ok := h.sender().(connTransport)
+	if ok && !netErr.Temporary() && h.MaxReconnectRetries > 0 && sendRetries <= h.MaxSendRetries {
+		if err := conn.reconnect(0); err != nil {
+			return &NetworkError{Err: fmt.Errorf("Couldn't reconnect to logstash: %w. The reason of reconnect: %s", err, netErr)}
+		}
 
-	return nil
+		if h.retryTimeExceeded(started) {
+			return h.abandonRetries(entry, netErr)
+		}
+		// The new connection doesn't have any part of the message.
+		if err := h.waitRetryBudget(); err != nil {
+			return err
+		}
+		h.statsd.count("retried", 1)
+		return h.performSend(entry, data, started, 0, sendRetries+1)
+	}
+
+	// All the retries have failed, so the message is lost.
+	h.drop(entry, DropReasonWriteFailed)
+	return &NetworkError{Err: err}
 }
 
 func (h *Hook) isNeedToResendMessage(err net.Error, sendRetries int) bool {
 	return (err.Temporary() || err.Timeout()) && sendRetries < h.MaxSendRetries
 }
-
-func (h *Hook) isNeedToReconnect(reconnectRetries int) bool {
-	return reconnectRetries < h.MaxReconnectRetries
-}
-
-// Levels specifies "active" log levels.
-// Log messages with this levels will be sent to logstash.
-func (h *Hook) Levels() []logrus.Level {
-	return []logrus.Level{
-		logrus.PanicLevel,
-		logrus.FatalLevel,
-		logrus.ErrorLevel,
-		logrus.WarnLevel,
-		logrus.InfoLevel,
-		logrus.DebugLevel,
-	}
-}
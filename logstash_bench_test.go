@@ -0,0 +1,118 @@
+package logrustash
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// discardConnMock accepts and discards all writes.
+type discardConnMock struct {
+	ConnMock
+}
+
+func (discardConnMock) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func benchmarkEntry() *logrus.Entry {
+	return &logrus.Entry{
+		Message: "user logged in",
+		Level:   logrus.InfoLevel,
+		Time:    time.Now(),
+		Data: logrus.Fields{
+			"user_id":  42,
+			"username": "alice",
+			"duration": 1.5,
+			"success":  true,
+			"err":      fmt.Errorf("connection refused"),
+		},
+	}
+}
+
+func BenchmarkFire(b *testing.B) {
+	b.Run("sync", func(b *testing.B) {
+		hook, err := NewHookWithConn(discardConnMock{}, "bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		entry := benchmarkEntry()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := hook.Fire(entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, bufferSize := range []int{0, 16, 1024, 8192} {
+		b.Run(fmt.Sprintf("async-buffer-%d", bufferSize), func(b *testing.B) {
+			hook := newHook(discardConnMock{}, "bench", make(logrus.Fields), "", nil)
+			hook.AsyncBufferSize = bufferSize
+			hook.WaitUntilBufferFrees = true
+			hook.makeAsync()
+			defer hook.Close()
+			entry := benchmarkEntry()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := hook.Fire(entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+			// Measure the delivery of all the messages, not just queueing.
+			if err := hook.Flush(0); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+func BenchmarkSendMessage(b *testing.B) {
+	hook, err := NewHookWithFieldsAndConn(discardConnMock{}, "bench", logrus.Fields{"env": "production", "host": "web-1"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	entry := benchmarkEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := hook.sendMessage(copyEntry(entry)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogstashFormatter(b *testing.B) {
+	formatter := &LogstashFormatter{Type: "bench"}
+	entry := benchmarkEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := formatter.Format(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReconnect(b *testing.B) {
+	factory := func(protocol, address string) (net.Conn, error) {
+		return ConnMock{buff: bytes.NewBufferString("")}, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "bench", WithConnFactory(factory))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := (connTransport{hook}).reconnect(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,48 +3,202 @@ package logrustash
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/sirupsen/logrus"
 )
 
-const defaultTimestampFormat = time.RFC3339
+const (
+	defaultTimestampFormat   = time.RFC3339
+	defaultLevelFieldName    = "level"
+	defaultMessageFieldName  = "message"
+	defaultTagsFieldName     = "tags"
+	defaultTypeKey           = "type"
+	defaultCallerFileKey     = "caller.file"
+	defaultCallerLineKey     = "caller.line"
+	defaultCallerFunctionKey = "caller.function"
+	fieldsTruncatedKey       = "fields_truncated"
+)
 
 // LogstashFormatter generates json in logstash format.
 // Logstash site: http://logstash.net/
 type LogstashFormatter struct {
 	Type string // if not empty use for logstash type field.
 
+	// TypeKey is the name of the field with Type, e.g. "service". Default: "type".
+	TypeKey string
+
+	// LogstashVersion is sent in the "@version" field (as a string, like Logstash does). Default: 1.
+	LogstashVersion int
+
+	// MessageFieldName is the name of the field with the message, e.g. "msg". Default: "message".
+	MessageFieldName string
+
+	// LevelFieldName is the name of the field with the level, e.g. "severity". Default: "level".
+	LevelFieldName string
+
+	// LevelValueMapper, if set, returns the value of the level field instead of level.String(),
+	// e.g. LevelUpperCase or LevelSyslogSeverity.
+	LevelValueMapper func(logrus.Level) string
+
 	// TimestampFormat sets the format used for timestamps.
 	TimestampFormat string
+
+	// TimestampFunc, if set, is used instead of TimestampFormat to generate the value
+	// of "@timestamp", e.g. TimestampEpochMillis. Numbers are emitted as JSON numbers.
+	TimestampFunc func(time.Time) interface{}
+
+	// Location, if set, is used to convert timestamps before formatting.
+	Location *time.Location
+
+	// Sanitize removes C0 control characters and repairs invalid UTF-8
+	// in the message and in string field values (including nested maps and slices).
+	Sanitize bool
+	// SanitizeKeepWhitespace makes Sanitize keep '\n' and '\t'.
+	SanitizeKeepWhitespace bool
+
+	// LargeUintAsString makes unsigned integers greater than math.MaxInt64 to be
+	// emitted as strings, since many JSON consumers can't represent them.
+	// Other integers are always emitted as JSON numbers without precision loss.
+	LargeUintAsString bool
+
+	// StringValues makes numbers and booleans in the entry fields (including nested maps
+	// and slices) to be emitted as JSON strings, e.g. to avoid mapping conflicts in
+	// Elasticsearch when the same field holds values of different types.
+	// By default values keep their types: integers, floats and booleans are emitted
+	// as JSON numbers and booleans.
+	StringValues bool
+
+	// CallerFileKey, CallerLineKey and CallerFunctionKey set the names of the caller fields, which are
+	// sent when the logger reports the caller. Defaults: "caller.file", "caller.line" and "caller.function".
+	CallerFileKey     string
+	CallerLineKey     string
+	CallerFunctionKey string
+	// CallerTrimPrefix is removed from the caller file path (e.g. GOPATH or the module root).
+	CallerTrimPrefix string
+
+	// Redactor, if set, replaces the values of sensitive fields with RedactedValue.
+	Redactor *Redactor
+
+	// KeyTransform, if set, is applied to the keys of the entry fields (not to
+	// the fields generated by the formatter, like "@timestamp" or "message").
+	// If several keys are transformed to the same key only the lowest original
+	// key is kept and the conflict is counted (see KeyConflictCount).
+	KeyTransform func(string) string
+
+	// FieldCap, if positive, limits how many entry fields (as sent, i.e. after KeyTransform
+	// and with the "fields." prefix added to the keys of the message, level and type fields)
+	// are sent: only the first FieldCap fields sorted by key are kept and the field "fields_truncated"
+	// is set to true. The fields generated by the formatter don't count. The key "fields_truncated"
+	// is reserved: an entry field with it is sent as "fields.fields_truncated".
+	FieldCap int
+
+	// Tags are sent as a JSON array in the field TagsFieldName (default: "tags").
+	// If the entry has the field as a []string, Tags are appended to it.
+	Tags          []string
+	TagsFieldName string
+
+	// MetadataKeys are the keys (as sent, i.e. after KeyTransform) of the entry fields which are
+	// sent inside the "@metadata" object, which is available to the filters and outputs of Logstash
+	// but isn't indexed, e.g. to route the messages.
+	MetadataKeys []string
+
+	sanitizedCount   uint64
+	keyConflictCount uint64
+	onKeyConflict    func(key, kept, dropped string) // called for each conflict of KeyTransform, if set
 }
 
-// Format formats log message.
+// Format formats log message. It implements logrus.Formatter, so like the other formatters
+// of logrus it terminates the message with a newline, see FormatWithPrefix.
 func (f *LogstashFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	return f.FormatWithPrefix(entry, "")
 }
 
-// FormatWithPrefix removes prefix from keys and formats log message.
+// FormatWithPrefix removes prefix from keys and formats log message terminated with a newline,
+// so the formatter can be used with a logger or a writer on its own. The hook doesn't use it:
+// it serializes the messages without the newline and frames them as set by WithFraming,
+// so WithFraming and WithNewlineDelimiter have no effect on the output of the formatter.
 func (f *LogstashFormatter) FormatWithPrefix(entry *logrus.Entry, prefix string) ([]byte, error) {
+	serialized, err := f.formatJSON(entry, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return append(serialized, '\n'), nil
+}
+
+// formatJSON removes prefix from keys and serializes log message to JSON
+// without a trailing newline.
+func (f *LogstashFormatter) formatJSON(entry *logrus.Entry, prefix string) ([]byte, error) {
 	fields := make(logrus.Fields)
+	var originalKeys map[string]string // transformed key -> original key
+	if f.KeyTransform != nil {
+		originalKeys = make(map[string]string, len(entry.Data))
+	}
 	for k, v := range entry.Data {
 		// Remove the prefix when sending the fields to logstash
 		if prefix != "" && strings.HasPrefix(k, prefix) {
 			k = strings.TrimPrefix(k, prefix)
 		}
 
+		if lazy, ok := v.(LazyField); ok {
+			v = lazy.evaluate()
+		}
+
+		var value interface{}
 		switch v := v.(type) {
 		case error:
 			// Otherwise errors are ignored by `encoding/json`
 			// https://github.com/Sirupsen/logrus/issues/377
-			fields[k] = v.Error()
+			value = v.Error()
 		default:
-			fields[k] = v
+			value = v
+		}
+
+		if f.Redactor != nil {
+			value = f.Redactor.redact(k, value)
 		}
+		if f.LargeUintAsString {
+			value = largeUintToString(value)
+		}
+		if f.StringValues {
+			value = scalarsToStrings(value)
+		}
+
+		if f.KeyTransform != nil {
+			transformedKey := f.KeyTransform(k)
+			if originalKey, ok := originalKeys[transformedKey]; ok {
+				// Several keys are transformed to the same one:
+				// deterministically keep the lowest original key.
+				atomic.AddUint64(&f.keyConflictCount, 1)
+				if f.onKeyConflict != nil {
+					f.onKeyConflict(transformedKey, min(originalKey, k), max(originalKey, k))
+				}
+				if originalKey < k {
+					continue
+				}
+			}
+			originalKeys[transformedKey] = k
+			k = transformedKey
+		}
+
+		fields[k] = value
 	}
+	f.moveReservedFields(fields)
+	f.capFields(fields)
+	f.addTags(fields)
+	f.moveMetadata(fields)
 
 	fields["@version"] = "1"
+	if f.LogstashVersion != 0 {
+		fields["@version"] = strconv.Itoa(f.LogstashVersion)
+	}
 
 	timeStampFormat := f.TimestampFormat
 
@@ -52,34 +206,307 @@ func (f *LogstashFormatter) FormatWithPrefix(entry *logrus.Entry, prefix string)
 		timeStampFormat = defaultTimestampFormat
 	}
 
-	fields["@timestamp"] = entry.Time.Format(timeStampFormat)
-
-	// set message field
-	v, ok := entry.Data["message"]
-	if ok {
-		fields["fields.message"] = v
+	timestamp := entry.Time
+	if f.Location != nil {
+		timestamp = timestamp.In(f.Location)
 	}
-	fields["message"] = entry.Message
+	if f.TimestampFunc != nil {
+		fields["@timestamp"] = f.TimestampFunc(timestamp)
+	} else {
+		fields["@timestamp"] = timestamp.Format(timeStampFormat)
+	}
+
+	// set message field (an entry field with its name has been moved by moveReservedFields)
+	fields[stringOrDefault(f.MessageFieldName, defaultMessageFieldName)] = entry.Message
 
 	// set level field
-	v, ok = entry.Data["level"]
-	if ok {
-		fields["fields.level"] = v
+	levelFieldName := stringOrDefault(f.LevelFieldName, defaultLevelFieldName)
+	if f.LevelValueMapper != nil {
+		fields[levelFieldName] = f.LevelValueMapper(entry.Level)
+	} else {
+		fields[levelFieldName] = entry.Level.String()
+	}
+
+	// set caller fields
+	if entry.Caller != nil {
+		fields[stringOrDefault(f.CallerFileKey, defaultCallerFileKey)] = strings.TrimPrefix(entry.Caller.File, f.CallerTrimPrefix)
+		fields[stringOrDefault(f.CallerLineKey, defaultCallerLineKey)] = entry.Caller.Line
+		fields[stringOrDefault(f.CallerFunctionKey, defaultCallerFunctionKey)] = entry.Caller.Function
 	}
-	fields["level"] = entry.Level.String()
 
 	// set type field
 	if f.Type != "" {
-		v, ok = entry.Data["type"]
-		if ok {
-			fields["fields.type"] = v
+		fields[stringOrDefault(f.TypeKey, defaultTypeKey)] = f.Type
+	}
+
+	if f.Sanitize {
+		s := sanitizer{keepWhitespace: f.SanitizeKeepWhitespace}
+		for k, v := range fields {
+			fields[k] = s.sanitize(v)
+		}
+		if s.changed {
+			atomic.AddUint64(&f.sanitizedCount, 1)
 		}
-		fields["type"] = f.Type
 	}
 
 	serialized, err := json.Marshal(fields)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to marshal fields to JSON, %v", err)
 	}
-	return append(serialized, '\n'), nil
+	return serialized, nil
+}
+
+// addTags adds Tags to the tags field.
+func (f *LogstashFormatter) addTags(fields logrus.Fields) {
+	if len(f.Tags) == 0 {
+		return
+	}
+
+	name := stringOrDefault(f.TagsFieldName, defaultTagsFieldName)
+	switch existing := fields[name].(type) {
+	case []string:
+		// The slice of the entry must not be modified.
+		tags := make([]string, 0, len(existing)+len(f.Tags))
+		fields[name] = append(append(tags, existing...), f.Tags...)
+	case []interface{}:
+		tags := make([]interface{}, 0, len(existing)+len(f.Tags))
+		tags = append(tags, existing...)
+		for _, tag := range f.Tags {
+			tags = append(tags, tag)
+		}
+		fields[name] = tags
+	default:
+		fields[name] = f.Tags
+	}
+}
+
+// moveMetadata moves the fields with MetadataKeys into the "@metadata" object.
+func (f *LogstashFormatter) moveMetadata(fields logrus.Fields) {
+	if len(f.MetadataKeys) == 0 {
+		return
+	}
+
+	metadata := make(map[string]interface{}, len(f.MetadataKeys))
+	switch existing := fields["@metadata"].(type) {
+	case map[string]interface{}:
+		for k, v := range existing {
+			metadata[k] = v
+		}
+	case logrus.Fields:
+		for k, v := range existing {
+			metadata[k] = v
+		}
+	}
+	for _, k := range f.MetadataKeys {
+		if v, ok := fields[k]; ok {
+			metadata[k] = v
+			delete(fields, k)
+		}
+	}
+	if len(metadata) > 0 {
+		fields["@metadata"] = metadata
+	}
+}
+
+// moveReservedFields adds the "fields." prefix to the keys of the entry fields (as processed above:
+// redacted, transformed, etc.) which have the names of the message, level and type fields set
+// by the formatter or, with FieldCap, the name "fields_truncated", so they aren't replaced
+// and are counted by FieldCap.
+func (f *LogstashFormatter) moveReservedFields(fields logrus.Fields) {
+	keys := []string{
+		stringOrDefault(f.MessageFieldName, defaultMessageFieldName),
+		stringOrDefault(f.LevelFieldName, defaultLevelFieldName),
+	}
+	if f.Type != "" {
+		keys = append(keys, stringOrDefault(f.TypeKey, defaultTypeKey))
+	}
+	if f.FieldCap > 0 {
+		keys = append(keys, fieldsTruncatedKey)
+	}
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			fields["fields."+k] = v
+			delete(fields, k)
+		}
+	}
+}
+
+// capFields removes the fields beyond FieldCap, see LogstashFormatter.FieldCap.
+func (f *LogstashFormatter) capFields(fields logrus.Fields) {
+	if f.FieldCap <= 0 || len(fields) <= f.FieldCap {
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys[f.FieldCap:] {
+		delete(fields, k)
+	}
+	fields[fieldsTruncatedKey] = true
+}
+
+// KeyConflictCount returns how many fields have been dropped because KeyTransform
+// transformed their keys to ones of other fields.
+func (f *LogstashFormatter) KeyConflictCount() uint64 {
+	return atomic.LoadUint64(&f.keyConflictCount)
+}
+
+// SanitizedCount returns how many log messages have been modified by Sanitize.
+func (f *LogstashFormatter) SanitizedCount() uint64 {
+	return atomic.LoadUint64(&f.sanitizedCount)
+}
+
+func stringOrDefault(s, defaultValue string) string {
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// largeUintToString converts unsigned integers which don't fit into int64 to strings,
+// descending into nested maps and slices.
+func largeUintToString(v interface{}) interface{} {
+	switch v := v.(type) {
+	case uint64:
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10)
+		}
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return strconv.FormatUint(uint64(v), 10)
+		}
+	case []uint64:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = largeUintToString(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = largeUintToString(item)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = largeUintToString(item)
+		}
+		return res
+	case logrus.Fields:
+		res := make(logrus.Fields, len(v))
+		for k, item := range v {
+			res[k] = largeUintToString(item)
+		}
+		return res
+	}
+
+	return v
+}
+
+// scalarsToStrings converts numbers and booleans to strings,
+// descending into nested maps and slices.
+func scalarsToStrings(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string:
+		return v
+	case json.Number:
+		return v.String()
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = scalarsToStrings(item)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = scalarsToStrings(item)
+		}
+		return res
+	case logrus.Fields:
+		res := make(logrus.Fields, len(v))
+		for k, item := range v {
+			res[k] = scalarsToStrings(item)
+		}
+		return res
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v)
+	default:
+		return v
+	}
+}
+
+// TimestampEpochMillis is a TimestampFunc which returns milliseconds since the Unix epoch,
+// e.g. 1257894000123.
+func TimestampEpochMillis(t time.Time) interface{} {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// TimestampEpochSeconds is a TimestampFunc which returns seconds since the Unix epoch
+// with millisecond precision, e.g. 1257894000.123.
+func TimestampEpochSeconds(t time.Time) interface{} {
+	// Formatted here, since the float64 closest to the value may be printed with extra digits.
+	millis := t.UnixNano() / int64(time.Millisecond)
+	return json.Number(strconv.FormatFloat(float64(millis)/1000, 'f', -1, 64))
+}
+
+// LevelUpperCase is a LevelValueMapper which returns the level in upper case, e.g. "WARNING".
+func LevelUpperCase(level logrus.Level) string {
+	return strings.ToUpper(level.String())
+}
+
+// LevelSyslogSeverity is a LevelValueMapper which returns the syslog severity code
+// of the level (RFC 5424), e.g. "4" for logrus.WarnLevel. Debug and trace are both "7".
+func LevelSyslogSeverity(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel:
+		return "0" // Emergency
+	case logrus.FatalLevel:
+		return "2" // Critical
+	case logrus.ErrorLevel:
+		return "3"
+	case logrus.WarnLevel:
+		return "4"
+	case logrus.InfoLevel:
+		return "6" // Informational
+	default:
+		return "7" // Debug
+	}
+}
+
+// SnakeCase is a KeyTransform which converts CamelCase, kebab-case and
+// space separated keys to snake_case. For example "userID" becomes "user_id",
+// "HTTPServer" becomes "http_server" and "request-id" becomes "request_id".
+func SnakeCase(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	b.Grow(len(key) + 4)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 {
+				prev := runes[i-1]
+				nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
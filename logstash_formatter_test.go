@@ -4,8 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/url"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -70,3 +77,525 @@ func TestLogstashFormatter(t *testing.T) {
 		t.Errorf("expected bool to be '%v' but got '%v'", true, data["bool"])
 	}
 }
+
+func TestLogstashFormatterLocation(t *testing.T) {
+	fTime := time.Date(2009, time.November, 10, 15, 4, 0, 0, time.FixedZone("", 2*60*60))
+	tt := []struct {
+		location *time.Location
+		expected string
+	}{
+		{nil, "2009-11-10T15:04:00+02:00"},
+		{time.UTC, "2009-11-10T13:04:00Z"},
+		{time.FixedZone("", -5*60*60), "2009-11-10T08:04:00-05:00"},
+	}
+
+	for _, te := range tt {
+		lf := LogstashFormatter{Location: te.location}
+		b, err := lf.Format(&logrus.Entry{Time: fTime, Data: logrus.Fields{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data["@timestamp"] != te.expected {
+			t.Errorf("expected @timestamp to be '%s' but got '%s'", te.expected, data["@timestamp"])
+		}
+	}
+}
+
+func TestLogstashFormatterTimestampFunc(t *testing.T) {
+	fTime := time.Date(2009, time.November, 10, 15, 4, 0, 123000000, time.FixedZone("", 2*60*60))
+	tt := []struct {
+		fn       func(time.Time) interface{}
+		expected string
+	}{
+		{TimestampEpochMillis, `1257858240123`},
+		{TimestampEpochSeconds, `1257858240.123`},
+		{func(t time.Time) interface{} { return t.Format(time.Kitchen) }, `"1:04PM"`},
+	}
+
+	for _, te := range tt {
+		// The location is applied before the function is called.
+		lf := LogstashFormatter{TimestampFunc: te.fn, Location: time.UTC}
+		b, err := lf.Format(&logrus.Entry{Time: fTime, Data: logrus.Fields{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		if string(data["@timestamp"]) != te.expected {
+			t.Errorf("expected @timestamp to be %s but got %s", te.expected, data["@timestamp"])
+		}
+	}
+}
+
+func TestLogstashFormatterIntegerPrecision(t *testing.T) {
+	fields := logrus.Fields{
+		"below":   int64(1<<53 - 1),
+		"limit":   int64(1 << 53),
+		"above":   int64(1<<53 + 1),
+		"maxint":  int64(math.MaxInt64),
+		"minint":  int64(math.MinInt64),
+		"maxuint": uint64(math.MaxUint64),
+		"nested":  map[string]interface{}{"id": uint64(1<<53 + 1)},
+	}
+
+	tt := []struct {
+		largeUintAsString bool
+		expected          map[string]string
+	}{
+		{false, map[string]string{
+			"below":   `9007199254740991`,
+			"limit":   `9007199254740992`,
+			"above":   `9007199254740993`,
+			"maxint":  `9223372036854775807`,
+			"minint":  `-9223372036854775808`,
+			"maxuint": `18446744073709551615`,
+			"nested":  `{"id":9007199254740993}`,
+		}},
+		{true, map[string]string{
+			"above":   `9007199254740993`,
+			"maxint":  `9223372036854775807`,
+			"maxuint": `"18446744073709551615"`,
+			"nested":  `{"id":9007199254740993}`,
+		}},
+	}
+
+	for _, te := range tt {
+		lf := LogstashFormatter{LargeUintAsString: te.largeUintAsString}
+		b, err := lf.Format(logrus.WithFields(fields))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		for key, expected := range te.expected {
+			if string(data[key]) != expected {
+				t.Errorf("expected %s to be encoded as %s but got %s", key, expected, data[key])
+			}
+		}
+	}
+}
+
+func TestLogstashFormatterTypes(t *testing.T) {
+	fields := logrus.Fields{
+		"int":     42,
+		"int8":    int8(-8),
+		"uint16":  uint16(16),
+		"float32": float32(1.5),
+		"float64": 3.14,
+		"bool":    true,
+		"string":  "42",
+		"nil":     nil,
+		"nested":  map[string]interface{}{"count": 7, "ok": false},
+		"list":    []interface{}{1, "a"},
+	}
+
+	tt := []struct {
+		stringValues bool
+		expected     map[string]string
+	}{
+		{false, map[string]string{
+			"int":     `42`,
+			"int8":    `-8`,
+			"uint16":  `16`,
+			"float32": `1.5`,
+			"float64": `3.14`,
+			"bool":    `true`,
+			"string":  `"42"`,
+			"nil":     `null`,
+			"nested":  `{"count":7,"ok":false}`,
+			"list":    `[1,"a"]`,
+		}},
+		{true, map[string]string{
+			"int":     `"42"`,
+			"int8":    `"-8"`,
+			"uint16":  `"16"`,
+			"float32": `"1.5"`,
+			"float64": `"3.14"`,
+			"bool":    `"true"`,
+			"string":  `"42"`,
+			"nil":     `null`,
+			"nested":  `{"count":"7","ok":"false"}`,
+			"list":    `["1","a"]`,
+		}},
+	}
+
+	for _, te := range tt {
+		lf := LogstashFormatter{StringValues: te.stringValues}
+		b, err := lf.Format(logrus.WithFields(fields))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		for key, expected := range te.expected {
+			if string(data[key]) != expected {
+				t.Errorf("expected %s to be encoded as %s but got %s", key, expected, data[key])
+			}
+		}
+	}
+}
+
+func TestLogstashFormatterCaller(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Dir(file) + "/"
+
+	tt := []struct {
+		formatter    *LogstashFormatter
+		fileKey      string
+		lineKey      string
+		functionKey  string
+		expectedFile string
+	}{
+		{&LogstashFormatter{}, "caller.file", "caller.line", "caller.function", file},
+		{&LogstashFormatter{CallerFileKey: "file", CallerLineKey: "line", CallerFunctionKey: "func", CallerTrimPrefix: dir},
+			"file", "line", "func", "logstash_formatter_test.go"},
+	}
+
+	for _, te := range tt {
+		buff := bytes.NewBufferString("")
+		logger := logrus.New()
+		logger.Out = buff
+		logger.Formatter = te.formatter
+		logger.SetReportCaller(true)
+		logger.Info("hello caller")
+
+		var data map[string]interface{}
+		if err := json.Unmarshal(buff.Bytes(), &data); err != nil {
+			t.Fatal(err)
+		}
+		if data[te.fileKey] != te.expectedFile {
+			t.Errorf("expected %s to be '%s' but got '%v'", te.fileKey, te.expectedFile, data[te.fileKey])
+		}
+		if line, ok := data[te.lineKey].(float64); !ok || line <= 0 {
+			t.Errorf("expected %s to be a positive number but got '%v'", te.lineKey, data[te.lineKey])
+		}
+		if function, _ := data[te.functionKey].(string); !strings.HasSuffix(function, ".TestLogstashFormatterCaller") {
+			t.Errorf("expected %s to point at the test function but got '%v'", te.functionKey, data[te.functionKey])
+		}
+	}
+}
+
+func TestLogstashFormatterNoCaller(t *testing.T) {
+	lf := LogstashFormatter{}
+	b, err := lf.Format(&logrus.Entry{Message: "no caller", Data: logrus.Fields{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"caller.file", "caller.line", "caller.function"} {
+		if _, ok := data[key]; ok {
+			t.Errorf("expected %s to be absent when the caller is not reported", key)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tt := []struct {
+		key      string
+		expected string
+	}{
+		{"snake_case", "snake_case"},
+		{"CamelCase", "camel_case"},
+		{"camelCase", "camel_case"},
+		{"kebab-case", "kebab_case"},
+		{"userID", "user_id"},
+		{"HTTPServer", "http_server"},
+		{"version2Name", "version2_name"},
+		{"with space", "with_space"},
+		{"nested.CamelKey", "nested.camel_key"},
+		{"", ""},
+	}
+	for _, te := range tt {
+		if res := SnakeCase(te.key); res != te.expected {
+			t.Errorf("expected SnakeCase(%q) to be %q but got %q", te.key, te.expected, res)
+		}
+	}
+}
+
+func TestLogstashFormatterKeyTransform(t *testing.T) {
+	lf := LogstashFormatter{Type: "app", KeyTransform: SnakeCase}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"requestID": "a",
+		"user-name": "b",
+		"userId":    "first",
+		"user_id":   "second",
+	})
+	entry.Message = "msg"
+	entry.Level = logrus.InfoLevel
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"request_id": "a",
+		"user_name":  "b",
+		"user_id":    "first",
+	}
+	for k, v := range expected {
+		if data[k] != v {
+			t.Errorf("expected data[%s] to be '%v' but got '%v'", k, v, data[k])
+		}
+	}
+	for _, k := range []string{"requestID", "user-name", "userId"} {
+		if _, ok := data[k]; ok {
+			t.Errorf("expected %s to be transformed", k)
+		}
+	}
+	if lf.KeyConflictCount() != 1 {
+		t.Errorf("expected key conflict count to be 1 but got %d", lf.KeyConflictCount())
+	}
+}
+
+func TestLogstashFormatterKeyTransformReservedKeys(t *testing.T) {
+	lf := LogstashFormatter{Type: "app", KeyTransform: strings.ToUpper}
+
+	entry := logrus.WithFields(logrus.Fields{"custom": "value"})
+	entry.Message = "msg"
+	entry.Level = logrus.InfoLevel
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"@timestamp", "@version", "message", "level", "type", "CUSTOM"} {
+		if _, ok := data[k]; !ok {
+			t.Errorf("expected data to have '%s' but got '%v'", k, data)
+		}
+	}
+}
+
+func TestLogstashFormatterMetadataKeys(t *testing.T) {
+	lf := LogstashFormatter{Type: "app", MetadataKeys: []string{"index", "pipeline", "missing"}}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"index":     "logs-checkout",
+		"pipeline":  "geoip",
+		"user":      "alice",
+		"@metadata": map[string]interface{}{"beat": "app"},
+	})
+	entry.Message = "msg"
+	entry.Level = logrus.InfoLevel
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, _ := data["@metadata"].(map[string]interface{})
+	expected := map[string]interface{}{"index": "logs-checkout", "pipeline": "geoip", "beat": "app"}
+	if len(metadata) != len(expected) {
+		t.Errorf("expected @metadata to be %v but got %v", expected, data["@metadata"])
+	}
+	for k, v := range expected {
+		if metadata[k] != v {
+			t.Errorf("expected @metadata[%s] to be '%v' but got '%v'", k, v, metadata[k])
+		}
+	}
+	for _, k := range []string{"index", "pipeline", "missing"} {
+		if _, ok := data[k]; ok {
+			t.Errorf("expected %s to be sent only in @metadata", k)
+		}
+	}
+	if data["user"] != "alice" {
+		t.Errorf("expected user to be 'alice' but got '%v'", data["user"])
+	}
+}
+
+func TestLogstashFormatterLogstashVersion(t *testing.T) {
+	for v, expected := range map[int]string{0: "1", 1: "1", 2: "2"} {
+		lf := LogstashFormatter{LogstashVersion: v}
+		b, err := lf.Format(&logrus.Entry{Message: "msg", Data: logrus.Fields{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data["@version"] != expected {
+			t.Errorf("expected @version to be '%s' for version %d but got '%v'", expected, v, data["@version"])
+		}
+	}
+}
+
+func TestLogstashFormatterLevelFieldName(t *testing.T) {
+	lf := LogstashFormatter{LevelFieldName: "severity"}
+	b, err := lf.Format(&logrus.Entry{Message: "msg", Level: logrus.WarnLevel, Data: logrus.Fields{"severity": "high"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["severity"] != "warning" || data["fields.severity"] != "high" {
+		t.Errorf("expected severity 'warning' and fields.severity 'high' but got '%v' and '%v'", data["severity"], data["fields.severity"])
+	}
+	if _, ok := data["level"]; ok {
+		t.Error("expected no level field")
+	}
+}
+
+func TestLogstashFormatterMessageFieldName(t *testing.T) {
+	lf := LogstashFormatter{MessageFieldName: "msg"}
+	b, err := lf.Format(&logrus.Entry{Message: "hello", Data: logrus.Fields{"msg": "field"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["msg"] != "hello" || data["fields.msg"] != "field" {
+		t.Errorf("expected msg 'hello' and fields.msg 'field' but got '%v' and '%v'", data["msg"], data["fields.msg"])
+	}
+	if _, ok := data["message"]; ok {
+		t.Error("expected no message field")
+	}
+}
+
+func TestLogstashFormatterLevelValueMapper(t *testing.T) {
+	tt := []struct {
+		mapper   func(logrus.Level) string
+		level    logrus.Level
+		expected string
+	}{
+		{nil, logrus.WarnLevel, "warning"},
+		{LevelUpperCase, logrus.WarnLevel, "WARNING"},
+		{LevelSyslogSeverity, logrus.PanicLevel, "0"},
+		{LevelSyslogSeverity, logrus.FatalLevel, "2"},
+		{LevelSyslogSeverity, logrus.ErrorLevel, "3"},
+		{LevelSyslogSeverity, logrus.WarnLevel, "4"},
+		{LevelSyslogSeverity, logrus.InfoLevel, "6"},
+		{LevelSyslogSeverity, logrus.DebugLevel, "7"},
+		{LevelSyslogSeverity, logrus.TraceLevel, "7"},
+	}
+	for _, te := range tt {
+		lf := LogstashFormatter{LevelValueMapper: te.mapper}
+		b, err := lf.Format(&logrus.Entry{Message: "msg", Level: te.level, Data: logrus.Fields{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data["level"] != te.expected {
+			t.Errorf("expected level %s to be '%s' but got '%v'", te.level, te.expected, data["level"])
+		}
+	}
+}
+
+func TestLogstashFormatterTags(t *testing.T) {
+	entryTags := []string{"billing"}
+	tt := []struct {
+		fieldName string
+		data      logrus.Fields
+		expected  []interface{}
+	}{
+		{"", logrus.Fields{}, []interface{}{"prod", "eu"}},
+		{"labels", logrus.Fields{"labels": entryTags}, []interface{}{"billing", "prod", "eu"}},
+		{"", logrus.Fields{"tags": []interface{}{"a"}}, []interface{}{"a", "prod", "eu"}},
+		{"", logrus.Fields{"tags": "replaced"}, []interface{}{"prod", "eu"}},
+	}
+	for _, te := range tt {
+		lf := LogstashFormatter{TagsFieldName: te.fieldName, Tags: []string{"prod", "eu"}}
+		b, err := lf.Format(&logrus.Entry{Message: "msg", Data: te.data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		fieldName := stringOrDefault(te.fieldName, "tags")
+		if !reflect.DeepEqual(data[fieldName], te.expected) {
+			t.Errorf("expected %s to be %v but got %v", fieldName, te.expected, data[fieldName])
+		}
+	}
+	if len(entryTags) != 1 || cap(entryTags) != 1 {
+		t.Errorf("expected the tags of the entry to be left intact but got %v", entryTags)
+	}
+}
+
+func TestLogstashFormatterFieldCap(t *testing.T) {
+	lf := LogstashFormatter{Type: "app", FieldCap: 2}
+	for _, test := range []struct {
+		fields    logrus.Fields
+		expected  []string
+		truncated bool
+	}{
+		{logrus.Fields{"d": 4, "b": 2, "c": 3, "a": 1}, []string{"a", "b"}, true},
+		{logrus.Fields{"b": 2, "a": 1}, []string{"a", "b"}, false},
+	} {
+		b, err := lf.Format(&logrus.Entry{Message: "msg", Data: test.fields})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{"@timestamp", "@version", "message", "level", "type"} {
+			if _, ok := data[k]; !ok {
+				t.Errorf("expected the field %s to be sent", k)
+			}
+		}
+		var keys []string
+		for k := range test.fields {
+			if _, ok := data[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		if fmt.Sprint(keys) != fmt.Sprint(test.expected) {
+			t.Errorf("expected the fields %v but got %v", test.expected, keys)
+		}
+		if truncated, _ := data["fields_truncated"].(bool); truncated != test.truncated {
+			t.Errorf("expected fields_truncated to be %v but got %v", test.truncated, data["fields_truncated"])
+		}
+	}
+	// The entry fields moved to fields.* are counted and a field "fields_truncated" isn't replaced.
+	b, err := lf.Format(&logrus.Entry{Message: "msg", Data: logrus.Fields{"message": "m", "fields_truncated": "user", "z": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["message"] != "msg" || data["fields.message"] != "m" || data["fields.fields_truncated"] != "user" {
+		t.Errorf("expected the entry fields to be moved to fields.* but got %v", data)
+	}
+	if _, ok := data["z"]; ok || data["fields_truncated"] != true {
+		t.Errorf("expected the field z to be truncated but got %v", data)
+	}
+}
@@ -3,13 +3,19 @@ package logrustash
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
 )
 
 func TestLogstashHook(t *testing.T) {
@@ -193,6 +199,15 @@ func TestSettingAttributes(t *testing.T) {
 			}
 			return nil
 		}},
+		{func(h *Hook) {
+			h.DeleteField("name")
+		}, func(h *Hook) error {
+			nField := logrus.Fields{}
+			if !reflect.DeepEqual(h.alwaysSentFields, nField) {
+				return fmt.Errorf("expected alwaysSentFields to be '%v' but got '%v'", nField, h.alwaysSentFields)
+			}
+			return nil
+		}},
 	}
 
 	for _, te := range tt {
@@ -205,6 +220,386 @@ func TestSettingAttributes(t *testing.T) {
 	}
 }
 
+func TestFireAfterDeleteField(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "delete_test", logrus.Fields{"service": "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hook.WithField("request_id", "42")
+	if err := hook.Fire(&logrus.Entry{Message: "start", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	hook.DeleteField("request_id")
+	hook.DeleteField("unknown")
+	if err := hook.Fire(&logrus.Entry{Message: "end", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	var start, end map[string]string
+	if err := dec.Decode(&start); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&end); err != nil {
+		t.Fatal(err)
+	}
+	if start["request_id"] != "42" {
+		t.Errorf("expected request_id to be '%s' but got '%s'", "42", start["request_id"])
+	}
+	if _, ok := end["request_id"]; ok {
+		t.Errorf("expected request_id to be deleted but got '%s'", end["request_id"])
+	}
+	if end["service"] != "api" {
+		t.Errorf("expected service to be '%s' but got '%s'", "api", end["service"])
+	}
+}
+
+func TestAlwaysSentFieldsOverride(t *testing.T) {
+	for _, override := range []bool{false, true} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook, err := NewHookWithFieldsAndConn(conn, "override_test", logrus.Fields{"service": "api"}, WithAlwaysSentFieldsOverride(override))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{"service": "worker", "user": "alice"}}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		expected := "worker"
+		if override {
+			expected = "api"
+		}
+		if res["service"] != expected || res["user"] != "alice" {
+			t.Errorf("expected service '%s' and user 'alice' with override %v but got '%s' and '%s'", expected, override, res["service"], res["user"])
+		}
+		if entry.Data["service"] != "worker" {
+			t.Errorf("expected the entry to be left intact but got service '%v'", entry.Data["service"])
+		}
+	}
+}
+
+// deadlineConnMock records the write deadlines.
+type deadlineConnMock struct {
+	ConnMock
+	deadlines []time.Time
+}
+
+func (c *deadlineConnMock) SetWriteDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func TestLevelBasedTimeout(t *testing.T) {
+	conn := &deadlineConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(conn, "timeout_test", WithLevelBasedTimeout(map[logrus.Level]time.Duration{
+		logrus.PanicLevel: time.Hour,
+		logrus.DebugLevel: time.Millisecond,
+		logrus.WarnLevel:  0,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Timeout = time.Minute
+
+	levels := []logrus.Level{logrus.PanicLevel, logrus.InfoLevel, logrus.DebugLevel, logrus.WarnLevel}
+	for _, level := range levels {
+		started := time.Now()
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Level: level, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		deadline := conn.deadlines[len(conn.deadlines)-1]
+		switch level {
+		case logrus.WarnLevel:
+			if !deadline.IsZero() {
+				t.Errorf("expected no deadline for %s but got %v", level, deadline)
+			}
+			continue
+		case logrus.PanicLevel:
+			started = started.Add(time.Hour)
+		case logrus.InfoLevel:
+			// The level without a timeout of its own uses Timeout.
+			started = started.Add(time.Minute)
+		case logrus.DebugLevel:
+			started = started.Add(time.Millisecond)
+		}
+		if diff := deadline.Sub(started); diff < 0 || diff > time.Second {
+			t.Errorf("expected the deadline of %s to be %v but got %v", level, started, deadline)
+		}
+	}
+}
+
+func TestLevelFields(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConn(conn, "level_fields_test", logrus.Fields{"service": "api"},
+		WithLevelFields(logrus.FatalLevel, logrus.Fields{"alert": "true", "service": "fatal"}),
+		WithLevelFields(logrus.DebugLevel, logrus.Fields{"sampling_rate": "0.1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []*logrus.Entry{
+		{Message: "fatal", Level: logrus.FatalLevel, Data: logrus.Fields{}},
+		{Message: "debug", Level: logrus.DebugLevel, Data: logrus.Fields{"sampling_rate": "1"}},
+		{Message: "info", Level: logrus.InfoLevel, Data: logrus.Fields{}},
+	}
+	for _, entry := range entries {
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	var res [3]map[string]string
+	for i := range res {
+		if err := dec.Decode(&res[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res[0]["alert"] != "true" || res[0]["service"] != "api" {
+		t.Errorf("expected alert 'true' and service 'api' for the fatal entry but got %v", res[0])
+	}
+	if res[1]["sampling_rate"] != "1" || res[1]["alert"] != "" {
+		t.Errorf("expected the field of the entry to win and no alert for the debug entry but got %v", res[1])
+	}
+	if _, ok := res[2]["alert"]; ok || res[2]["sampling_rate"] != "" {
+		t.Errorf("expected no level fields for the info entry but got %v", res[2])
+	}
+}
+
+func TestCustomAppName(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "default_app", WithCustomAppName(func(entry *logrus.Entry) string {
+		service, _ := entry.Data["service"].(string)
+		return service
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"billing", "", "auth"} {
+		entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{}}
+		if service != "" {
+			entry.Data["service"] = service
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"billing", "default_app", "auth"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["type"] != expected {
+			t.Errorf("expected type to be '%s' but got '%s'", expected, res["type"])
+		}
+	}
+}
+
+func TestTypeOverrideField(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithFieldsAndConnAndPrefix(conn, "default_app", nil, "_ls_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	entries := []*logrus.Entry{
+		logger.WithField(DefaultTypeOverrideField, "plugin_a"),
+		logger.WithField("other", "field"),
+		logger.WithField("_ls_"+DefaultTypeOverrideField, "plugin_b"),
+		logger.WithField(DefaultTypeOverrideField, 42),
+	}
+	for _, entry := range entries {
+		entry.Info("hello")
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"plugin_a", "default_app", "plugin_b", "default_app"} {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["type"] != expected {
+			t.Errorf("expected type to be '%s' but got '%v'", expected, res["type"])
+		}
+		for k := range res {
+			if strings.Contains(k, DefaultTypeOverrideField) {
+				t.Errorf("expected the field %s not to be sent", k)
+			}
+		}
+	}
+
+	// The field can be renamed.
+	conn = ConnMock{buff: bytes.NewBufferString("")}
+	hook, err = NewHookWithConn(conn, "default_app", WithTypeOverrideField("plugin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{"plugin": "plugin_c"}}); err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res["plugin"]; ok || res["type"] != "plugin_c" {
+		t.Errorf("expected type 'plugin_c' without the plugin field but got %v", res)
+	}
+}
+
+func TestEntryMiddleware(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	errorCodes := map[interface{}]string{"E42": "disk full"}
+	hook, err := NewHookWithConn(conn, "middleware_test",
+		WithEntryMiddleware(func(entry *logrus.Entry) *logrus.Entry {
+			if entry.Message == "secret" {
+				return nil
+			}
+			entry.Data["error"] = errorCodes[entry.Data["error"]]
+			return entry
+		}),
+		WithEntryMiddleware(func(entry *logrus.Entry) *logrus.Entry {
+			entry.Message = strings.ToUpper(entry.Message)
+			return entry
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &logrus.Entry{Message: "write failed", Data: logrus.Fields{"error": "E42"}}
+	for _, e := range []*logrus.Entry{entry, {Message: "secret", Data: logrus.Fields{}}} {
+		if err := hook.Fire(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entry.Message != "write failed" || entry.Data["error"] != "E42" {
+		t.Errorf("expected the entry to be left intact but got '%s' with error '%v'", entry.Message, entry.Data["error"])
+	}
+	if hook.DroppedCount() != 1 {
+		t.Errorf("expected 1 dropped entry but got %d", hook.DroppedCount())
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	var res map[string]string
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "WRITE FAILED" || res["error"] != "disk full" {
+		t.Errorf("expected message 'WRITE FAILED' and error 'disk full' but got '%s' and '%s'", res["message"], res["error"])
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+}
+
+func TestEnvironmentField(t *testing.T) {
+	for fieldName, expected := range map[string]string{"": "environment", "env": "env"} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook, err := NewHookWithFieldsAndConn(conn, "env_test", nil, WithEnvironmentField(fieldName, "production"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res[expected] != "production" {
+			t.Errorf("expected %s to be 'production' but got '%s'", expected, res[expected])
+		}
+	}
+
+	// The fields passed to the constructor are left intact.
+	fields := logrus.Fields{"region": "eu"}
+	if _, err := NewHookWithFieldsAndConn(ConnMock{buff: bytes.NewBufferString("")}, "env_test", fields, WithEnvironmentField("", "production")); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 {
+		t.Errorf("expected the fields of the caller to be left intact but got %v", fields)
+	}
+}
+
+func TestReservedFields(t *testing.T) {
+	for _, test := range []struct {
+		appName  string
+		prefix   string
+		fields   logrus.Fields
+		opts     []Option
+		reserved []string
+	}{
+		{"app", "", logrus.Fields{"@version": "2", "@timestamp": "now", "region": "eu"}, nil, []string{"@timestamp", "@version"}},
+		{"app", "", logrus.Fields{"type": "t", "level": "l", "message": "m"}, nil, []string{"level", "message", "type"}},
+		{"", "", logrus.Fields{"type": "t"}, nil, nil},
+		{"app", "_ls_", logrus.Fields{"_ls_type": "t"}, nil, []string{"_ls_type"}},
+		{"app", "", logrus.Fields{"message": "m", "msg": "m"}, []Option{WithMessageFieldName("msg")}, []string{"msg"}},
+	} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		_, err := NewHookWithFieldsAndConnAndPrefix(conn, test.appName, test.fields, test.prefix, test.opts...)
+		var reservedErr *ReservedFieldError
+		if test.reserved == nil {
+			if err != nil {
+				t.Errorf("expected no error for %v but got %v", test.fields, err)
+			}
+			continue
+		}
+		if !errors.As(err, &reservedErr) || fmt.Sprint(reservedErr.Keys) != fmt.Sprint(test.reserved) {
+			t.Errorf("expected the reserved fields %v but got %v", test.reserved, err)
+		}
+	}
+
+	if _, err := NewHookWithFields("udp", "127.0.0.1:9", "app", logrus.Fields{"@version": "2"}); err == nil {
+		t.Error("expected an error for a reserved field")
+	}
+}
+
+func TestSettingFieldsWhileFiring(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewAsyncHookWithConn(conn, "race_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.Out = bytes.NewBufferString("")
+	logger.Hooks.Add(hook)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			hook.WithField("i", i)
+			hook.WithFields(logrus.Fields{"j": i})
+			hook.DeleteField("i")
+			hook.WithPrefix(fmt.Sprintf("_%d", i%2))
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 250; i++ {
+				logger.WithFields(logrus.Fields{"_0host": "localhost", "_1host": "localhost"}).Info("race")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestFilterHookOnly(t *testing.T) {
 	tt := []struct {
 		entry    *logrus.Entry
@@ -272,37 +667,52 @@ func (c ConnMock) SetWriteDeadline(t time.Time) error {
 }
 
 func TestFire(t *testing.T) {
-	conn := ConnMock{buff: bytes.NewBufferString("")}
-	hook := &Hook{
-		conn:             conn,
-		appName:          "fire_test",
-		alwaysSentFields: logrus.Fields{"test-name": "fire-test", "->ignore": "haaa", "override": "no"},
-		hookOnlyPrefix:   "->",
-	}
-	entry := &logrus.Entry{
-		Message: "hello world!",
-		Data:    logrus.Fields{"override": "yes"},
-		Level:   logrus.DebugLevel,
-	}
-	if err := hook.Fire(entry); err != nil {
-		t.Error(err)
-	}
-	var res map[string]string
-	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
-		t.Error(err)
-	}
-	expected := map[string]string{
-		"@timestamp": "0001-01-01T00:00:00Z",
-		"@version":   "1",
-		"ignore":     "haaa",
-		"level":      "debug",
-		"message":    "hello world!",
-		"override":   "yes",
-		"test-name":  "fire-test",
-		"type":       "fire_test",
+	tt := []struct {
+		name     string
+		appName  string
+		typeKey  string
+		expected map[string]string
+	}{
+		{"default type key", "fire_test", "", map[string]string{"type": "fire_test"}},
+		{"renamed type key", "fire_test", "service", map[string]string{"service": "fire_test"}},
+		{"no app name", "", "", map[string]string{}},
 	}
-	if !reflect.DeepEqual(expected, res) {
-		t.Errorf("expected message to be '%v' but got '%v'", expected, res)
+	for _, te := range tt {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook := &Hook{
+			conn:             conn,
+			appName:          te.appName,
+			alwaysSentFields: logrus.Fields{"test-name": "fire-test", "->ignore": "haaa", "override": "no"},
+			hookOnlyPrefix:   "->",
+			formatter:        LogstashFormatter{TypeKey: te.typeKey},
+		}
+		entry := &logrus.Entry{
+			Message: "hello world!",
+			Data:    logrus.Fields{"override": "yes"},
+			Level:   logrus.DebugLevel,
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Error(err)
+		}
+		var res map[string]string
+		if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+			t.Error(err)
+		}
+		expected := map[string]string{
+			"@timestamp": "0001-01-01T00:00:00Z",
+			"@version":   "1",
+			"ignore":     "haaa",
+			"level":      "debug",
+			"message":    "hello world!",
+			"override":   "yes",
+			"test-name":  "fire-test",
+		}
+		for k, v := range te.expected {
+			expected[k] = v
+		}
+		if !reflect.DeepEqual(expected, res) {
+			t.Errorf("%s: expected message to be '%v' but got '%v'", te.name, expected, res)
+		}
 	}
 }
 
@@ -320,9 +730,11 @@ func TestFireFilterHook(t *testing.T) {
 	if err := hook.Fire(entry); err != nil {
 		t.Error(err)
 	}
+	// The fields of the hook are not added to the entry of the caller anymore (see CHANGELOG.md),
+	// only the fields with the hook only prefix are removed from it.
 	expected := &logrus.Entry{
 		Message: "hello world!",
-		Data:    logrus.Fields{"test-name": "fire-test-hook", "override": "yes"},
+		Data:    logrus.Fields{"override": "yes"},
 		Level:   logrus.DebugLevel,
 	}
 
@@ -368,3 +780,588 @@ func TestLogstashTimeStampFormat(t *testing.T) {
 		t.Errorf("expected time to be '%s' but got '%s'", "3:04AM", value)
 	}
 }
+
+func TestLogstashTimestampLocation(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "location_test", WithUTCTimestamps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.TimeFormat = time.RFC3339
+
+	fTime := time.Date(2009, time.November, 10, 15, 4, 0, 0, time.FixedZone("", 2*60*60))
+	if err := hook.Fire(&logrus.Entry{Time: fTime, Data: logrus.Fields{}}); err != nil {
+		t.Errorf("expected fire to not return error: %s", err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Error(err)
+	}
+	if res["@timestamp"] != "2009-11-10T13:04:00Z" {
+		t.Errorf("expected time to be '%s' but got '%s'", "2009-11-10T13:04:00Z", res["@timestamp"])
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	hook, err := NewHook("udp", "localhost:9999", "dial_timeout_test", WithDialTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.DialTimeout != time.Second {
+		t.Errorf("expected DialTimeout to be '%s' but got '%s'", time.Second, hook.DialTimeout)
+	}
+
+	// The protocols wrapping the connections of the factory are covered as well.
+	for _, protocol := range []string{"tcp", "lumberjack", "tls"} {
+		release := make(chan struct{})
+		conn := &closeTrackingConn{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, closed: make(chan struct{})}
+		factory := func(protocol, address string) (net.Conn, error) {
+			<-release
+			return conn, nil
+		}
+
+		start := time.Now()
+		_, err = NewHook(protocol, "logstash:9999", "dial_timeout_test", WithConnFactory(factory), WithDialTimeout(100*time.Millisecond))
+		if err == nil {
+			t.Fatalf("%s: expected the dial to time out", protocol)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected dial to give up after DialTimeout but it took %s", protocol, elapsed)
+		}
+
+		// The connection established after the timeout is closed.
+		close(release)
+		select {
+		case <-conn.closed:
+		case <-time.After(time.Second):
+			t.Errorf("%s: expected the connection established after the timeout to be closed", protocol)
+		}
+	}
+}
+
+type closeTrackingConn struct {
+	ConnMock
+	closed chan struct{}
+}
+
+func (c *closeTrackingConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestConnFactory(t *testing.T) {
+	var dials []string
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	factory := func(protocol, address string) (net.Conn, error) {
+		dials = append(dials, protocol+"://"+address)
+		return conn, nil
+	}
+
+	hook, err := NewHook("tcp", "logstash:9999", "factory_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "hello factory", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Error(err)
+	}
+	if res["message"] != "hello factory" {
+		t.Errorf("expected message to be '%s' but got '%s'", "hello factory", res["message"])
+	}
+
+	if err := (connTransport{hook}).reconnect(0); err != nil {
+		t.Error(err)
+	}
+	expected := []string{"tcp://logstash:9999", "tcp://logstash:9999"}
+	if !reflect.DeepEqual(expected, dials) {
+		t.Errorf("expected dials to be '%v' but got '%v'", expected, dials)
+	}
+
+	factoryErr := fmt.Errorf("factory error")
+	_, err = NewHook("tcp", "logstash:9999", "factory_test", WithConnFactory(func(string, string) (net.Conn, error) {
+		return nil, factoryErr
+	}))
+	if err != factoryErr {
+		t.Errorf("expected error to be '%v' but got '%v'", factoryErr, err)
+	}
+}
+
+func TestReconnectNotify(t *testing.T) {
+	dialErr := fmt.Errorf("connection refused")
+	failures := 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		if failures > 0 {
+			failures--
+			return nil, dialErr
+		}
+		return ConnMock{buff: bytes.NewBufferString("")}, nil
+	}
+
+	notifications := make(chan error, 10)
+	hook, err := NewHook("tcp", "logstash:9999", "notify_test", WithConnFactory(factory), WithReconnectNotify(notifications))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxReconnectRetries = 3
+
+	failures = 2
+	if err := (connTransport{hook}).reconnect(0); err != nil {
+		t.Error(err)
+	}
+	expected := []error{dialErr, dialErr, nil}
+	for _, e := range expected {
+		select {
+		case res := <-notifications:
+			if res != e {
+				t.Errorf("expected notification to be '%v' but got '%v'", e, res)
+			}
+		default:
+			t.Fatalf("expected notification '%v' but got nothing", e)
+		}
+	}
+
+	// A full channel must not block reconnects.
+	blocked := make(chan error)
+	hook.reconnectNotify = blocked
+	if err := (connTransport{hook}).reconnect(0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewlineDelimiter(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook, err := NewHookWithConn(conn, "newline_test", WithNewlineDelimiter(enabled))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+			t.Error(err)
+		}
+
+		data := conn.buff.Bytes()
+		if hasNewline := bytes.HasSuffix(data, []byte("\n")); hasNewline != enabled {
+			t.Errorf("expected trailing newline to be %v but got %q", enabled, data)
+		}
+		var res map[string]string
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestFireWhileReconnecting(t *testing.T) {
+	buff := &syncBuffer{}
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	var dialingOnce sync.Once
+	factory := func(protocol, address string) (net.Conn, error) {
+		if address == "logstash:9999" {
+			return ConnMock{buff: bytes.NewBufferString("")}, nil
+		}
+		dialingOnce.Do(func() { close(dialing) })
+		<-release
+		return &writerConn{w: buff}, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "race_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.Timeout = time.Second
+	// The hook has no connection until the new address is dialed.
+	hook.SetAddress("tcp", "logstash:9998")
+
+	reconnected := make(chan error, 1)
+	go func() {
+		reconnected <- (connTransport{hook}).reconnect(0)
+	}()
+	<-dialing
+
+	fired := make(chan error, 1)
+	go func() {
+		fired <- hook.Fire(&logrus.Entry{Message: "race", Data: logrus.Fields{}})
+	}()
+	select {
+	case err := <-fired:
+		t.Fatalf("expected Fire to wait for the connection but it returned '%v'", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-reconnected; err != nil {
+		t.Error(err)
+	}
+	if err := <-fired; err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(buff.String(), `"message":"race"`) {
+		t.Errorf("expected the entry to be sent after the reconnect but got %q", buff.String())
+	}
+}
+
+func TestSequenceField(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "sequence_test", WithSequenceField("seq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for i := uint64(1); i <= 3; i++ {
+		var res map[string]interface{}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["seq"] != float64(i) {
+			t.Errorf("expected seq to be '%d' but got '%v'", i, res["seq"])
+		}
+	}
+}
+
+type slowHook struct{}
+
+func (slowHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (slowHook) Fire(entry *logrus.Entry) error {
+	for i := 0; i < 100; i++ {
+		for range entry.Data {
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+	return nil
+}
+
+func TestFireDoesNotModifyEntry(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewAsyncHookWithFieldsAndConnAndPrefix(conn, "entry_test", logrus.Fields{"service": "api"}, "_",
+		WithSequenceField("seq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = bytes.NewBufferString("")
+	logger.Hooks.Add(hook)
+	logger.Hooks.Add(slowHook{})
+
+	for i := 0; i < 10; i++ {
+		logger.WithField("i", i).Info("shared entry")
+	}
+
+	entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{"i": 1, "_host": "localhost"}}
+	hook.Fire(entry)
+	expected := logrus.Fields{"i": 1}
+	if !reflect.DeepEqual(entry.Data, expected) {
+		t.Errorf("expected entry data to be '%v' but got '%v'", expected, entry.Data)
+	}
+}
+
+// chunkConnMock writes at most 10 bytes at a time.
+type chunkConnMock struct {
+	ConnMock
+}
+
+func (c chunkConnMock) Write(b []byte) (int, error) {
+	if len(b) > 10 {
+		b = b[:10]
+	}
+	return c.buff.Write(b)
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "i/o timeout" }
+func (temporaryError) Timeout() bool   { return true }
+func (temporaryError) Temporary() bool { return true }
+
+// interruptedConnMock writes a half of the first message and fails.
+type interruptedConnMock struct {
+	ConnMock
+	interrupted bool
+}
+
+func (c *interruptedConnMock) Write(b []byte) (int, error) {
+	if !c.interrupted {
+		c.interrupted = true
+		n, _ := c.buff.Write(b[:len(b)/2])
+		return n, temporaryError{}
+	}
+	return c.buff.Write(b)
+}
+
+func TestShortWrites(t *testing.T) {
+	tt := map[string]net.Conn{
+		"chunks":      chunkConnMock{ConnMock{buff: bytes.NewBufferString("")}},
+		"interrupted": &interruptedConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}},
+	}
+	for name, conn := range tt {
+		hook, err := NewHookWithConn(conn, "short_write_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook.MaxSendRetries = 1
+
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{"name": name}}); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+
+		var buff *bytes.Buffer
+		switch conn := conn.(type) {
+		case chunkConnMock:
+			buff = conn.buff
+		case *interruptedConnMock:
+			buff = conn.buff
+		}
+		lines := strings.Split(strings.TrimSuffix(buff.String(), "\n"), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("%s: expected exactly one line but got %q", name, buff.String())
+		}
+		var res map[string]string
+		if err := json.Unmarshal([]byte(lines[0]), &res); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+		if res["name"] != name {
+			t.Errorf("%s: expected name to be '%s' but got '%s'", name, name, res["name"])
+		}
+	}
+}
+
+func TestFireWithoutConnection(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	fail := true
+	factory := func(protocol, address string) (net.Conn, error) {
+		if fail {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return conn, nil
+	}
+	hook := newHook(nil, "nil_conn_test", make(logrus.Fields), "", []Option{WithConnFactory(factory)})
+	hook.protocol = "tcp"
+	hook.address = "logstash:9999"
+
+	if err := hook.Fire(&logrus.Entry{Message: "lost", Data: logrus.Fields{}}); err == nil {
+		t.Error("expected fire to fail while the connection can't be established")
+	}
+	if err := hook.performSend(nil, []byte("lost\n"), time.Now(), 0, 0); err != ErrNotConnected {
+		t.Errorf("expected error to be '%v' but got '%v'", ErrNotConnected, err)
+	}
+
+	fail = false
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "hello" {
+		t.Errorf("expected message to be '%s' but got '%s'", "hello", res["message"])
+	}
+	if state := hook.ConnectionState(); state != StateConnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateConnected, state)
+	}
+}
+
+// panicConnMock panics on the first write and passes the next ones to a channel.
+type panicConnMock struct {
+	ConnMock
+	panicked bool
+	written  chan []byte
+}
+
+func (c *panicConnMock) Write(b []byte) (int, error) {
+	if !c.panicked {
+		c.panicked = true
+		panic("broken connection")
+	}
+	c.written <- append([]byte(nil), b...)
+	return len(b), nil
+}
+
+func TestAsyncSenderSurvivesPanic(t *testing.T) {
+	conn := &panicConnMock{written: make(chan []byte, 1)}
+	hook, err := NewAsyncHookWithConn(conn, "panic_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.WaitUntilBufferFrees = true
+
+	hook.Fire(&logrus.Entry{Message: "panic", Data: logrus.Fields{}})
+	hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+
+	select {
+	case data := <-conn.written:
+		var res map[string]string
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != "hello" {
+			t.Errorf("expected message to be '%s' but got '%s'", "hello", res["message"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the sender to continue after a panic")
+	}
+}
+
+func TestAsyncOrder(t *testing.T) {
+	w := &syncBuffer{}
+	hook, err := NewAsyncHookWithWriter(w, "order_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.WaitUntilBufferFrees = true
+
+	const goroutines, entries = 4, 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < entries; i++ {
+				hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{"goroutine": g, "i": i}})
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	hook.Close()
+
+	// The entries of each goroutine are sent in the order they have been fired.
+	next := make([]int, goroutines)
+	dec := json.NewDecoder(strings.NewReader(w.String()))
+	for dec.More() {
+		var res struct {
+			Goroutine int
+			I         int
+		}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.I != next[res.Goroutine] {
+			t.Fatalf("expected entry %d of goroutine %d but got %d", next[res.Goroutine], res.Goroutine, res.I)
+		}
+		next[res.Goroutine]++
+	}
+	for g, n := range next {
+		if n != entries {
+			t.Errorf("expected %d entries of goroutine %d but got %d", entries, g, n)
+		}
+	}
+}
+
+func TestTCPIntegration(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+
+	hook, err := NewHookWithFields("tcp", server.Addr(), "integration_test", logrus.Fields{"env": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	log := logrus.New()
+	log.Out = io.Discard
+	log.Hooks.Add(hook)
+
+	log.WithField("user", "alice").Info("hello")
+
+	events, err := server.WaitForEvents(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := events[0]
+	expected := map[string]interface{}{
+		"message":  "hello",
+		"level":    "info",
+		"user":     "alice",
+		"env":      "test",
+		"type":     "integration_test",
+		"@version": "1",
+	}
+	for k, v := range expected {
+		if message[k] != v {
+			t.Errorf("expected %s to be '%v' but got '%v'", k, v, message[k])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, fmt.Sprint(message["@timestamp"])); err != nil {
+		t.Errorf("expected a valid @timestamp: %s", err)
+	}
+}
+
+func TestTCPReconnect(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+
+	hook, err := NewHook("tcp", server.Addr(), "reconnect_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.ReconnectBaseDelay = 10 * time.Millisecond
+	hook.MaxReconnectRetries = 3
+
+	if err := hook.Fire(&logrus.Entry{Message: "before", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := server.WaitForEvents(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	} else if events[0]["message"] != "before" {
+		t.Errorf("expected message to be 'before' but got '%v'", events[0]["message"])
+	}
+
+	// Logstash restarts: the established connection is reset and the port is unavailable for a while.
+	server.Stop()
+	hook.Fire(&logrus.Entry{Message: "lost", Data: logrus.Fields{}})
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages written before the reset is noticed are lost, so keep logging until one arrives.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hook.Fire(&logrus.Entry{Message: "after", Data: logrus.Fields{}})
+		if events, err := server.WaitForEvents(2, 50*time.Millisecond); err == nil {
+			if events[1]["message"] != "after" {
+				t.Errorf("expected message to be 'after' but got '%v'", events[1]["message"])
+			}
+			return
+		}
+	}
+	t.Fatal("expected the hook to reconnect")
+}
+
+func TestKeyTransformConflictWarning(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "conflict_test", WithKeyTransform(SnakeCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() {
+		for i := 0; i < 3; i++ {
+			if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{"userID": 1, "user_id": 2}}); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if hook.KeyConflictCount() != 3 {
+		t.Errorf("expected 3 conflicts but got %d", hook.KeyConflictCount())
+	}
+	// Each conflict is reported once, with both keys.
+	if n := strings.Count(output, "Warning: "); n != 1 || !strings.Contains(output, `"userID"`) || !strings.Contains(output, `"user_id"`) {
+		t.Errorf("expected a single warning with both keys but got %q", output)
+	}
+}
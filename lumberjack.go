@@ -0,0 +1,148 @@
+package logrustash
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Frame types of the Lumberjack v2 protocol.
+const (
+	lumberjackVersion    = '2'
+	lumberjackWindow     = 'W'
+	lumberjackJSON       = 'J'
+	lumberjackCompressed = 'C'
+	lumberjackAck        = 'A'
+)
+
+// WithLumberjackCompression makes the "lumberjack" protocol compress the messages with zlib
+// at level (see compress/zlib). Zero level (default) disables compression.
+func WithLumberjackCompression(level int) Option {
+	return func(h *Hook) {
+		h.lumberjackCompression = level
+	}
+}
+
+// lumberjackAckError is returned when a message has not been acknowledged.
+// It's neither temporary nor a timeout, so the hook reconnects and resends the message.
+type lumberjackAckError struct {
+	err error
+}
+
+func (e lumberjackAckError) Error() string {
+	return fmt.Sprintf("Message has not been acknowledged: %s", e.err)
+}
+
+func (e lumberjackAckError) Unwrap() error   { return e.err }
+func (e lumberjackAckError) Timeout() bool   { return false }
+func (e lumberjackAckError) Temporary() bool { return false }
+
+// lumberjackConn is a net.Conn which sends each written message as a window of one
+// JSON event of the Lumberjack v2 protocol (used by the beats input of Logstash)
+// and waits for its acknowledgement, so a successful Write means the event has been
+// received by Logstash. The written data must be a single JSON document.
+type lumberjackConn struct {
+	net.Conn
+	reader      *bufio.Reader
+	compression int
+	readTimeout time.Duration
+}
+
+func (h *Hook) dialLumberjack(address string) (net.Conn, error) {
+	// The auto-reconnecting connections of goautosocket can't be used,
+	// because an acknowledgement must be read from the connection the event was sent to.
+	var conn net.Conn
+	var err error
+	switch {
+	case h.connFactory != nil:
+		conn, err = h.dialFactory("tcp", address)
+	case h.useProxy("tcp"):
+		conn, err = h.dialProxy("tcp", address)
+	default:
+		conn, err = h.dialer("tcp").Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &lumberjackConn{
+		Conn:        conn,
+		reader:      bufio.NewReader(conn),
+		compression: h.lumberjackCompression,
+		readTimeout: h.readTimeout(),
+	}, nil
+}
+
+// Write sends b as a single event and waits until it's acknowledged.
+func (c *lumberjackConn) Write(b []byte) (int, error) {
+	frames, err := c.frames(bytes.TrimSuffix(b, []byte("\n")))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.Conn.Write(frames); err != nil {
+		return 0, err
+	}
+	if err := c.waitAck(1); err != nil {
+		return 0, lumberjackAckError{err}
+	}
+
+	return len(b), nil
+}
+
+// frames returns the window and data frames of a single event.
+func (c *lumberjackConn) frames(event []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{lumberjackVersion, lumberjackWindow})
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+
+	var data bytes.Buffer
+	data.Write([]byte{lumberjackVersion, lumberjackJSON})
+	binary.Write(&data, binary.BigEndian, uint32(1)) // sequence number
+	binary.Write(&data, binary.BigEndian, uint32(len(event)))
+	data.Write(event)
+
+	if c.compression == 0 {
+		buf.Write(data.Bytes())
+		return buf.Bytes(), nil
+	}
+
+	var compressed bytes.Buffer
+	w, err := zlib.NewWriterLevel(&compressed, c.compression)
+	if err != nil {
+		return nil, err
+	}
+	w.Write(data.Bytes())
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write([]byte{lumberjackVersion, lumberjackCompressed})
+	binary.Write(&buf, binary.BigEndian, uint32(compressed.Len()))
+	buf.Write(compressed.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+// waitAck reads acknowledgements until seq is acknowledged.
+// Logstash may acknowledge smaller sequence numbers as a keep-alive.
+func (c *lumberjackConn) waitAck(seq uint32) error {
+	if c.readTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	frame := make([]byte, 6)
+	for {
+		if _, err := io.ReadFull(c.reader, frame); err != nil {
+			return err
+		}
+		if frame[0] != lumberjackVersion || frame[1] != lumberjackAck {
+			return fmt.Errorf("Unexpected frame %q", frame[:2])
+		}
+		if binary.BigEndian.Uint32(frame[2:]) >= seq {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package logrustash
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeBeatsServer reads Lumberjack v2 events and acknowledges them unless ack returns false,
+// in which case it closes the connection without acknowledgement.
+type fakeBeatsServer struct {
+	listener net.Listener
+	events   chan map[string]string
+	ack      func(event map[string]string) bool
+}
+
+func newFakeBeatsServer(t *testing.T, ack func(event map[string]string) bool) *fakeBeatsServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeBeatsServer{listener: listener, events: make(chan map[string]string, 10), ack: ack}
+	go s.serve(t)
+	return s
+}
+
+func (s *fakeBeatsServer) serve(t *testing.T) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(t, conn)
+	}
+}
+
+func (s *fakeBeatsServer) handle(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		header := make([]byte, 6)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		if header[0] != '2' || header[1] != 'W' {
+			t.Errorf("expected a window frame but got %q", header[:2])
+			return
+		}
+		frames := r
+		if next, _ := r.Peek(2); string(next) == "2C" {
+			r.Discard(2)
+			var length uint32
+			binary.Read(r, binary.BigEndian, &length)
+			zr, err := zlib.NewReader(io.LimitReader(r, int64(length)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := ioutil.ReadAll(zr)
+			frames = bufio.NewReader(bytes.NewReader(data))
+		}
+
+		var seq, length uint32
+		frameType := make([]byte, 2)
+		io.ReadFull(frames, frameType)
+		if string(frameType) != "2J" {
+			t.Errorf("expected a JSON frame but got %q", frameType)
+			return
+		}
+		binary.Read(frames, binary.BigEndian, &seq)
+		binary.Read(frames, binary.BigEndian, &length)
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(frames, payload); err != nil {
+			t.Error(err)
+			return
+		}
+		var event map[string]string
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Error(err)
+			return
+		}
+		s.events <- event
+		if !s.ack(event) {
+			return
+		}
+
+		ack := []byte{'2', 'A', 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(ack[2:], seq)
+		conn.Write(ack)
+	}
+}
+
+func TestLumberjack(t *testing.T) {
+	for _, compression := range []int{0, zlib.BestSpeed} {
+		server := newFakeBeatsServer(t, func(map[string]string) bool { return true })
+		defer server.listener.Close()
+
+		hook, err := NewHook("lumberjack", server.listener.Addr().String(), "beats_test", WithLumberjackCompression(compression))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook.Timeout = 5 * time.Second
+		for _, message := range []string{"first", "second"} {
+			if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+				t.Error(err)
+			}
+			if event := <-server.events; event["message"] != message {
+				t.Errorf("expected message to be '%s' but got '%s'", message, event["message"])
+			}
+		}
+		hook.Close()
+	}
+}
+
+func TestLumberjackRetransmit(t *testing.T) {
+	acked := false
+	server := newFakeBeatsServer(t, func(event map[string]string) bool {
+		// Lose the first event.
+		defer func() { acked = true }()
+		return acked
+	})
+	defer server.listener.Close()
+
+	hook, err := NewHook("lumberjack", server.listener.Addr().String(), "beats_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.Timeout = 5 * time.Second
+	hook.MaxReconnectRetries = 1
+
+	if err := hook.Fire(&logrus.Entry{Message: "important", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 2; i++ {
+		if event := <-server.events; event["message"] != "important" {
+			t.Errorf("expected message to be '%s' but got '%s'", "important", event["message"])
+		}
+	}
+}
+
+func TestLumberjackReadTimeout(t *testing.T) {
+	// The server neither acknowledges the event nor closes the connection.
+	release := make(chan struct{})
+	defer close(release)
+	server := newFakeBeatsServer(t, func(map[string]string) bool {
+		<-release
+		return false
+	})
+	defer server.listener.Close()
+
+	hook, err := NewHook("lumberjack", server.listener.Addr().String(), "beats_test", WithConnectionReadTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	started := time.Now()
+	err = hook.Fire(&logrus.Entry{Message: "unacknowledged", Data: logrus.Fields{}})
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) {
+		t.Errorf("expected a NetworkError but got '%v'", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("expected the read to time out after 100ms but it took %v", elapsed)
+	}
+}
+
+func TestLumberjackNoAck(t *testing.T) {
+	server := newFakeBeatsServer(t, func(map[string]string) bool { return false })
+	defer server.listener.Close()
+
+	hook, err := NewHook("lumberjack", server.listener.Addr().String(), "beats_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	err = hook.Fire(&logrus.Entry{Message: "lost", Data: logrus.Fields{}})
+	if _, ok := err.(*NetworkError); !ok {
+		t.Errorf("expected a NetworkError but got '%v'", err)
+	}
+}
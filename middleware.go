@@ -0,0 +1,135 @@
+package logrustash
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Middleware processes each entry before it is formatted and sent (see Use).
+// Process gets a copy of the entry, which it may modify, and passes it (or another entry)
+// to next, which runs the rest of the middlewares and sends the entry. If Process returns
+// without calling next (or calls it with nil), the entry is dropped and counted in DroppedCount.
+// The error of Process is returned by Fire in sync mode and printed in async mode.
+type Middleware interface {
+	Process(entry *logrus.Entry, next func(*logrus.Entry) error) error
+}
+
+// MiddlewareFunc is an adapter to use an ordinary function as a Middleware.
+type MiddlewareFunc func(entry *logrus.Entry, next func(*logrus.Entry) error) error
+
+// Process calls f(entry, next).
+func (f MiddlewareFunc) Process(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+	return f(entry, next)
+}
+
+// entryMiddleware is a Middleware added by WithEntryMiddleware.
+type entryMiddleware func(*logrus.Entry) *logrus.Entry
+
+func (fn entryMiddleware) Process(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+	return next(fn(entry))
+}
+
+// Use adds m to the middlewares of the hook. The middlewares (including the ones added by
+// WithEntryMiddleware) are executed in the order they are added, from the goroutine which
+// sends the entry. The messages sent by SendRaw aren't passed to them. Use may be called
+// while the hook is in use; a child hook adds m to its parent.
+func (h *Hook) Use(m Middleware) {
+	if h.parent != nil {
+		h.parent.Use(m)
+		return
+	}
+	h.middlewaresLocker.Lock()
+	defer h.middlewaresLocker.Unlock()
+	// Copy on write, so processEntry may use the slice without the lock.
+	h.middlewares = append(h.middlewares[:len(h.middlewares):len(h.middlewares)], m)
+}
+
+// processEntry passes entry through the middlewares to send.
+// It counts the entry as dropped if a middleware has dropped it.
+func (h *Hook) processEntry(entry *logrus.Entry, send func(*logrus.Entry) error) error {
+	if _, ok := rawMessage(entry); ok {
+		return send(entry)
+	}
+	h.middlewaresLocker.RLock()
+	middlewares := h.middlewares
+	h.middlewaresLocker.RUnlock()
+
+	sent := false
+	var next func(i int, entry *logrus.Entry) error
+	next = func(i int, entry *logrus.Entry) error {
+		if entry == nil {
+			return nil
+		}
+		if i == len(middlewares) {
+			sent = true
+			return send(entry)
+		}
+		return middlewares[i].Process(entry, func(entry *logrus.Entry) error {
+			return next(i+1, entry)
+		})
+	}
+	err := next(0, entry)
+	if !sent && err == nil {
+		h.drop(entry, DropReasonMiddleware)
+	}
+
+	return err
+}
+
+// SamplingMiddleware passes only a fraction of the entries of each level in rates to the next
+// middleware, like WithSamplingRate: 1 passes all entries, 0.1 roughly 10% of them and 0 none.
+// The entries of the levels missing in rates are passed.
+func SamplingMiddleware(rates map[logrus.Level]float64) Middleware {
+	s := &sampler{
+		rates: make(map[logrus.Level]float64, len(rates)),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for level, r := range rates {
+		s.rates[level] = r
+	}
+	return MiddlewareFunc(func(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+		if !s.sample(entry.Level) {
+			return nil
+		}
+		return next(entry)
+	})
+}
+
+// DeduplicationMiddleware drops an entry if an entry with the same level and message
+// has been passed less than window ago, like WithDeduplication.
+func DeduplicationMiddleware(window time.Duration) Middleware {
+	d := newDeduplicator()
+	d.window = window
+	return MiddlewareFunc(func(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+		if send, _ := d.check(entry.Level, entry.Message); !send {
+			return nil
+		}
+		return next(entry)
+	})
+}
+
+// FieldRedactionMiddleware replaces the values of the entry fields matched by r with RedactedValue,
+// like WithRedactor, but before the next middlewares.
+func FieldRedactionMiddleware(r *Redactor) Middleware {
+	return MiddlewareFunc(func(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+		for k, v := range entry.Data {
+			entry.Data[k] = r.redact(k, v)
+		}
+		return next(entry)
+	})
+}
+
+// RateLimitMiddleware passes at most perSecond entries per second (with bursts of up to burst
+// entries) to the next middleware and drops the rest.
+func RateLimitMiddleware(perSecond float64, burst int) Middleware {
+	limiter := rate.NewLimiter(rate.Limit(perSecond), burst)
+	return MiddlewareFunc(func(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+		if !limiter.Allow() {
+			return nil
+		}
+		return next(entry)
+	})
+}
@@ -0,0 +1,118 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestUse(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	var order []string
+	hook, err := NewHookWithConn(conn, "middleware_test", WithEntryMiddleware(func(entry *logrus.Entry) *logrus.Entry {
+		order = append(order, "option")
+		return entry
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := errors.New("rejected")
+	hook.Use(MiddlewareFunc(func(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+		order = append(order, "first")
+		switch entry.Message {
+		case "drop":
+			return nil
+		case "reject":
+			return rejected
+		}
+		entry.Message = strings.ToUpper(entry.Message)
+		return next(entry)
+	}))
+	hook.Use(MiddlewareFunc(func(entry *logrus.Entry, next func(*logrus.Entry) error) error {
+		order = append(order, "second")
+		return next(entry)
+	}))
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "option,first,second" {
+		t.Errorf("expected the middlewares in the order they have been added but got %v", order)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "HELLO" {
+		t.Errorf("expected message 'HELLO' but got '%s'", res["message"])
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "drop", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "reject", Data: logrus.Fields{}}); err != rejected {
+		t.Errorf("expected the error of the middleware but got %v", err)
+	}
+	if n := hook.droppedByReason[DropReasonMiddleware]; n != 1 {
+		t.Errorf("expected 1 entry dropped by the middleware but got %d", n)
+	}
+	if conn.buff.Len() != 0 {
+		t.Errorf("expected nothing else to be sent but got %q", conn.buff.String())
+	}
+}
+
+func TestBuiltinMiddlewares(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "middleware_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactor, err := NewRedactor("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Use(SamplingMiddleware(map[logrus.Level]float64{logrus.DebugLevel: 0}))
+	hook.Use(DeduplicationMiddleware(time.Hour))
+	hook.Use(FieldRedactionMiddleware(redactor))
+	hook.Use(RateLimitMiddleware(0.001, 2))
+
+	for _, entry := range []*logrus.Entry{
+		{Message: "sampled", Level: logrus.DebugLevel},
+		{Message: "first", Level: logrus.InfoLevel, Data: logrus.Fields{"password": "hunter2"}},
+		{Message: "first", Level: logrus.InfoLevel},
+		{Message: "second", Level: logrus.InfoLevel},
+		{Message: "rate limited", Level: logrus.InfoLevel},
+	} {
+		if entry.Data == nil {
+			entry.Data = logrus.Fields{}
+		}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for _, expected := range []string{"first", "second"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected {
+			t.Errorf("expected message '%s' but got '%s'", expected, res["message"])
+		}
+		if password, ok := res["password"]; ok && password != RedactedValue {
+			t.Errorf("expected the password to be redacted but got '%s'", password)
+		}
+	}
+	if dec.More() {
+		t.Error("expected no more messages")
+	}
+	if n := hook.droppedByReason[DropReasonMiddleware]; n != 3 {
+		t.Errorf("expected 3 entries dropped by the middlewares but got %d", n)
+	}
+}
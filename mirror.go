@@ -0,0 +1,52 @@
+package logrustash
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithMirror makes the hook pass a copy of every entry to mirror, e.g. a hook to a new
+// Logstash cluster during a migration. Unlike WithShadowEndpoint, the mirror is a complete hook
+// with its own connection, reconnect state, buffer, fields and drop counters, so a failure
+// of one destination never blocks or drops the entries of the other one, as long as the mirror
+// is an async hook. The entries are passed before sampling and deduplication, which are
+// configured on each hook separately. Close of the hook closes the mirror as well.
+func WithMirror(mirror *Hook) Option {
+	return func(h *Hook) {
+		h.mirror = mirror
+	}
+}
+
+// WithMirrorFlush makes Flush and FlushContext also wait for the mirror set by WithMirror.
+// By default only the hook itself is flushed.
+func WithMirrorFlush(wait bool) Option {
+	return func(h *Hook) {
+		h.mirrorFlush = wait
+	}
+}
+
+// Mirror returns the hook set by WithMirror or nil.
+func (h *Hook) Mirror() *Hook {
+	return h.mirror
+}
+
+// fireMirror passes a copy of entry to the mirror, if any.
+func (h *Hook) fireMirror(entry *logrus.Entry) {
+	if h.mirror == nil {
+		return
+	}
+
+	if err := h.mirror.Fire(copyEntry(entry)); err != nil {
+		fmt.Println("Error during sending message to logstash mirror:", err)
+	}
+}
+
+// flushMirror waits for the mirror if WithMirrorFlush is used.
+func (h *Hook) flushMirror(ctx context.Context) error {
+	if h.mirror == nil || !h.mirrorFlush {
+		return nil
+	}
+	return h.mirror.FlushContext(ctx)
+}
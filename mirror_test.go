@@ -0,0 +1,119 @@
+package logrustash
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestMirror(t *testing.T) {
+	const n = 50
+
+	// stalledHook is a destination which doesn't accept anything.
+	stalledHook := func() (*Hook, chan struct{}) {
+		conn := blockingConnMock{release: make(chan struct{})}
+		hook := newHook(conn, "mirror_test", make(logrus.Fields), "", nil)
+		hook.AsyncBufferSize = 1
+		hook.makeAsync()
+		return hook, conn.release
+	}
+	healthyHook := func(t *testing.T, server *logrustashtest.Server, opts ...Option) *Hook {
+		hook, err := NewAsyncHook("tcp", server.Addr(), "mirror_test", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hook
+	}
+
+	t.Run("stalled mirror", func(t *testing.T) {
+		server := logrustashtest.NewServer(t, "tcp")
+		mirror, release := stalledHook()
+		hook := healthyHook(t, server, WithMirror(mirror))
+		defer hook.Close()
+		// Close waits for the write in progress.
+		defer close(release)
+
+		for i := 0; i < n; i++ {
+			if err := hook.Fire(&logrus.Entry{Message: fmt.Sprint(i), Data: logrus.Fields{}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		events, err := server.WaitForEvents(n, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, event := range events {
+			if event["message"] != fmt.Sprint(i) {
+				t.Errorf("expected message to be '%d' but got '%v'", i, event["message"])
+			}
+		}
+		if err := hook.Flush(time.Second); err != nil {
+			t.Errorf("expected flush to ignore the mirror but got %v", err)
+		}
+		if hook.DroppedCount() != 0 || mirror.DroppedCount() == 0 {
+			t.Errorf("expected only the mirror to drop entries but got %d and %d", hook.DroppedCount(), mirror.DroppedCount())
+		}
+	})
+
+	t.Run("stalled primary", func(t *testing.T) {
+		server := logrustashtest.NewServer(t, "tcp")
+		mirror := healthyHook(t, server)
+		hook, release := stalledHook()
+		WithMirror(mirror)(hook)
+		WithMirrorFlush(true)(hook)
+
+		for i := 0; i < n; i++ {
+			if err := hook.Fire(&logrus.Entry{Message: fmt.Sprint(i), Data: logrus.Fields{}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		events, err := server.WaitForEvents(n, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, event := range events {
+			if event["message"] != fmt.Sprint(i) {
+				t.Errorf("expected message to be '%d' but got '%v'", i, event["message"])
+			}
+		}
+		if err := mirror.Flush(time.Second); err != nil {
+			t.Error(err)
+		}
+		if err := hook.Flush(10 * time.Millisecond); err != ErrFlushTimeout {
+			t.Errorf("expected flush to time out waiting for the primary but got %v", err)
+		}
+
+		close(release)
+		hook.Close()
+		if state := mirror.ConnectionState(); state != StateClosed {
+			t.Errorf("expected the mirror to be closed with the hook but got '%s'", state)
+		}
+	})
+}
+
+func TestMirrorFlush(t *testing.T) {
+	conn := blockingConnMock{release: make(chan struct{})}
+	mirror := newHook(conn, "mirror_test", make(logrus.Fields), "", nil)
+	mirror.AsyncBufferSize = 1
+	mirror.makeAsync()
+	hook, err := NewHookWithConn(discardConnMock{}, "mirror_test", WithMirror(mirror), WithMirrorFlush(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(10 * time.Millisecond); err != ErrFlushTimeout {
+		t.Errorf("expected flush to wait for the mirror but got %v", err)
+	}
+	close(conn.release)
+	if err := hook.Flush(time.Second); err != nil {
+		t.Error(err)
+	}
+	hook.Close()
+}
@@ -0,0 +1,321 @@
+package logrustash
+
+import (
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures a Hook. Options are passed to the New* constructors.
+type Option func(*Hook)
+
+// WithSanitize removes C0 control characters and repairs invalid UTF-8
+// in the message and string field values before sending them to logstash.
+// If keepWhitespace is true '\n' and '\t' are kept.
+func WithSanitize(keepWhitespace bool) Option {
+	return func(h *Hook) {
+		h.formatter.Sanitize = true
+		h.formatter.SanitizeKeepWhitespace = keepWhitespace
+	}
+}
+
+// WithRedactor replaces the values of the fields matched by r with RedactedValue
+// in the messages sent to logstash. The entries themselves are not modified,
+// so other hooks and formatters still see the original values.
+func WithRedactor(r *Redactor) Option {
+	return func(h *Hook) {
+		h.formatter.Redactor = r
+	}
+}
+
+// WithLocation converts the timestamps of the entries to loc before formatting.
+func WithLocation(loc *time.Location) Option {
+	return func(h *Hook) {
+		h.formatter.Location = loc
+	}
+}
+
+// WithUTCTimestamps converts the timestamps of the entries to UTC before formatting,
+// which is the recommended practice for Logstash.
+func WithUTCTimestamps() Option {
+	return WithLocation(time.UTC)
+}
+
+// WithTimestampFunc sets the function which generates the value of "@timestamp",
+// e.g. TimestampEpochMillis. See LogstashFormatter.TimestampFunc.
+func WithTimestampFunc(fn func(time.Time) interface{}) Option {
+	return func(h *Hook) {
+		h.formatter.TimestampFunc = fn
+	}
+}
+
+// WithDialTimeout sets DialTimeout, so it is also used by the constructors
+// to establish the initial connection.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(h *Hook) {
+		h.DialTimeout = timeout
+	}
+}
+
+// WithConnectionReadTimeout sets ConnectionReadTimeout, the deadline for reading from the connection
+// the responses of protocols which acknowledge the messages (currently "lumberjack").
+// It's set before every read; without it the reads are limited by Timeout.
+func WithConnectionReadTimeout(d time.Duration) Option {
+	return func(h *Hook) {
+		h.ConnectionReadTimeout = d
+	}
+}
+
+// WithLargeUintAsString makes unsigned integers greater than math.MaxInt64
+// to be sent as strings.
+func WithLargeUintAsString() Option {
+	return func(h *Hook) {
+		h.formatter.LargeUintAsString = true
+	}
+}
+
+// WithConnFactory makes the hook use factory instead of net.Dial to establish
+// connections (including reconnects), for example to supply connections from
+// a net.Dialer with custom socket options or wrapped with some middleware.
+func WithConnFactory(factory func(protocol, address string) (net.Conn, error)) Option {
+	return func(h *Hook) {
+		h.connFactory = factory
+	}
+}
+
+// WithCallerFieldNames sets the names of the fields with the caller file, line and function,
+// which are sent when the logger reports the caller (see logrus.Logger.SetReportCaller).
+func WithCallerFieldNames(fileKey, lineKey, functionKey string) Option {
+	return func(h *Hook) {
+		h.formatter.CallerFileKey = fileKey
+		h.formatter.CallerLineKey = lineKey
+		h.formatter.CallerFunctionKey = functionKey
+	}
+}
+
+// WithCallerTrimPrefix removes prefix (e.g. GOPATH or the module root) from the caller file path.
+func WithCallerTrimPrefix(prefix string) Option {
+	return func(h *Hook) {
+		h.formatter.CallerTrimPrefix = prefix
+	}
+}
+
+// WithReconnectNotify makes the hook send the result of each reconnect attempt to ch:
+// the dial error or nil on success. Sending never blocks, so if ch is not ready
+// the notification is dropped.
+func WithReconnectNotify(ch chan<- error) Option {
+	return func(h *Hook) {
+		h.reconnectNotify = ch
+	}
+}
+
+// WithMaxSendRetries sets MaxSendRetries to n: a message is resent at most n times,
+// reconnects included, plus once over a new connection if the connection has been lost.
+// Then it is dropped and counted in DroppedCount.
+func WithMaxSendRetries(n int) Option {
+	return func(h *Hook) {
+		h.MaxSendRetries = n
+	}
+}
+
+// WithMaxReconnectRetries sets MaxReconnectRetries to n and makes the hook give up
+// when n retries to reconnect have failed: the entries buffered by an async hook are dropped
+// and Fire refuses new entries with ErrGaveUpReconnecting until the hook is re-enabled with Reset.
+// Without this option the hook tries to reconnect again for each next message.
+func WithMaxReconnectRetries(n int) Option {
+	return func(h *Hook) {
+		h.MaxReconnectRetries = n
+		h.giveUpReconnecting = true
+	}
+}
+
+// WithAlwaysSentFieldsOverride makes the fields added with WithField, WithFields or the constructors
+// override the fields of the entries with the same keys. By default the fields of the entries win.
+func WithAlwaysSentFieldsOverride(override bool) Option {
+	return func(h *Hook) {
+		h.alwaysSentFieldsOverride = override
+	}
+}
+
+// WithLevelFields makes the hook add fields to the entries of level, e.g. alert: true to the fatal ones.
+// It may be used for several levels. Fields which are already set in the entry
+// (or by WithField, WithFields and the constructors) are not overridden.
+func WithLevelFields(level logrus.Level, fields logrus.Fields) Option {
+	return func(h *Hook) {
+		if h.levelFields == nil {
+			h.levelFields = make(map[logrus.Level]logrus.Fields)
+		}
+		if h.levelFields[level] == nil {
+			h.levelFields[level] = make(logrus.Fields, len(fields))
+		}
+		for k, v := range fields {
+			h.levelFields[level][k] = v
+		}
+	}
+}
+
+// WithLevelBasedTimeout sets the timeout of sending the entries of each level in timeouts, e.g. a long one
+// for PanicLevel and FatalLevel to make sure they are delivered and a short one for DebugLevel.
+// The entries of the other levels use Timeout. A zero timeout means no timeout.
+func WithLevelBasedTimeout(timeouts map[logrus.Level]time.Duration) Option {
+	return func(h *Hook) {
+		h.levelTimeouts = make(map[logrus.Level]time.Duration, len(timeouts))
+		for level, timeout := range timeouts {
+			h.levelTimeouts[level] = timeout
+		}
+	}
+}
+
+// WithKeyTransform applies transform (e.g. SnakeCase) to the keys of the entry fields.
+// See LogstashFormatter.KeyTransform.
+func WithKeyTransform(transform func(string) string) Option {
+	return func(h *Hook) {
+		h.formatter.KeyTransform = transform
+	}
+}
+
+// WithNewlineDelimiter controls whether a newline is appended to each message,
+// as required by the json_lines codec of Logstash. It is enabled by default.
+// It is a shorthand for WithFraming(FramingNewline) or WithFraming(FramingNone).
+func WithNewlineDelimiter(enabled bool) Option {
+	if enabled {
+		return WithFraming(FramingNewline)
+	}
+	return WithFraming(FramingNone)
+}
+
+// WithSequenceField makes the hook send a sequence number in the field fieldName.
+// The number starts from 1 and is incremented for each message of the hook,
+// so lost or reordered messages (e.g. over UDP) can be detected by gaps in the sequence.
+func WithSequenceField(fieldName string) Option {
+	return func(h *Hook) {
+		h.sequenceField = fieldName
+	}
+}
+
+// WithStrictTyping controls whether numbers and booleans in the fields are sent
+// as JSON numbers and booleans (enabled, the default) or as strings.
+// See LogstashFormatter.StringValues.
+func WithStrictTyping(enabled bool) Option {
+	return func(h *Hook) {
+		h.formatter.StringValues = !enabled
+	}
+}
+
+// WithFieldCap limits how many fields are sent with an entry to n, e.g. for the entries with hundreds of fields:
+// only the first n fields sorted by key are kept and "fields_truncated": true is added.
+// See LogstashFormatter.FieldCap.
+func WithFieldCap(n int) Option {
+	return func(h *Hook) {
+		h.formatter.FieldCap = n
+	}
+}
+
+// WithCustomAppName makes the hook send fn(entry) in the "type" field instead of appName,
+// e.g. to route the entries of several services of one binary to different indices.
+// appName is sent if fn returns an empty string.
+func WithCustomAppName(fn func(*logrus.Entry) string) Option {
+	return func(h *Hook) {
+		h.customAppName = fn
+	}
+}
+
+// DefaultTypeOverrideField is the name of the entry field which overrides the "type" field
+// of the entry, see WithTypeOverrideField.
+const DefaultTypeOverrideField = "@logrustash_type"
+
+// WithTypeOverrideField sets the name of the entry field (DefaultTypeOverrideField by default)
+// whose value, if it is a non-empty string, is sent in the "type" field instead of appName
+// (and of the value of WithCustomAppName), e.g. for the plugins sharing a logger.
+// The field is only for the hook, so it isn't sent; it may also have the hook only prefix,
+// so other hooks and the formatter of the logger don't get it either. An empty fieldName disables it.
+func WithTypeOverrideField(fieldName string) Option {
+	return func(h *Hook) {
+		h.typeOverrideField = fieldName
+	}
+}
+
+// WithEntryMiddleware adds fn to the functions which transform each entry before it is
+// formatted and sent, e.g. to translate error codes or to replace the message.
+// The functions are called in the order they are added, from the goroutine which sends
+// the entry. They get a copy of the entry, which they may modify or replace with another
+// entry. If a function returns nil, the entry is dropped. See also Use.
+func WithEntryMiddleware(fn func(*logrus.Entry) *logrus.Entry) Option {
+	return func(h *Hook) {
+		h.Use(entryMiddleware(fn))
+	}
+}
+
+// WithMetadataFields makes the hook send the entry fields with keys inside the "@metadata"
+// object, which Logstash doesn't index. See LogstashFormatter.MetadataKeys.
+func WithMetadataFields(keys ...string) Option {
+	return func(h *Hook) {
+		h.formatter.MetadataKeys = append(h.formatter.MetadataKeys, keys...)
+	}
+}
+
+// WithLogstashVersion sets the value of the "@version" field, e.g. 2 for the pipelines
+// which expect it. See LogstashFormatter.LogstashVersion.
+func WithLogstashVersion(v int) Option {
+	return func(h *Hook) {
+		h.formatter.LogstashVersion = v
+	}
+}
+
+// WithLevelFieldName sets the name of the field with the level, e.g. "severity" or "log_level".
+// See LogstashFormatter.LevelFieldName.
+func WithLevelFieldName(name string) Option {
+	return func(h *Hook) {
+		h.formatter.LevelFieldName = name
+	}
+}
+
+// WithMessageFieldName sets the name of the field with the message, e.g. "msg" or "text".
+// See LogstashFormatter.MessageFieldName.
+func WithMessageFieldName(name string) Option {
+	return func(h *Hook) {
+		h.formatter.MessageFieldName = name
+	}
+}
+
+// WithLevelValueMapping makes the hook send fn(level) as the value of the level field,
+// e.g. LevelUpperCase or LevelSyslogSeverity. See LogstashFormatter.LevelValueMapper.
+func WithLevelValueMapping(fn func(logrus.Level) string) Option {
+	return func(h *Hook) {
+		h.formatter.LevelValueMapper = fn
+	}
+}
+
+// WithTagsField makes the hook send tags as a JSON array in the field fieldName ("tags" if empty),
+// e.g. for the routing in the Logstash pipeline. If an entry has the field as a []string,
+// tags are appended to it. See LogstashFormatter.Tags.
+func WithTagsField(fieldName string, tags ...string) Option {
+	return func(h *Hook) {
+		h.formatter.TagsFieldName = fieldName
+		h.formatter.Tags = tags
+	}
+}
+
+// WithEnvironmentField makes the hook send env (e.g. "production") in the field fieldName
+// ("environment" if empty) with every message, like a field added with WithField.
+func WithEnvironmentField(fieldName, env string) Option {
+	return func(h *Hook) {
+		if fieldName == "" {
+			fieldName = "environment"
+		}
+		if h.alwaysSentFields == nil {
+			h.alwaysSentFields = make(logrus.Fields)
+		}
+		h.alwaysSentFields[fieldName] = env
+	}
+}
+
+// WithTypeKey sets the name of the field with appName, e.g. "service" or "application",
+// since "type" is reserved in newer Elasticsearch versions. The field isn't sent if appName is empty.
+// See LogstashFormatter.TypeKey.
+func WithTypeKey(key string) Option {
+	return func(h *Hook) {
+		h.formatter.TypeKey = key
+	}
+}
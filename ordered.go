@@ -0,0 +1,41 @@
+package logrustash
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnorderedProtocol is returned by the constructors of a hook with WithOrderedDelivery
+// which sends its messages over UDP, where the datagrams may be reordered by the network.
+var ErrUnorderedProtocol = errors.New("Ordered delivery can't be guaranteed over UDP")
+
+// WithOrderedDelivery declares that the order of the entries matters more than throughput for the hook.
+// The async mode and the write-ahead log already send the entries one by one in the order they have
+// been fired. In sync mode the entries fired concurrently are sent one at a time as well, in the order
+// the goroutines have got to the sender: an entry waiting to be resent (see WithRetryBackoff)
+// or to be formatted isn't overtaken by the entries fired after it, so the sequence numbers
+// (see WithSequenceField) arrive in order too. The constructors return ErrUnorderedProtocol
+// if the hook sends over UDP.
+func WithOrderedDelivery() Option {
+	return func(h *Hook) {
+		h.orderedDelivery = true
+	}
+}
+
+// checkOrderedDelivery returns an error if the hook can't keep the order requested by WithOrderedDelivery.
+func (h *Hook) checkOrderedDelivery() error {
+	if !h.orderedDelivery || h.transport != nil {
+		return nil
+	}
+
+	protocol := h.protocol
+	if h.conn != nil {
+		if addr := h.conn.LocalAddr(); addr != nil {
+			protocol = addr.Network()
+		}
+	}
+	if strings.HasPrefix(protocol, "udp") {
+		return ErrUnorderedProtocol
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package logrustash
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestOrderedDelivery(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewHookWithTransport(transport, "ordered_test", WithOrderedDelivery(),
+		WithMaxSendRetries(1), WithRetryBackoff(100*time.Millisecond, 100*time.Millisecond, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	// The first entry waits to be resent while the second one is fired.
+	transport.FailNext(Retryable(errors.New("busy")))
+	done := make(chan error)
+	go func() {
+		done <- hook.Fire(&logrus.Entry{Message: "first", Data: logrus.Fields{}})
+	}()
+	for transport.Attempts() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := hook.Fire(&logrus.Entry{Message: "second", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	events := transport.Events()
+	if len(events) != 2 || events[0]["message"] != "first" || events[1]["message"] != "second" {
+		t.Errorf("expected the messages 'first' and 'second' but got %v", events)
+	}
+}
+
+func TestOrderedDeliveryUDP(t *testing.T) {
+	if _, err := NewHook("udp", "127.0.0.1:9999", "ordered_test", WithOrderedDelivery()); err != ErrUnorderedProtocol {
+		t.Errorf("expected ErrUnorderedProtocol but got %v", err)
+	}
+
+	conn, err := net.Dial("udp", "127.0.0.1:9999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := NewHookWithConn(conn, "ordered_test", WithOrderedDelivery()); err != ErrUnorderedProtocol {
+		t.Errorf("expected ErrUnorderedProtocol but got %v", err)
+	}
+
+	// The order of the datagrams isn't requested.
+	hook, err := NewHook("udp", "127.0.0.1:9999", "ordered_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Close()
+}
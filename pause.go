@@ -0,0 +1,89 @@
+package logrustash
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrHookPaused is returned by Flush if the hook is paused with messages left to send,
+// and by Fire of a paused hook in sync mode without a write-ahead log.
+var ErrHookPaused = errors.New("Hook is paused")
+
+// pauseState tells whether the sender of a hook is paused (see Pause).
+// The zero value is ready to use.
+type pauseState struct {
+	sync.Mutex
+	paused  bool
+	changed chan struct{} // closed and replaced by every Pause and Resume which changes paused
+}
+
+// state returns whether the hook is paused and a channel which is closed when it changes.
+func (p *pauseState) state() (paused bool, changed <-chan struct{}) {
+	p.Lock()
+	defer p.Unlock()
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return p.paused, p.changed
+}
+
+func (p *pauseState) set(paused bool) {
+	p.Lock()
+	defer p.Unlock()
+	if p.paused == paused {
+		return
+	}
+	p.paused = paused
+	if p.changed != nil {
+		close(p.changed)
+		p.changed = nil
+	}
+}
+
+// Pause makes the hook stop sending messages to logstash, e.g. during a planned maintenance
+// of logstash, until Resume is called. The fired entries keep accumulating in the buffer of
+// the async mode (subject to WithBufferFullStrategy) or in the write-ahead log; in sync mode
+// without a write-ahead log there is nowhere to keep them, so they are dropped and Fire returns
+// ErrHookPaused. Pause and Resume may be called concurrently and more than once.
+func (h *Hook) Pause() {
+	if h.parent != nil {
+		// A child hook sends its messages through its parent.
+		h.parent.Pause()
+		return
+	}
+	h.pause.set(true)
+}
+
+// Resume makes a hook paused by Pause send messages again, starting with the oldest one.
+func (h *Hook) Resume() {
+	if h.parent != nil {
+		h.parent.Resume()
+		return
+	}
+	h.pause.set(false)
+}
+
+// Paused reports whether the hook is paused by Pause.
+func (h *Hook) Paused() bool {
+	if h.parent != nil {
+		return h.parent.Paused()
+	}
+	paused, _ := h.pause.state()
+	return paused
+}
+
+// waitResumed blocks while the hook is paused.
+// It returns false if closeChan is closed while waiting.
+func (h *Hook) waitResumed(closeChan <-chan struct{}) bool {
+	for {
+		paused, changed := h.pause.state()
+		if !paused {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-closeChan:
+			return false
+		}
+	}
+}
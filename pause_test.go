@@ -0,0 +1,122 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestPause(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewAsyncHookWithTransport(transport, "pause_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	// Pause and Resume are idempotent and may be called concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hook.Pause()
+		}()
+	}
+	wg.Wait()
+	if !hook.Paused() {
+		t.Fatal("expected the hook to be paused")
+	}
+
+	messages := []string{"one", "two", "three"}
+	for _, message := range messages {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	started := time.Now()
+	if err := hook.Flush(5 * time.Second); err != ErrHookPaused {
+		t.Errorf("expected ErrHookPaused but got %v", err)
+	}
+	if time.Since(started) > time.Second {
+		t.Error("expected Flush to return right away while the hook is paused")
+	}
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("expected nothing to be sent while the hook is paused but got %v", events)
+	}
+
+	hook.Resume()
+	hook.Resume()
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	events := transport.Events()
+	if len(events) != len(messages) {
+		t.Fatalf("expected %d events but got %v", len(messages), events)
+	}
+	for i, message := range messages {
+		if events[i]["message"] != message {
+			t.Errorf("expected message '%s' but got '%v'", message, events[i]["message"])
+		}
+	}
+}
+
+func TestPauseSync(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "pause_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	hook.Pause()
+	if err := hook.Fire(&logrus.Entry{Message: "dropped", Data: logrus.Fields{}}); err != ErrHookPaused {
+		t.Errorf("expected ErrHookPaused but got %v", err)
+	}
+	if n := hook.droppedByReason[DropReasonPaused]; n != 1 {
+		t.Errorf("expected 1 entry dropped because the hook is paused but got %d", n)
+	}
+	if err := hook.Flush(time.Second); err != nil {
+		t.Errorf("expected nothing to flush but got %v", err)
+	}
+
+	hook.Resume()
+	if err := hook.Fire(&logrus.Entry{Message: "sent", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "sent" {
+		t.Errorf("expected message 'sent' but got '%s'", res["message"])
+	}
+}
+
+func TestPauseWAL(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	hook, err := NewHook("tcp", server.Addr(), "pause_test", WithWAL(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	hook.Pause()
+	fireMessages(t, hook, "one", "two")
+	if err := hook.Flush(5 * time.Second); err != ErrHookPaused {
+		t.Errorf("expected ErrHookPaused but got %v", err)
+	}
+	if events := server.Events(); len(events) != 0 {
+		t.Errorf("expected nothing to be sent while the hook is paused but got %v", events)
+	}
+
+	hook.Resume()
+	fireMessages(t, hook, "three")
+	checkEventMessages(t, server, "one", "two", "three")
+}
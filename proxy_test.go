@@ -0,0 +1,154 @@
+package logrustash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// serveSOCKS5 accepts a single connection on listener and proxies it
+// according to a minimal no-auth SOCKS5 CONNECT handshake.
+func serveSOCKS5(t *testing.T, listener net.Listener, requested chan<- string) {
+	client, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	r := bufio.NewReader(client)
+
+	// Greeting: version, number of methods, methods.
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := io.ReadFull(r, make([]byte, header[1])); err != nil {
+		t.Error(err)
+		return
+	}
+	client.Write([]byte{5, 0})
+
+	// Request: version, command, reserved, address type, address, port.
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(r, request); err != nil {
+		t.Error(err)
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(r, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length, _ := r.ReadByte()
+		name := make([]byte, length)
+		io.ReadFull(r, name)
+		host = string(name)
+	default:
+		t.Errorf("unexpected address type %d", request[3])
+		return
+	}
+	port := make([]byte, 2)
+	io.ReadFull(r, port)
+	address := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	requested <- address
+
+	target, err := net.Dial("tcp", address)
+	if err != nil {
+		client.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	client.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(client, target)
+	io.Copy(target, r)
+}
+
+func TestProxy(t *testing.T) {
+	logstash, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logstash.Close()
+	socks, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socks.Close()
+	requested := make(chan string, 1)
+	go serveSOCKS5(t, socks, requested)
+
+	proxyURL, _ := url.Parse("socks5://" + socks.Addr().String())
+	hook, err := NewHook("tcp", logstash.Addr().String(), "proxy_test", WithProxy(proxyURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if address := <-requested; address != logstash.Addr().String() {
+		t.Errorf("expected proxy to be asked for '%s' but got '%s'", logstash.Addr(), address)
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello proxy", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	conn, err := logstash.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var res map[string]string
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "hello proxy" {
+		t.Errorf("expected message to be '%s' but got '%s'", "hello proxy", res["message"])
+	}
+}
+
+func TestProxyUnsupportedScheme(t *testing.T) {
+	proxyURL, _ := url.Parse("ftp://127.0.0.1:21")
+	if _, err := NewHook("tcp", "127.0.0.1:9999", "proxy_test", WithProxy(proxyURL)); err == nil {
+		t.Error("expected unsupported proxy scheme to fail")
+	}
+}
+
+func TestProxyTLS(t *testing.T) {
+	logstash, config := newTLSListener(t)
+	messages := make(chan string, 1)
+	go readTLSMessage(t, logstash, messages)
+	socks, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socks.Close()
+	requested := make(chan string, 1)
+	go serveSOCKS5(t, socks, requested)
+
+	proxyURL, _ := url.Parse("socks5://" + socks.Addr().String())
+	hook, err := NewHook("tls", logstash.Addr().String(), "proxy_test", WithProxy(proxyURL), WithTLSConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if address := <-requested; address != logstash.Addr().String() {
+		t.Errorf("expected proxy to be asked for '%s' but got '%s'", logstash.Addr(), address)
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello proxy", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-messages; message != "hello proxy" {
+		t.Errorf("expected message to be '%s' but got '%s'", "hello proxy", message)
+	}
+}
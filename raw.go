@@ -0,0 +1,76 @@
+package logrustash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidRawMessage is returned by SendRaw with WithRawValidation if the message isn't a single JSON object.
+var ErrInvalidRawMessage = errors.New("Raw message is not a single JSON object")
+
+// rawMessageKey is the key of the context of an entry which carries a message passed to SendRaw.
+type rawMessageKey struct{}
+
+// newRawEntry returns an entry which carries a copy of data through the buffer, the write-ahead log
+// and the drop callbacks. Its Message is data, so the callbacks can tell what has been dropped.
+func newRawEntry(data []byte, now time.Time) *logrus.Entry {
+	data = append([]byte(nil), data...)
+	return &logrus.Entry{
+		Data:    logrus.Fields{},
+		Time:    now,
+		Level:   logrus.InfoLevel,
+		Message: string(data),
+		Context: context.WithValue(context.Background(), rawMessageKey{}, data),
+	}
+}
+
+// rawMessage returns the message carried by an entry created by newRawEntry.
+func rawMessage(entry *logrus.Entry) ([]byte, bool) {
+	if entry.Context == nil {
+		return nil, false
+	}
+	data, ok := entry.Context.Value(rawMessageKey{}).([]byte)
+	return data, ok
+}
+
+// SendRaw sends data, an already serialized message (e.g. a JSON document produced by another component),
+// through the same buffer, retries and connection as the entries fired by the hook, subject to the same
+// buffer strategy and size limits. data is framed as set by WithFraming, so it must not contain the frame
+// itself, e.g. the trailing newline. The fields of the hook, the middlewares, the levels, sampling and
+// deduplication don't apply to it. data isn't checked unless WithRawValidation is used.
+func (h *Hook) SendRaw(data []byte) error {
+	if h.parent != nil {
+		return h.parent.SendRaw(data)
+	}
+	if h.ConnectionState() == StateClosed {
+		return ErrHookClosed
+	}
+	if h.validateRaw && !isJSONObject(data) {
+		return ErrInvalidRawMessage
+	}
+
+	entry := newRawEntry(data, h.clock())
+	if h.hasGivenUp() {
+		h.fallbackEntry(entry)
+		h.drop(entry, DropReasonGaveUp)
+		return ErrGaveUpReconnecting
+	}
+
+	return h.enqueue(entry)
+}
+
+// WithRawValidation makes SendRaw return ErrInvalidRawMessage if the message isn't a single JSON object.
+func WithRawValidation() Option {
+	return func(h *Hook) {
+		h.validateRaw = true
+	}
+}
+
+func isJSONObject(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) && json.Valid(data)
+}
@@ -0,0 +1,118 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestSendRaw(t *testing.T) {
+	const raw = `{"message":"second","type":"native"}`
+	for _, framing := range []Framing{FramingNewline, FramingLengthPrefixBE32} {
+		conn := ConnMock{buff: bytes.NewBufferString("")}
+		hook, err := NewHookWithFieldsAndConn(conn, "raw_test", logrus.Fields{"always": "sent"}, WithFraming(framing))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.Fire(&logrus.Entry{Message: "first", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.SendRaw([]byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.Fire(&logrus.Entry{Message: "third", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var frames [][]byte
+		data := conn.buff.Bytes()
+		if framing == FramingNewline {
+			frames = bytes.SplitAfter(data, []byte("\n"))
+			frames = frames[:len(frames)-1]
+		} else {
+			for len(data) >= 4 {
+				n := binary.BigEndian.Uint32(data)
+				frames = append(frames, data[4:4+n])
+				data = data[4+n:]
+			}
+		}
+		if len(frames) != 3 {
+			t.Fatalf("%v: expected 3 frames but got %q", framing, conn.buff.Bytes())
+		}
+		// The raw message is sent as is, in order with the entries.
+		expected := raw
+		if framing == FramingNewline {
+			expected += "\n"
+		}
+		if string(frames[1]) != expected {
+			t.Errorf("%v: expected the raw message as is but got %q", framing, frames[1])
+		}
+		for i, message := range []string{"first", "second", "third"} {
+			var res map[string]string
+			if err := json.Unmarshal(frames[i], &res); err != nil {
+				t.Fatalf("%v: %v in %q", framing, err, frames[i])
+			}
+			if res["message"] != message {
+				t.Errorf("%v: expected message '%s' but got '%s'", framing, message, res["message"])
+			}
+			if sent := res["always"] == "sent"; sent == (i == 1) {
+				t.Errorf("%v: expected the fields of the hook only in the entries but got %q", framing, frames[i])
+			}
+		}
+	}
+}
+
+func TestSendRawAsync(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewAsyncHookWithTransport(transport, "raw_test", WithRawValidation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			err = hook.Fire(&logrus.Entry{Message: "entry", Data: logrus.Fields{"i": i}})
+		} else {
+			err = hook.SendRaw([]byte(fmt.Sprintf(`{"message":"raw","i":%d}`, i)))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	events := transport.Events()
+	if len(events) != 10 {
+		t.Fatalf("expected 10 events but got %d", len(events))
+	}
+	for i, event := range events {
+		if event["i"] != float64(i) {
+			t.Errorf("expected the event %d in order but got %v", i, event)
+		}
+	}
+	for _, message := range transport.Messages() {
+		if bytes.Count(message, []byte("\n")) != 1 {
+			t.Errorf("expected a single newline but got %q", message)
+		}
+	}
+
+	for _, invalid := range []string{"", "[1]", `{"a":1}{"b":2}`, `{"a":`} {
+		if err := hook.SendRaw([]byte(invalid)); err != ErrInvalidRawMessage {
+			t.Errorf("expected ErrInvalidRawMessage for %q but got %v", invalid, err)
+		}
+	}
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.SendRaw([]byte("{}")); err != ErrHookClosed {
+		t.Errorf("expected ErrHookClosed but got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package logrustash
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces the values of the fields matched by a Redactor.
+const RedactedValue = "[REDACTED]"
+
+// Redactor replaces the values of sensitive fields with RedactedValue.
+// Matching of field names is case-insensitive.
+type Redactor struct {
+	keys    map[string]struct{}
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+// NewRedactor creates a Redactor from a list of key patterns.
+// A pattern is either an exact field name ("password"), a glob pattern
+// as understood by path.Match ("*_token") or a regular expression
+// enclosed in slashes ("/^x-.*-secret$/").
+func NewRedactor(patterns ...string) (*Redactor, error) {
+	r := &Redactor{keys: make(map[string]struct{})}
+	for _, pattern := range patterns {
+		switch {
+		case len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+			re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid redact pattern %q: %v", pattern, err)
+			}
+			r.regexps = append(r.regexps, re)
+		case strings.ContainsAny(pattern, "*?["):
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Invalid redact pattern %q: %v", pattern, err)
+			}
+			r.globs = append(r.globs, strings.ToLower(pattern))
+		default:
+			r.keys[strings.ToLower(pattern)] = struct{}{}
+		}
+	}
+
+	return r, nil
+}
+
+// Match reports whether the value of the field `key` must be redacted.
+func (r *Redactor) Match(key string) bool {
+	lowerKey := strings.ToLower(key)
+	if _, ok := r.keys[lowerKey]; ok {
+		return true
+	}
+	for _, glob := range r.globs {
+		if ok, _ := path.Match(glob, lowerKey); ok {
+			return true
+		}
+	}
+	for _, re := range r.regexps {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact returns a copy of the value of the field `key` with all sensitive
+// values replaced, descending into nested maps and slices.
+// The original value is never modified.
+func (r *Redactor) redact(key string, v interface{}) interface{} {
+	if r.Match(key) {
+		return RedactedValue
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = r.redact(k, item)
+		}
+		return res
+	case logrus.Fields:
+		res := make(logrus.Fields, len(v))
+		for k, item := range v {
+			res[k] = r.redact(k, item)
+		}
+		return res
+	case map[string]string:
+		res := make(map[string]string, len(v))
+		for k, item := range v {
+			if r.Match(k) {
+				item = RedactedValue
+			}
+			res[k] = item
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			// Items of a slice inherit the name of the field holding the slice,
+			// which has already been checked above.
+			res[i] = r.redactItem(item)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) redactItem(v interface{}) interface{} {
+	switch v.(type) {
+	case map[string]interface{}, logrus.Fields, map[string]string, []interface{}:
+		return r.redact("", v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,157 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactorMatch(t *testing.T) {
+	r, err := NewRedactor("password", "*_token", "/^x-.*-secret$/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		key      string
+		expected bool
+	}{
+		{"password", true},
+		{"PassWord", true},
+		{"password2", false},
+		{"access_token", true},
+		{"ACCESS_TOKEN", true},
+		{"token", false},
+		{"x-api-secret", true},
+		{"X-Api-Secret", true},
+		{"x-api-secret-not", false},
+		{"user", false},
+	}
+	for _, te := range tt {
+		if res := r.Match(te.key); res != te.expected {
+			t.Errorf("expected Match(%q) to be %v but got %v", te.key, te.expected, res)
+		}
+	}
+}
+
+func TestNewRedactorInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"/(unclosed/", "[unclosed"} {
+		if _, err := NewRedactor(pattern); err == nil {
+			t.Errorf("expected pattern %q to return an error", pattern)
+		}
+	}
+}
+
+func TestLogstashFormatterRedact(t *testing.T) {
+	r, err := NewRedactor("password", "authorization", "ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := LogstashFormatter{Redactor: r}
+
+	headers := map[string]interface{}{"Authorization": "Bearer xyz", "Accept": "*/*"}
+	users := []interface{}{map[string]interface{}{"name": "alice", "SSN": "123-45-6789"}}
+	entry := logrus.WithFields(logrus.Fields{
+		"Password": "hunter2",
+		"user":     "alice",
+		"request":  map[string]interface{}{"headers": headers},
+		"users":    users,
+	})
+	entry.Message = "login"
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if data["Password"] != RedactedValue {
+		t.Errorf("expected Password to be redacted but got %v", data["Password"])
+	}
+	if data["user"] != "alice" {
+		t.Errorf("expected user to be '%s' but got '%v'", "alice", data["user"])
+	}
+	expectedHeaders := map[string]interface{}{"Authorization": RedactedValue, "Accept": "*/*"}
+	resHeaders := data["request"].(map[string]interface{})["headers"]
+	if !reflect.DeepEqual(expectedHeaders, resHeaders) {
+		t.Errorf("expected headers to be '%v' but got '%v'", expectedHeaders, resHeaders)
+	}
+	resUser := data["users"].([]interface{})[0].(map[string]interface{})
+	if resUser["SSN"] != RedactedValue || resUser["name"] != "alice" {
+		t.Errorf("expected only SSN to be redacted but got '%v'", resUser)
+	}
+
+	// The entry must stay untouched.
+	if entry.Data["Password"] != "hunter2" {
+		t.Errorf("expected entry password to be untouched but got '%v'", entry.Data["Password"])
+	}
+	if headers["Authorization"] != "Bearer xyz" {
+		t.Errorf("expected nested entry value to be untouched but got '%v'", headers["Authorization"])
+	}
+	if users[0].(map[string]interface{})["SSN"] != "123-45-6789" {
+		t.Errorf("expected nested entry value to be untouched but got '%v'", users[0])
+	}
+}
+
+func TestFireRedact(t *testing.T) {
+	r, err := NewRedactor("*secret*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "redact_test", WithRedactor(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &logrus.Entry{Message: "hello", Data: logrus.Fields{"MySecretKey": "42"}}
+	if err := hook.Fire(entry); err != nil {
+		t.Error(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Error(err)
+	}
+	if res["MySecretKey"] != RedactedValue {
+		t.Errorf("expected MySecretKey to be redacted but got '%s'", res["MySecretKey"])
+	}
+	if entry.Data["MySecretKey"] != "42" {
+		t.Errorf("expected entry value to be untouched but got '%v'", entry.Data["MySecretKey"])
+	}
+}
+
+func TestLogstashFormatterRedactGeneratedFieldNames(t *testing.T) {
+	r, err := NewRedactor("message", "level", "type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := LogstashFormatter{Type: "app", Redactor: r}
+
+	// The fields with the names of the generated fields are sent as "fields.message" etc.
+	entry := logrus.WithFields(logrus.Fields{"message": "hunter2", "level": "secret", "type": "card"})
+	entry.Message = "login"
+	entry.Level = logrus.InfoLevel
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"fields.message", "fields.level", "fields.type"} {
+		if data[k] != RedactedValue {
+			t.Errorf("expected %s to be redacted but got '%v'", k, data[k])
+		}
+	}
+	if data["message"] != "login" || data["level"] != "info" || data["type"] != "app" {
+		t.Errorf("expected the generated fields to be kept but got %v", data)
+	}
+}
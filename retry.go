@@ -0,0 +1,131 @@
+package logrustash
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// WithRetryBudget limits how many times per second the hook may resend messages
+// (across all messages), so retries don't amplify the load of an overloaded Logstash.
+// Retries beyond the budget wait for it instead of being sent immediately.
+func WithRetryBudget(rps float64) Option {
+	return func(h *Hook) {
+		h.retryBudget = rate.NewLimiter(rate.Limit(rps), 1)
+	}
+}
+
+// waitRetryBudget blocks until the retry budget allows one more retry.
+// It returns ErrHookClosed if the hook is closed while waiting.
+func (h *Hook) waitRetryBudget() error {
+	if h.retryBudget == nil {
+		return nil
+	}
+
+	reservation := h.retryBudget.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	_, closeChan := h.channels()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-closeChan:
+		reservation.Cancel()
+		return ErrHookClosed
+	}
+}
+
+// WithMaxRetryElapsedTime limits how long the hook tries to send a message, including
+// the resends and the reconnects (see MaxRetryElapsedTime). A message which is still
+// not sent after d is dropped, so a sustained outage doesn't hold up the following messages
+// regardless of whether the attempts fail immediately or time out.
+func WithMaxRetryElapsedTime(d time.Duration) Option {
+	return func(h *Hook) {
+		h.MaxRetryElapsedTime = d
+	}
+}
+
+// WithRetryBackoff makes the hook wait before each resend of a message: base before the first one,
+// doubling for each next one up to max (zero means no limit). jitter (from 0 to 1) is the fraction
+// of each delay which is randomized, so the hooks of many processes don't resend at the same time.
+// Without this option the messages are resent immediately.
+func WithRetryBackoff(base, max time.Duration, jitter float64) Option {
+	return func(h *Hook) {
+		h.RetryBaseDelay = base
+		h.RetryMaxDelay = max
+		h.RetryJitter = jitter
+	}
+}
+
+// retryDelay returns how long to wait before the resend which follows sendRetries resends.
+func (h *Hook) retryDelay(sendRetries int) time.Duration {
+	if h.RetryBaseDelay <= 0 {
+		return 0
+	}
+
+	delay := float64(h.RetryBaseDelay) * math.Pow(2, float64(sendRetries))
+	if h.RetryMaxDelay > 0 && delay > float64(h.RetryMaxDelay) {
+		delay = float64(h.RetryMaxDelay)
+	}
+	if delay > math.MaxInt64 {
+		delay = math.MaxInt64
+	}
+	if jitter := math.Min(h.RetryJitter, 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// waitRetryDelay blocks for the delay before the resend which follows sendRetries resends.
+// It returns ErrHookClosed if the hook is closed while waiting.
+func (h *Hook) waitRetryDelay(sendRetries int) error {
+	delay := h.retryDelay(sendRetries)
+	if delay <= 0 {
+		return nil
+	}
+
+	var elapsed <-chan time.Time
+	if h.after != nil {
+		elapsed = h.after(delay)
+	} else {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		elapsed = timer.C
+	}
+	_, closeChan := h.channels()
+	select {
+	case <-elapsed:
+		return nil
+	case <-closeChan:
+		return ErrHookClosed
+	}
+}
+
+func (h *Hook) clock() time.Time {
+	if h.now == nil {
+		return time.Now()
+	}
+
+	return h.now()
+}
+
+// retryTimeExceeded reports whether a message which the hook started to send at started
+// mustn't be retried anymore because of MaxRetryElapsedTime.
+func (h *Hook) retryTimeExceeded(started time.Time) bool {
+	return h.MaxRetryElapsedTime > 0 && h.clock().Sub(started) >= h.MaxRetryElapsedTime
+}
+
+// abandonRetries drops a message which couldn't be sent within MaxRetryElapsedTime.
+func (h *Hook) abandonRetries(entry *logrus.Entry, err net.Error) error {
+	h.drop(entry, DropReasonRetryTimeExceeded)
+	return &DroppedError{Reason: "max retry elapsed time exceeded", Err: err}
+}
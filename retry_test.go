@@ -0,0 +1,204 @@
+package logrustash
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// timeoutConnMock counts the writes and always fails with a temporary error.
+type timeoutConnMock struct {
+	ConnMock
+	writes int
+}
+
+func (c *timeoutConnMock) Write(b []byte) (int, error) {
+	c.writes++
+	return 0, temporaryError{}
+}
+
+func TestRetryBudget(t *testing.T) {
+	conn := &timeoutConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(conn, "retry_test", WithRetryBudget(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxSendRetries = 5
+
+	start := time.Now()
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err == nil {
+		t.Error("expected fire to fail")
+	}
+	// The first retry is within the budget, the other 4 wait for 50ms each.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected retries to be limited by the budget but they took %s", elapsed)
+	}
+	if conn.writes != 6 {
+		t.Errorf("expected 6 writes but got %d", conn.writes)
+	}
+}
+
+func TestRetryBudgetClose(t *testing.T) {
+	conn := &timeoutConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(conn, "retry_test", WithRetryBudget(0.001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxSendRetries = 5
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		hook.Close()
+	}()
+	if err := hook.sendMessage(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != ErrHookClosed {
+		t.Errorf("expected error to be '%v' but got '%v'", ErrHookClosed, err)
+	}
+}
+
+// clockConnMock always fails with a temporary error after the time of an attempt passes on a fake clock.
+type clockConnMock struct {
+	ConnMock
+	now         time.Time
+	attemptTime time.Duration
+	writes      int
+}
+
+func (c *clockConnMock) Write(b []byte) (int, error) {
+	c.writes++
+	c.now = c.now.Add(c.attemptTime)
+	return 0, temporaryError{}
+}
+
+func TestMaxRetryElapsedTime(t *testing.T) {
+	tt := []struct {
+		name        string
+		maxElapsed  time.Duration
+		attemptTime time.Duration
+		writes      int
+	}{
+		{"fast failures", 100 * time.Millisecond, time.Millisecond, 100},
+		{"slow timeouts", 2 * time.Minute, 30 * time.Second, 4},
+	}
+	for _, te := range tt {
+		conn := &clockConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, attemptTime: te.attemptTime}
+		hook, err := NewHookWithConn(conn, "retry_test", WithMaxRetryElapsedTime(te.maxElapsed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook.MaxSendRetries = 1000
+		hook.now = func() time.Time { return conn.now }
+
+		err = hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}})
+		if _, ok := err.(*DroppedError); !ok {
+			t.Errorf("%s: expected the message to be dropped but got '%v'", te.name, err)
+		}
+		if conn.writes != te.writes {
+			t.Errorf("%s: expected %d writes but got %d", te.name, te.writes, conn.writes)
+		}
+		if n := hook.droppedByReason[DropReasonRetryTimeExceeded]; n != 1 {
+			t.Errorf("%s: expected 1 dropped message but got %d", te.name, n)
+		}
+	}
+}
+
+// brokenConnMock counts the writes and always fails with a permanent error.
+type brokenConnMock struct {
+	ConnMock
+	writes *int
+}
+
+func (c brokenConnMock) Write(b []byte) (int, error) {
+	*c.writes++
+	return 0, &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}
+}
+
+func TestMaxSendRetriesWithReconnects(t *testing.T) {
+	writes, dials := 0, 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		dials++
+		return brokenConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, writes: &writes}, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "retry_test", WithConnFactory(factory), WithMaxSendRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxReconnectRetries = 1
+
+	// Each write fails on a fresh connection, so the message must be dropped after the retries.
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err == nil {
+		t.Error("expected fire to fail")
+	}
+	if writes != 4 || dials != 4 {
+		t.Errorf("expected 4 writes and 4 dials but got %d and %d", writes, dials)
+	}
+	if hook.DroppedCount() != 1 {
+		t.Errorf("expected 1 dropped entry but got %d", hook.DroppedCount())
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tt := []struct {
+		name   string
+		jitter float64
+	}{
+		{"without jitter", 0},
+		{"with jitter", 0.5},
+	}
+	for _, te := range tt {
+		conn := &clockConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+		hook, err := NewHookWithConn(conn, "retry_test", WithRetryBackoff(100*time.Millisecond, time.Second, te.jitter), WithMaxSendRetries(6))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var delays []time.Duration
+		hook.now = func() time.Time { return conn.now }
+		hook.after = func(d time.Duration) <-chan time.Time {
+			delays = append(delays, d)
+			conn.now = conn.now.Add(d)
+			ch := make(chan time.Time, 1)
+			ch <- conn.now
+			return ch
+		}
+
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err == nil {
+			t.Errorf("%s: expected fire to fail", te.name)
+		}
+		expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+		if len(delays) != len(expected) {
+			t.Fatalf("%s: expected %d delays but got %v", te.name, len(expected), delays)
+		}
+		for i, delay := range delays {
+			max := expected[i] * time.Millisecond
+			min := max - time.Duration(float64(max)*te.jitter)
+			if delay < min || delay > max {
+				t.Errorf("%s: expected delay %d to be in [%s, %s] but got %s", te.name, i, min, max, delay)
+			}
+		}
+	}
+
+	// The delays count towards MaxRetryElapsedTime.
+	conn := &clockConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(conn, "retry_test", WithRetryBackoff(time.Second, 0, 0), WithMaxRetryElapsedTime(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxSendRetries = 1000
+	hook.now = func() time.Time { return conn.now }
+	hook.after = func(d time.Duration) <-chan time.Time {
+		conn.now = conn.now.Add(d)
+		ch := make(chan time.Time, 1)
+		ch <- conn.now
+		return ch
+	}
+	if _, ok := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}).(*DroppedError); !ok {
+		t.Error("expected the message to be dropped")
+	}
+	// The delays of 1s, 2s and 4s exceed 5s.
+	if conn.writes != 3 {
+		t.Errorf("expected 3 writes but got %d", conn.writes)
+	}
+}
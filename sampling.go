@@ -0,0 +1,46 @@
+package logrustash
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sampler decides which entries are sent according to the sampling rates of their levels.
+type sampler struct {
+	sync.Mutex // protects rand
+	rates      map[logrus.Level]float64
+	rand       *rand.Rand
+}
+
+// WithSamplingRate makes the hook send only a fraction rate of the entries of level:
+// 1 sends all entries, 0.1 sends roughly 10% of them and 0 drops all of them.
+// Dropped entries are counted in DroppedCount.
+func WithSamplingRate(level logrus.Level, rate float64) Option {
+	return func(h *Hook) {
+		if h.sampler == nil {
+			h.sampler = &sampler{
+				rates: make(map[logrus.Level]float64),
+				rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+			}
+		}
+		h.sampler.rates[level] = rate
+	}
+}
+
+// sample reports whether an entry of level must be sent.
+func (s *sampler) sample(level logrus.Level) bool {
+	rate, ok := s.rates[level]
+	if !ok || rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	return s.rand.Float64() < rate
+}
@@ -0,0 +1,52 @@
+package logrustash
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSamplingRate(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "sampling_test",
+		WithSamplingRate(logrus.DebugLevel, 0),
+		WithSamplingRate(logrus.InfoLevel, 0.5),
+		WithSamplingRate(logrus.ErrorLevel, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10000
+	for _, level := range []logrus.Level{logrus.DebugLevel, logrus.InfoLevel, logrus.WarnLevel, logrus.ErrorLevel} {
+		for i := 0; i < n; i++ {
+			if err := hook.Fire(&logrus.Entry{Message: "hello", Level: level, Data: logrus.Fields{}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// All debug entries and roughly half of the info entries are dropped.
+	dropped := hook.DroppedCount()
+	if dropped < n+n*4/10 || dropped > n+n*6/10 {
+		t.Errorf("expected roughly %d dropped entries but got %d", n+n/2, dropped)
+	}
+	sent := uint64(bytes.Count(conn.buff.Bytes(), []byte("\n")))
+	if sent+dropped != 4*n {
+		t.Errorf("expected %d sent and dropped entries but got %d sent and %d dropped", 4*n, sent, dropped)
+	}
+}
+
+func TestSamplingRateFilterHookOnly(t *testing.T) {
+	hook := NewFilterHookWithPrefix("_", WithSamplingRate(logrus.InfoLevel, 0))
+	entry := &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{"_host": "localhost", "name": "test"}}
+	if err := hook.Fire(entry); err != nil {
+		t.Error(err)
+	}
+	if _, ok := entry.Data["_host"]; ok {
+		t.Error("expected hook only fields to be removed from dropped entries")
+	}
+	if hook.DroppedCount() != 1 {
+		t.Errorf("expected 1 dropped entry but got %d", hook.DroppedCount())
+	}
+}
@@ -0,0 +1,98 @@
+package logrustash
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sanitizer removes C0 control characters and repairs invalid UTF-8 sequences
+// in string values, including strings nested in maps and slices.
+type sanitizer struct {
+	keepWhitespace bool // keep '\n' and '\t'
+	changed        bool // set if at least one value has been modified
+}
+
+func (s *sanitizer) isStripped(r rune) bool {
+	if r >= 0x20 {
+		return false
+	}
+	if s.keepWhitespace && (r == '\n' || r == '\t') {
+		return false
+	}
+	return true
+}
+
+func (s *sanitizer) needsSanitizing(str string) bool {
+	if !utf8.ValidString(str) {
+		return true
+	}
+	for i := 0; i < len(str); i++ {
+		if s.isStripped(rune(str[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sanitizer) sanitizeString(str string) string {
+	if !s.needsSanitizing(str) {
+		return str
+	}
+	s.changed = true
+
+	var b strings.Builder
+	b.Grow(len(str))
+	for i := 0; i < len(str); {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		i += size
+		if s.isStripped(r) {
+			continue
+		}
+		// An invalid byte is decoded as utf8.RuneError, which is written as U+FFFD.
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sanitize returns a sanitized copy of v. Maps and slices are copied, so
+// the original value is never modified.
+func (s *sanitizer) sanitize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return s.sanitizeString(v)
+	case []string:
+		res := make([]string, len(v))
+		for i, item := range v {
+			res[i] = s.sanitizeString(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = s.sanitize(item)
+		}
+		return res
+	case map[string]string:
+		res := make(map[string]string, len(v))
+		for k, item := range v {
+			res[k] = s.sanitizeString(item)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = s.sanitize(item)
+		}
+		return res
+	case logrus.Fields:
+		res := make(logrus.Fields, len(v))
+		for k, item := range v {
+			res[k] = s.sanitize(item)
+		}
+		return res
+	default:
+		return v
+	}
+}
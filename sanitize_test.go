@@ -0,0 +1,121 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSanitizer(t *testing.T) {
+	tt := []struct {
+		keepWhitespace bool
+		value          interface{}
+		expected       interface{}
+		changed        bool
+	}{
+		{false, "clean value", "clean value", false},
+		{false, "nul\x00byte", "nulbyte", true},
+		{false, "bell\x07 and esc\x1b", "bell and esc", true},
+		{false, "line\nbreak\ttab", "linebreaktab", true},
+		{true, "line\nbreak\ttab", "line\nbreak\ttab", false},
+		{true, "line\r\nbreak", "line\nbreak", true},
+		{false, "bad\xff\xfeutf8", "bad��utf8", true},
+		{false, "trunc\xe2\x82", "trunc��", true},
+		{false, 42, 42, false},
+		{false, []string{"ok", "n\x00ul"}, []string{"ok", "nul"}, true},
+		{false, []interface{}{"a\x01", 1, []string{"\x02b"}}, []interface{}{"a", 1, []string{"b"}}, true},
+		{false,
+			map[string]interface{}{"inner": map[string]interface{}{"deep": "x\x00y\xff"}, "n": 1},
+			map[string]interface{}{"inner": map[string]interface{}{"deep": "xy�"}, "n": 1},
+			true},
+		{false, logrus.Fields{"a": "\x00"}, logrus.Fields{"a": ""}, true},
+		{false, map[string]string{"a": "b\x1f"}, map[string]string{"a": "b"}, true},
+	}
+
+	for _, te := range tt {
+		s := sanitizer{keepWhitespace: te.keepWhitespace}
+		res := s.sanitize(te.value)
+		if !reflect.DeepEqual(te.expected, res) {
+			t.Errorf("expected %q to be sanitized to %q but got %q", te.value, te.expected, res)
+		}
+		if s.changed != te.changed {
+			t.Errorf("expected changed to be %v for %q", te.changed, te.value)
+		}
+	}
+}
+
+func TestSanitizerDoesNotModifyOriginal(t *testing.T) {
+	nested := map[string]interface{}{"key": "a\x00b"}
+	slice := []string{"c\x00d"}
+	s := sanitizer{}
+	s.sanitize(map[string]interface{}{"nested": nested, "slice": slice})
+
+	if nested["key"] != "a\x00b" {
+		t.Errorf("expected nested map to be untouched but got %q", nested["key"])
+	}
+	if slice[0] != "c\x00d" {
+		t.Errorf("expected slice to be untouched but got %q", slice[0])
+	}
+}
+
+func TestLogstashFormatterSanitize(t *testing.T) {
+	lf := LogstashFormatter{Sanitize: true, SanitizeKeepWhitespace: true}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"dump":   "\x00\x01proto\xffdump",
+		"nested": map[string]interface{}{"list": []string{"a\x00", "b"}},
+	})
+	entry.Message = "multi\nline\x00"
+
+	b, err := lf.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte(`\u0000`)) || bytes.Contains(b, []byte(`\u0001`)) {
+		t.Errorf("expected control characters to be removed but got %s", b)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["message"] != "multi\nline" {
+		t.Errorf("expected message to be %q but got %q", "multi\nline", data["message"])
+	}
+	if data["dump"] != "proto�dump" {
+		t.Errorf("expected dump to be %q but got %q", "proto�dump", data["dump"])
+	}
+	list := data["nested"].(map[string]interface{})["list"].([]interface{})
+	if list[0] != "a" {
+		t.Errorf("expected nested list item to be %q but got %q", "a", list[0])
+	}
+
+	entry = logrus.WithFields(logrus.Fields{"clean": "value"})
+	entry.Message = "clean"
+	if _, err := lf.Format(entry); err != nil {
+		t.Fatal(err)
+	}
+	if lf.SanitizedCount() != 1 {
+		t.Errorf("expected sanitized count to be 1 but got %d", lf.SanitizedCount())
+	}
+}
+
+func TestFireSanitize(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "sanitize_test", WithSanitize(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{"bad\x00", "good", "bad\xff"} {
+		if err := hook.Fire(&logrus.Entry{Message: msg, Data: logrus.Fields{}}); err != nil {
+			t.Error(err)
+		}
+	}
+	if hook.SanitizedCount() != 2 {
+		t.Errorf("expected sanitized count to be 2 but got %d", hook.SanitizedCount())
+	}
+}
@@ -0,0 +1,90 @@
+package logrustash
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const shadowBufferSize = 8192
+
+// shadowEndpoint mirrors the traffic of a hook to a secondary Logstash instance.
+type shadowEndpoint struct {
+	sync.Mutex // held by the running loop, so a loop restarted by Reset waits for the previous one
+	hook       *Hook
+	protocol   string
+	address    string
+	conn       net.Conn
+	queue      chan []byte
+}
+
+// WithShadowEndpoint makes the hook send a copy of every entry to `protocol`://`address`.
+// Copies are sent on a best-effort basis: errors on the shadow connection never
+// affect the primary connection and are never returned from Fire.
+// The shadow connection uses the same reconnect parameters as the primary one.
+func WithShadowEndpoint(protocol, address string) Option {
+	return func(h *Hook) {
+		h.shadow = &shadowEndpoint{
+			hook:     h,
+			protocol: protocol,
+			address:  address,
+			queue:    make(chan []byte, shadowBufferSize),
+		}
+		go h.shadow.loop(h.closeChan)
+	}
+}
+
+// enqueue never blocks: if the shadow endpoint can't keep up, the copy is dropped.
+func (s *shadowEndpoint) enqueue(data []byte) {
+	select {
+	case s.queue <- data:
+	default:
+	}
+}
+
+func (s *shadowEndpoint) loop(closeChan <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	for {
+		select {
+		case data := <-s.queue:
+			if err := s.send(data); err != nil {
+				fmt.Println("Error during sending message to logstash shadow endpoint:", err)
+			}
+		case <-closeChan:
+			if s.conn != nil {
+				s.conn.Close()
+				s.conn = nil
+			}
+			return
+		}
+	}
+}
+
+func (s *shadowEndpoint) send(data []byte) error {
+	if s.conn == nil {
+		conn, err := s.hook.dial(s.protocol, s.address)
+		if err != nil && s.hook.MaxReconnectRetries > 0 {
+			conn, err = s.hook.redial(s.protocol, s.address, 0, nil)
+		}
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if s.hook.Timeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.hook.Timeout))
+	}
+
+	if _, err := s.conn.Write(data); err != nil {
+		// Dial a new connection on the next message.
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+
+	return nil
+}
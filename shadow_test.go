@@ -0,0 +1,99 @@
+package logrustash
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestShadowEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "shadow_test", WithShadowEndpoint("tcp", listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello shadow", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+
+	var primary map[string]string
+	if err := json.NewDecoder(conn.buff).Decode(&primary); err != nil {
+		t.Error(err)
+	}
+	if primary["message"] != "hello shadow" {
+		t.Errorf("expected primary message to be '%s' but got '%s'", "hello shadow", primary["message"])
+	}
+
+	shadowConn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shadowConn.Close()
+	shadowConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var shadow map[string]string
+	if err := json.NewDecoder(bufio.NewReader(shadowConn)).Decode(&shadow); err != nil {
+		t.Fatal(err)
+	}
+	if shadow["message"] != "hello shadow" {
+		t.Errorf("expected shadow message to be '%s' but got '%s'", "hello shadow", shadow["message"])
+	}
+}
+
+func TestShadowEndpointUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "shadow_test", WithShadowEndpoint("tcp", address))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+			t.Errorf("expected fire to not return error: %s", err)
+		}
+	}
+
+	dec := json.NewDecoder(conn.buff)
+	for i := 0; i < 3; i++ {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestShadowEndpointDialFailure(t *testing.T) {
+	var created *Hook
+	factory := func(protocol, address string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	_, err := NewHook("tcp", "logstash:9999", "shadow_test",
+		WithConnFactory(factory), WithShadowEndpoint("tcp", "shadow:9999"), func(h *Hook) { created = h })
+	if err == nil {
+		t.Fatal("expected an error when logstash can't be dialed")
+	}
+	// The hook is closed, so the goroutine of the shadow endpoint stops.
+	if state := created.ConnectionState(); state != StateClosed {
+		t.Errorf("expected the hook to be closed but got state %s", state)
+	}
+}
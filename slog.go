@@ -0,0 +1,156 @@
+package logrustash
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SlogHandler is a slog.Handler which sends the records through a Hook,
+// so the same connection, retries and buffer are used for log/slog as for logrus.
+type SlogHandler struct {
+	hook      *Hook
+	addSource bool
+	fields    logrus.Fields // attributes added with WithAttrs, flattened
+	groups    []string
+}
+
+// SlogHandlerOption configures a SlogHandler.
+type SlogHandlerOption func(*SlogHandler)
+
+// WithSlogSource makes the handler report the source of the records as the caller fields
+// (see LogstashFormatter.CallerFileKey).
+func WithSlogSource(enabled bool) SlogHandlerOption {
+	return func(h *SlogHandler) {
+		h.addSource = enabled
+	}
+}
+
+// NewSlogHandler creates a slog.Handler which sends the records through hook.
+// The attributes of groups are flattened with dots, e.g. "request.id".
+// The slog levels are mapped onto the logrus ones (see SlogLevel) and the records
+// of the levels which aren't in hook.Levels() are discarded.
+func NewSlogHandler(hook *Hook, opts ...SlogHandlerOption) *SlogHandler {
+	h := &SlogHandler{hook: hook, fields: make(logrus.Fields)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SlogLevel maps a slog level onto a logrus level: the levels between the standard
+// slog levels are rounded down, e.g. slog.LevelInfo+2 is mapped to logrus.InfoLevel,
+// and the levels below slog.LevelDebug are mapped to logrus.TraceLevel.
+func SlogLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.hook.levelEnabled(SlogLevel(level))
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := &logrus.Entry{
+		Time:    r.Time,
+		Level:   SlogLevel(r.Level),
+		Message: r.Message,
+		Data:    make(logrus.Fields, len(h.fields)+r.NumAttrs()),
+		Context: ctx,
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	for k, v := range h.fields {
+		entry.Data[k] = v
+	}
+	prefix := groupPrefix(h.groups)
+	r.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(entry.Data, prefix, attr)
+		return true
+	})
+	if h.addSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.Caller = &frame
+	}
+
+	return h.hook.Fire(entry)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := h.clone()
+	prefix := groupPrefix(h.groups)
+	for _, attr := range attrs {
+		addSlogAttr(c.fields, prefix, attr)
+	}
+	return c
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := h.clone()
+	c.groups = append(c.groups[:len(c.groups):len(c.groups)], name)
+	return c
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	c := *h
+	c.fields = make(logrus.Fields, len(h.fields))
+	for k, v := range h.fields {
+		c.fields[k] = v
+	}
+	return &c
+}
+
+func groupPrefix(groups []string) string {
+	var prefix string
+	for _, group := range groups {
+		prefix += group + "."
+	}
+	return prefix
+}
+
+// addSlogAttr adds attr to fields with the key prefixed by prefix, flattening groups.
+func addSlogAttr(fields logrus.Fields, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		// Empty attributes are ignored according to the slog.Handler contract.
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		groupAttrs := attr.Value.Group()
+		if len(groupAttrs) == 0 {
+			return
+		}
+		if attr.Key != "" {
+			// Attributes of a group without a key are inlined.
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range groupAttrs {
+			addSlogAttr(fields, prefix, groupAttr)
+		}
+		return
+	}
+
+	fields[prefix+attr.Key] = attr.Value.Any()
+}
@@ -0,0 +1,109 @@
+package logrustash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSlogHandler(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "slog_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(NewSlogHandler(hook, WithSlogSource(true)))
+
+	logger.With("service", "api").WithGroup("request").With("id", 42).Warn("slow request",
+		"duration", 1.5,
+		slog.Group("user", "name", "alice", "admin", false),
+		slog.Group("", "inlined", "yes"),
+		slog.Group("empty"),
+		"err", errors.New("timeout"),
+	)
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(conn.buff).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"message":            "slow request",
+		"level":              "warning",
+		"type":               "slog_test",
+		"service":            "api",
+		"request.id":         float64(42),
+		"request.duration":   1.5,
+		"request.user.name":  "alice",
+		"request.user.admin": false,
+		"request.inlined":    "yes",
+		"request.err":        "timeout",
+	}
+	for k, v := range expected {
+		if res[k] != v {
+			t.Errorf("expected %s to be '%v' but got '%v'", k, v, res[k])
+		}
+	}
+	if _, ok := res["request.empty"]; ok {
+		t.Error("expected empty group to be omitted")
+	}
+	if file, _ := res[defaultCallerFileKey].(string); !strings.HasSuffix(file, "slog_test.go") {
+		t.Errorf("expected caller file to be slog_test.go but got '%v'", res[defaultCallerFileKey])
+	}
+}
+
+func TestSlogHandlerWithAttrsDoesNotLeak(t *testing.T) {
+	conn := ConnMock{buff: bytes.NewBufferString("")}
+	hook, err := NewHookWithConn(conn, "slog_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(NewSlogHandler(hook))
+	logger.With("child", "yes").Info("child")
+	logger.Info("parent")
+
+	dec := json.NewDecoder(conn.buff)
+	var child, parent map[string]interface{}
+	if err := dec.Decode(&child); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&parent); err != nil {
+		t.Fatal(err)
+	}
+	if child["child"] != "yes" {
+		t.Errorf("expected child to be 'yes' but got '%v'", child["child"])
+	}
+	if _, ok := parent["child"]; ok {
+		t.Error("expected attributes of a child logger to not be sent by the parent")
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	tt := map[slog.Level]logrus.Level{
+		slog.LevelDebug - 4: logrus.TraceLevel,
+		slog.LevelDebug:     logrus.DebugLevel,
+		slog.LevelInfo:      logrus.InfoLevel,
+		slog.LevelInfo + 2:  logrus.InfoLevel,
+		slog.LevelWarn:      logrus.WarnLevel,
+		slog.LevelError:     logrus.ErrorLevel,
+		slog.LevelError + 4: logrus.ErrorLevel,
+	}
+	for level, expected := range tt {
+		if res := SlogLevel(level); res != expected {
+			t.Errorf("expected %s to be mapped to %s but got %s", level, expected, res)
+		}
+	}
+
+	handler := NewSlogHandler(NewFilterHook())
+	if !handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug level to be enabled")
+	}
+	if handler.Enabled(context.Background(), slog.LevelDebug-4) {
+		t.Error("expected trace level to be disabled")
+	}
+}
@@ -0,0 +1,144 @@
+package logrustash
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HookState describes the health of the connection of a Hook.
+type HookState int32
+
+const (
+	// StateConnected means the hook has a connection to logstash.
+	StateConnected HookState = iota
+	// StateReconnecting means the hook is trying to establish a new connection.
+	StateReconnecting
+	// StateDisconnected means the hook has no connection to logstash.
+	StateDisconnected
+	// StateClosed means the hook has been closed with Close.
+	StateClosed
+)
+
+// String implements fmt.Stringer.
+func (s HookState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionState returns the current state of the connection to logstash.
+func (h *Hook) ConnectionState() HookState {
+	state := HookState(atomic.LoadInt32(&h.state))
+	if h.parent != nil && state != StateClosed {
+		// A child hook uses the connection of its parent.
+		return h.parent.ConnectionState()
+	}
+	return state
+}
+
+// setState changes the state of the hook unless it is closed.
+func (h *Hook) setState(state HookState) {
+	for {
+		current := atomic.LoadInt32(&h.state)
+		if HookState(current) == StateClosed {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&h.state, current, int32(state)) {
+			return
+		}
+	}
+}
+
+// hasGivenUp reports whether the hook has given up reconnecting (see WithMaxReconnectRetries).
+func (h *Hook) hasGivenUp() bool {
+	return atomic.LoadInt32(&h.gaveUp) != 0
+}
+
+// giveUp makes the hook refuse new entries and drops the buffered ones.
+func (h *Hook) giveUp() {
+	atomic.StoreInt32(&h.gaveUp, 1)
+	fireChannel, _ := h.channels()
+	h.dropBuffered(fireChannel, DropReasonGaveUp)
+}
+
+// dropBuffered drops the entries waiting in fireChannel.
+func (h *Hook) dropBuffered(fireChannel chan *logrus.Entry, reason DropReason) {
+	if fireChannel == nil {
+		return
+	}
+
+	for {
+		select {
+		case entry := <-fireChannel:
+			h.drop(entry, reason)
+			h.fallbackEntry(entry)
+			h.inFlight.done()
+		default:
+			return
+		}
+	}
+}
+
+// Reset restores a disconnected or closed hook, so it doesn't need to be
+// replaced (and re-registered with logrus) after a network partition:
+// it re-enables a hook which has given up reconnecting (see WithMaxReconnectRetries),
+// restarts the sender of a closed async hook and reconnects to logstash.
+// The entries left in the buffer of a closed hook are dropped.
+// Hooks created with a connection can't be reconnected, so for them Reset returns an error.
+func (h *Hook) Reset() error {
+	h.channelsLocker.Lock()
+	if HookState(atomic.LoadInt32(&h.state)) == StateClosed {
+		if h.wal != nil {
+			if err := h.wal.open(); err != nil {
+				h.channelsLocker.Unlock()
+				return fmt.Errorf("Couldn't open the write-ahead log: %w", err)
+			}
+		}
+		oldFireChannel := h.fireChannel
+		h.closeChan = make(chan struct{})
+		if oldFireChannel != nil {
+			h.fireChannel = make(chan *logrus.Entry, h.AsyncBufferSize)
+			go h.runSender(h.fireChannel, h.closeChan)
+		}
+		if h.shadow != nil {
+			go h.shadow.loop(h.closeChan)
+		}
+		if h.fallback != nil {
+			go h.fallback.loop(h.closeChan)
+		}
+		if h.dropMetaEntryEnabled() {
+			go h.dropMeta.loop(h, h.closeChan)
+		}
+		if h.periodicFlushEnabled() {
+			go h.periodicFlush.loop(h, h.closeChan)
+		}
+		if h.wal != nil {
+			go h.wal.loop(h, h.closeChan)
+		}
+		atomic.StoreInt32(&h.state, int32(StateDisconnected))
+		h.channelsLocker.Unlock()
+		h.dropBuffered(oldFireChannel, DropReasonClosed)
+	} else {
+		h.channelsLocker.Unlock()
+	}
+	atomic.StoreInt32(&h.gaveUp, 0)
+
+	conn, ok := h.sender().(connTransport)
+	if protocol, _ := h.target(); !ok || (protocol == "" && h.getConn() == nil) {
+		// A filtering hook doesn't have anything to reconnect
+		// and a transport manages its own connection.
+		return nil
+	}
+	return conn.reconnect(0)
+}
@@ -0,0 +1,261 @@
+package logrustash
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConnectionState(t *testing.T) {
+	var hook *Hook
+	var stateDuringDial HookState
+	fail := false
+	factory := func(protocol, address string) (net.Conn, error) {
+		if hook != nil {
+			stateDuringDial = hook.ConnectionState()
+		}
+		if fail {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return ConnMock{buff: bytes.NewBufferString("")}, nil
+	}
+
+	hook, err := NewAsyncHook("tcp", "logstash:9999", "state_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := hook.ConnectionState(); state != StateConnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateConnected, state)
+	}
+
+	fail = true
+	if err := (connTransport{hook}).reconnect(0); err == nil {
+		t.Error("expected reconnect to fail")
+	}
+	if stateDuringDial != StateReconnecting {
+		t.Errorf("expected state during reconnect to be '%s' but got '%s'", StateReconnecting, stateDuringDial)
+	}
+	if state := hook.ConnectionState(); state != StateDisconnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateDisconnected, state)
+	}
+
+	fail = false
+	if err := (connTransport{hook}).reconnect(0); err != nil {
+		t.Error(err)
+	}
+	if state := hook.ConnectionState(); state != StateConnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateConnected, state)
+	}
+
+	if err := hook.Close(); err != nil {
+		t.Error(err)
+	}
+	if state := hook.ConnectionState(); state != StateClosed {
+		t.Errorf("expected state to be '%s' but got '%s'", StateClosed, state)
+	}
+	if err := hook.Close(); err != nil {
+		t.Errorf("expected second close to not return error: %s", err)
+	}
+	if err := hook.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != ErrHookClosed {
+		t.Errorf("expected fire to return '%v' but got '%v'", ErrHookClosed, err)
+	}
+
+	// A closed hook stays closed.
+	(connTransport{hook}).reconnect(0)
+	if state := hook.ConnectionState(); state != StateClosed {
+		t.Errorf("expected state to be '%s' but got '%s'", StateClosed, state)
+	}
+}
+
+func TestMaxReconnectRetries(t *testing.T) {
+	fail := false
+	dials := 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		dials++
+		if fail {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return ConnMock{buff: bytes.NewBufferString("")}, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "give_up_test", WithConnFactory(factory), WithMaxReconnectRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pretend some entries wait in the buffer of an async hook.
+	hook.fireChannel = make(chan *logrus.Entry, 2)
+	for i := 0; i < 2; i++ {
+		hook.inFlight.add()
+		hook.fireChannel <- &logrus.Entry{Data: logrus.Fields{}}
+	}
+
+	fail = true
+	dials = 0
+	if err := (connTransport{hook}).reconnect(0); err == nil {
+		t.Error("expected reconnect to fail")
+	}
+	if dials != 3 {
+		t.Errorf("expected 3 dial attempts but got %d", dials)
+	}
+	if state := hook.ConnectionState(); state != StateDisconnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateDisconnected, state)
+	}
+	if len(hook.fireChannel) != 0 {
+		t.Error("expected buffered entries to be dropped")
+	}
+	select {
+	case <-hook.inFlight.idle():
+	default:
+		t.Error("expected no entries in flight")
+	}
+	hook.fireChannel = nil
+	if err := hook.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != ErrGaveUpReconnecting {
+		t.Errorf("expected fire to return '%v' but got '%v'", ErrGaveUpReconnecting, err)
+	}
+	if hook.DroppedCount() != 3 {
+		t.Errorf("expected 3 dropped entries but got %d", hook.DroppedCount())
+	}
+
+	fail = false
+	if err := hook.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if state := hook.ConnectionState(); state != StateConnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateConnected, state)
+	}
+	if err := hook.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestReset(t *testing.T) {
+	var conn ConnMock
+	factory := func(protocol, address string) (net.Conn, error) {
+		conn = ConnMock{buff: bytes.NewBufferString("")}
+		return conn, nil
+	}
+	hook, err := NewAsyncHook("tcp", "logstash:9999", "reset_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := hook.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != ErrHookClosed {
+			t.Errorf("expected fire to return '%v' but got '%v'", ErrHookClosed, err)
+		}
+
+		if err := hook.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if state := hook.ConnectionState(); state != StateConnected {
+			t.Errorf("expected state to be '%s' but got '%s'", StateConnected, state)
+		}
+		if err := hook.Fire(&logrus.Entry{Message: "after reset", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.Flush(time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(conn.buff.Bytes(), []byte("after reset")) {
+			t.Errorf("expected the message to be sent through the new connection but got %q", conn.buff.String())
+		}
+	}
+}
+
+func TestReconnectWhileClosing(t *testing.T) {
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	lateConn := &closeTrackingConn{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, closed: make(chan struct{})}
+	dials := 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		dials++
+		if dials == 1 {
+			return ConnMock{buff: bytes.NewBufferString("")}, nil
+		}
+		close(dialing)
+		<-release
+		return lateConn, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "reconnect_test", WithConnFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- (connTransport{hook}).reconnect(0)
+	}()
+	<-dialing
+	hook.Close()
+	close(release)
+
+	if err := <-errChan; err != ErrHookClosed {
+		t.Errorf("expected reconnect to return '%v' but got '%v'", ErrHookClosed, err)
+	}
+	select {
+	case <-lateConn.closed:
+	default:
+		t.Error("expected the connection dialed after Close to be closed")
+	}
+	if hook.getConn() == lateConn {
+		t.Error("expected the connection dialed after Close to not be set")
+	}
+	if state := hook.ConnectionState(); state != StateClosed {
+		t.Errorf("expected state to be '%s' but got '%s'", StateClosed, state)
+	}
+}
+
+func TestResetWithConn(t *testing.T) {
+	hook, err := NewHookWithConn(ConnMock{buff: bytes.NewBufferString("")}, "reset_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Close()
+	if err := hook.Reset(); err == nil {
+		t.Error("expected reset of a hook with its own connection to fail")
+	}
+
+	filter := NewAsyncFilterHook()
+	filter.Close()
+	if err := filter.Reset(); err != nil {
+		t.Error(err)
+	}
+	if err := filter.Fire(&logrus.Entry{Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFilterHookConnectionState(t *testing.T) {
+	hook := NewFilterHook()
+	if state := hook.ConnectionState(); state != StateDisconnected {
+		t.Errorf("expected state to be '%s' but got '%s'", StateDisconnected, state)
+	}
+	if err := hook.Close(); err != nil {
+		t.Error(err)
+	}
+	if state := hook.ConnectionState(); state != StateClosed {
+		t.Errorf("expected state to be '%s' but got '%s'", StateClosed, state)
+	}
+}
+
+func TestHookStateString(t *testing.T) {
+	tt := map[HookState]string{
+		StateConnected:    "connected",
+		StateReconnecting: "reconnecting",
+		StateDisconnected: "disconnected",
+		StateClosed:       "closed",
+		HookState(42):     "unknown",
+	}
+	for state, expected := range tt {
+		if state.String() != expected {
+			t.Errorf("expected state string to be '%s' but got '%s'", expected, state.String())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package logrustash
+
+// StatsDClient is the part of a StatsD client used by WithStatsDMetrics.
+// It is implemented by *statsd.Client of gopkg.in/alexcesaro/statsd.v2.
+type StatsDClient interface {
+	Count(bucket string, n interface{})
+	Gauge(bucket string, value interface{})
+}
+
+// statsdMetrics reports the metrics of the hook to a StatsD client.
+type statsdMetrics struct {
+	client StatsDClient
+	prefix string
+}
+
+// WithStatsDMetrics makes the hook report its metrics to client, with the bucket names
+// prefixed with prefix and a dot:
+//
+//	<prefix>.sent         counter of the messages sent to logstash
+//	<prefix>.dropped      counter of the dropped entries (see DroppedCount)
+//	<prefix>.retried      counter of the resends of messages, after a reconnect or not
+//	<prefix>.reconnects   counter of the successful reconnects
+//	<prefix>.queue_depth  gauge of the number of entries in the buffer of the async mode, after each entry is queued
+func WithStatsDMetrics(client StatsDClient, prefix string) Option {
+	return func(h *Hook) {
+		if prefix != "" {
+			prefix += "."
+		}
+		h.statsd = &statsdMetrics{client: client, prefix: prefix}
+	}
+}
+
+// count adds n to the counter name, if the metrics are enabled.
+func (m *statsdMetrics) count(name string, n int) {
+	if m == nil {
+		return
+	}
+	m.client.Count(m.prefix+name, n)
+}
+
+// gauge sets the gauge name to value, if the metrics are enabled.
+func (m *statsdMetrics) gauge(name string, value int) {
+	if m == nil {
+		return
+	}
+	m.client.Gauge(m.prefix+name, value)
+}
@@ -0,0 +1,85 @@
+package logrustash
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// statsdClientMock records the metrics reported to it.
+type statsdClientMock struct {
+	sync.Mutex
+	counts map[string]int
+	gauges map[string]int
+}
+
+func newStatsDClientMock() *statsdClientMock {
+	return &statsdClientMock{counts: make(map[string]int), gauges: make(map[string]int)}
+}
+
+func (c *statsdClientMock) Count(bucket string, n interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	c.counts[bucket] += n.(int)
+}
+
+func (c *statsdClientMock) Gauge(bucket string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	c.gauges[bucket] = value.(int)
+}
+
+func TestStatsDMetrics(t *testing.T) {
+	client := newStatsDClientMock()
+	dials := 0
+	factory := func(protocol, address string) (net.Conn, error) {
+		dials++
+		if dials == 1 {
+			return brokenConnMock{ConnMock: ConnMock{buff: bytes.NewBufferString("")}, writes: new(int)}, nil
+		}
+		return ConnMock{buff: bytes.NewBufferString("")}, nil
+	}
+	hook, err := NewHook("tcp", "logstash:9999", "statsd_test",
+		WithConnFactory(factory),
+		WithStatsDMetrics(client, "logstash"),
+		WithSamplingRate(logrus.DebugLevel, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxReconnectRetries = 1
+
+	for _, level := range []logrus.Level{logrus.InfoLevel, logrus.InfoLevel, logrus.DebugLevel} {
+		if err := hook.Fire(&logrus.Entry{Message: "hello", Level: level, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]int{"logstash.sent": 2, "logstash.retried": 1, "logstash.reconnects": 1, "logstash.dropped": 1}
+	for bucket, n := range expected {
+		if client.counts[bucket] != n {
+			t.Errorf("expected %s to be %d but got %d", bucket, n, client.counts[bucket])
+		}
+	}
+
+	// The queue depth of the async mode.
+	conn := blockingConnMock{release: make(chan struct{})}
+	hook = newHook(conn, "statsd_test", make(logrus.Fields), "", []Option{WithStatsDMetrics(client, "")})
+	hook.AsyncBufferSize = 2
+	hook.makeAsync()
+	defer hook.Close()
+	for _, message := range []string{"sent", "buffered"} {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	client.Lock()
+	if client.gauges["queue_depth"] != 1 {
+		t.Errorf("expected queue_depth to be 1 but got %d", client.gauges["queue_depth"])
+	}
+	client.Unlock()
+	close(conn.release)
+}
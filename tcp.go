@@ -0,0 +1,71 @@
+package logrustash
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	gas "github.com/xaionaro-go/goautosocket"
+)
+
+// WithWriteBufferSize sets the size of the operating system's send buffer of TCP connections.
+// Zero leaves the OS default unchanged.
+func WithWriteBufferSize(n int) Option {
+	return func(h *Hook) {
+		h.writeBufferSize = n
+	}
+}
+
+// WithKeepalive enables TCP keep-alive probes on the connections to logstash, so connections
+// which are idle for a long time are not silently dropped by firewalls. Zero interval means
+// the OS default keep-alive period. This is unrelated to the tcp_keep_alive setting
+// of the Logstash tcp input, which controls the probes sent by Logstash.
+func WithKeepalive(interval time.Duration) Option {
+	return func(h *Hook) {
+		h.keepAlive = true
+		h.keepAlivePeriod = interval
+	}
+}
+
+// tcpConn returns the TCP connection underlying conn or nil if conn is not a TCP connection.
+func tcpConn(conn net.Conn) *net.TCPConn {
+	switch conn := conn.(type) {
+	case *net.TCPConn:
+		return conn
+	case *gas.TCPClient:
+		return conn.TCPConn
+	case *lumberjackConn:
+		return tcpConn(conn.Conn)
+	case *tls.Conn:
+		return tcpConn(conn.NetConn())
+	default:
+		return nil
+	}
+}
+
+// configureConn applies the socket options of the hook to a newly established connection.
+// Connections which don't support an option are left as is.
+func (h *Hook) configureConn(conn net.Conn) error {
+	tcp := tcpConn(conn)
+	if tcp == nil {
+		return nil
+	}
+
+	if h.writeBufferSize > 0 {
+		if err := tcp.SetWriteBuffer(h.writeBufferSize); err != nil {
+			return err
+		}
+	}
+	if h.keepAlive {
+		if err := tcp.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if h.keepAlivePeriod > 0 {
+			if err := tcp.SetKeepAlivePeriod(h.keepAlivePeriod); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package logrustash
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func getsockoptInt(t *testing.T, conn net.Conn, level, opt int) int {
+	rawConn, err := tcpConn(conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	rawConn.Control(func(fd uintptr) {
+		value, err = syscall.GetsockoptInt(int(fd), level, opt)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestWriteBufferSize(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hook, err := NewHook("tcp", listener.Addr().String(), "tcp_test", WithWriteBufferSize(64*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	// Linux doubles the requested size for bookkeeping overhead.
+	if size := getsockoptInt(t, hook.conn, syscall.SOL_SOCKET, syscall.SO_SNDBUF); size != 64*1024 && size != 2*64*1024 {
+		t.Errorf("expected send buffer to be %d but got %d", 64*1024, size)
+	}
+}
+
+func TestWriteBufferSizeUDP(t *testing.T) {
+	hook, err := NewHook("udp", "127.0.0.1:9999", "udp_test", WithWriteBufferSize(64*1024), WithKeepalive(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Close()
+}
+
+func TestKeepalive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hook, err := NewHook("tcp", listener.Addr().String(), "tcp_test", WithKeepalive(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	if keepAlive := getsockoptInt(t, hook.conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); keepAlive == 0 {
+		t.Error("expected keep-alive to be enabled")
+	}
+}
@@ -0,0 +1,60 @@
+package logrustash
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// WithTLSConfig sets the TLS configuration of the "tls" protocol, which sends the messages
+// over a TLS connection to e.g. the tcp input of Logstash with ssl_enabled. The default is
+// the zero configuration, which verifies the certificate of logstash with the system roots.
+// The server name is the host of the address of the hook unless config sets it.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(h *Hook) {
+		h.tlsConfig = config
+	}
+}
+
+// dialTLS establishes a TLS connection to address over TCP, respecting DialTimeout.
+// The TCP connection is made by the connection factory or through the proxy of the hook, if any.
+func (h *Hook) dialTLS(address string) (net.Conn, error) {
+	// The auto-reconnecting connections of goautosocket can't be used, because
+	// a TLS session can't continue over a new TCP connection.
+	var conn net.Conn
+	var err error
+	switch {
+	case h.connFactory != nil:
+		conn, err = h.dialFactory("tcp", address)
+	case h.useProxy("tcp"):
+		conn, err = h.dialProxy("tcp", address)
+	default:
+		conn, err = h.dialer("tcp").Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{}
+	if h.tlsConfig != nil {
+		config = h.tlsConfig.Clone()
+	}
+	if config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			config.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, config)
+	ctx := context.Background()
+	if h.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.DialTimeout)
+		defer cancel()
+	}
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
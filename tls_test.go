@@ -0,0 +1,79 @@
+package logrustash
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTLSListener returns a TLS listener with the certificate of httptest for 127.0.0.1
+// and a client configuration which trusts it.
+func newTLSListener(t *testing.T) (net.Listener, *tls.Config) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	certificates := server.TLS.Certificates
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener, &tls.Config{RootCAs: roots}
+}
+
+// readTLSMessage accepts a connection on listener and sends the message of the first event to messages.
+func readTLSMessage(t *testing.T, listener net.Listener, messages chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var res map[string]string
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		t.Error(err)
+	}
+	messages <- res["message"]
+}
+
+func TestTLS(t *testing.T) {
+	listener, config := newTLSListener(t)
+	messages := make(chan string, 1)
+	go readTLSMessage(t, listener, messages)
+
+	hook, err := NewHook("tls", listener.Addr().String(), "tls_test", WithTLSConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	if err := hook.Fire(&logrus.Entry{Message: "hello tls", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-messages; message != "hello tls" {
+		t.Errorf("expected message to be '%s' but got '%s'", "hello tls", message)
+	}
+}
+
+func TestTLSUntrustedCertificate(t *testing.T) {
+	listener, _ := newTLSListener(t)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// The certificate of httptest isn't signed by the system roots.
+	if _, err := NewHook("tls", listener.Addr().String(), "tls_test", WithDialTimeout(5*time.Second)); err == nil {
+		t.Error("expected the handshake with an untrusted certificate to fail")
+	}
+}
@@ -0,0 +1,139 @@
+package logrustash
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Transport delivers the formatted messages to logstash instead of the connection of the hook,
+// e.g. a custom delivery mechanism or a fake in tests (see logrustashtest.Transport).
+// The hook uses the same retries, buffer, fallback and flushing for a transport as for a connection:
+// the connection to `protocol`://`address` (or the one the hook is created with) is the default transport.
+type Transport interface {
+	// Send delivers a single message (framed as set by WithFraming, i.e. with the trailing
+	// newline by default) before ctx is done. ctx has the deadline set by Timeout, if any.
+	// Errors wrapped with Retryable (or any net.Error which is temporary or a timeout)
+	// make the hook send the message again up to MaxSendRetries times.
+	Send(ctx context.Context, data []byte) error
+	// Close is called by Close of the hook.
+	Close() error
+}
+
+// transportFlusher is implemented by a Transport which buffers the messages.
+// Flush is called by Flush of the hook once the hook has sent all the messages to the transport.
+type transportFlusher interface {
+	Flush(ctx context.Context) error
+}
+
+// NewHookWithTransport creates a new hook which sends the formatted messages with t.
+func NewHookWithTransport(t Transport, appName string, opts ...Option) (*Hook, error) {
+	hook := newHook(nil, appName, make(logrus.Fields), "", append([]Option{WithTransport(t)}, opts...))
+	if err := hook.startWAL(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// NewAsyncHookWithTransport creates a new hook which sends the formatted messages with t.
+// Logs will be sent asynchronously.
+func NewAsyncHookWithTransport(t Transport, appName string, opts ...Option) (*Hook, error) {
+	hook, err := NewHookWithTransport(t, appName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hook.AsyncBufferSize = 8192
+	hook.makeAsync()
+
+	return hook, nil
+}
+
+// WithTransport makes the hook send the messages with t instead of a connection, so the constructors
+// which take a protocol and an address don't dial and the ones which take a connection don't use it.
+func WithTransport(t Transport) Option {
+	return func(h *Hook) {
+		h.transport = t
+	}
+}
+
+// Retryable marks err returned by Transport.Send as temporary, so the message is sent again.
+func Retryable(err error) error {
+	return retryableError{err}
+}
+
+// streamTransport is implemented by the transports which write the messages to a byte stream
+// (see connTransport), so a message which has been written partially is resent from where
+// the write stopped and several messages may be sent at once (see FireBatch).
+type streamTransport interface {
+	Transport
+	// sendFrom sends data starting from the offset written and returns the new offset.
+	sendFrom(ctx context.Context, data []byte, written int) (int, error)
+	// isStream reports whether the messages are currently written to a byte stream.
+	isStream() bool
+}
+
+// sender returns the transport which sends the messages of the hook: the one set by WithTransport,
+// the one of the "http" and "https" protocols or the connection of the hook otherwise.
+func (h *Hook) sender() Transport {
+	if h.transport != nil {
+		return h.transport
+	}
+	if protocol, _ := h.target(); isHTTP(protocol) {
+		return httpTransport{h}
+	}
+	return connTransport{h}
+}
+
+// connectedSender returns the transport of the hook, connecting if the connection of the hook
+// has never been established. It returns nil without an error for a filteringHook.
+func (h *Hook) connectedSender() (Transport, error) {
+	transport := h.sender()
+	if conn, ok := transport.(connTransport); ok {
+		if ok, err := conn.connect(); !ok {
+			return nil, err
+		}
+	}
+	return transport, nil
+}
+
+// send sends data starting from the offset written with transport within timeout (if positive)
+// and returns the new offset. The transports which aren't streams send the whole data again.
+func (h *Hook) send(ctx context.Context, transport Transport, data []byte, written int, timeout time.Duration) (int, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if stream, ok := transport.(streamTransport); ok {
+		return stream.sendFrom(ctx, data, written)
+	}
+	if err := transport.Send(ctx, data); err != nil {
+		return written, err
+	}
+	return len(data), nil
+}
+
+// entryTimeKey is the context key of the time of the entry which a message is formatted from.
+type entryTimeKey struct{}
+
+// withEntryTime returns ctx for sending a message formatted from an entry fired at t.
+func withEntryTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, entryTimeKey{}, t)
+}
+
+// entryTime returns the time of the entry which the message sent with ctx is formatted from.
+// ok is false if it's unknown, e.g. for the records of the write-ahead log.
+func entryTime(ctx context.Context) (t time.Time, ok bool) {
+	t, ok = ctx.Value(entryTimeKey{}).(time.Time)
+	return t, ok && !t.IsZero()
+}
+
+// isStream reports whether transport writes the messages to a byte stream, see streamTransport.
+func isStream(transport Transport) bool {
+	stream, ok := transport.(streamTransport)
+	return ok && stream.isStream()
+}
@@ -0,0 +1,156 @@
+package logrustash
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func TestTransport(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewHookWithTransport(transport, "transport_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.MaxSendRetries = 2
+
+	fire := func(message string) error {
+		return hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}})
+	}
+
+	// Retryable errors are retried up to MaxSendRetries times.
+	transport.FailNext(Retryable(errors.New("busy")), Retryable(errors.New("busy")))
+	if err := fire("retried"); err != nil {
+		t.Fatal(err)
+	}
+	if transport.Attempts() != 3 {
+		t.Errorf("expected 3 attempts but got %d", transport.Attempts())
+	}
+
+	transport.FailNext(Retryable(errors.New("busy")), Retryable(errors.New("busy")), Retryable(errors.New("busy")))
+	if err := fire("exhausted"); err == nil {
+		t.Error("expected an error after all the retries")
+	}
+
+	// Other errors are final.
+	final := errors.New("rejected")
+	transport.FailNext(final)
+	var netErr *NetworkError
+	if err := fire("rejected"); !errors.As(err, &netErr) || !errors.Is(err, final) {
+		t.Errorf("expected a NetworkError wrapping the error of the transport but got %v", err)
+	}
+	if transport.Attempts() != 7 {
+		t.Errorf("expected 7 attempts but got %d", transport.Attempts())
+	}
+
+	if err := fire("sent"); err != nil {
+		t.Fatal(err)
+	}
+	events := transport.Events()
+	if len(events) != 2 || events[0]["message"] != "retried" || events[1]["message"] != "sent" {
+		t.Errorf("expected the messages 'retried' and 'sent' but got %v", events)
+	}
+	if events[0]["type"] != "transport_test" {
+		t.Errorf("expected type to be 'transport_test' but got '%v'", events[0]["type"])
+	}
+
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !transport.Closed() {
+		t.Error("expected the transport to be closed with the hook")
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	// The hook doesn't dial the address.
+	hook, err := NewAsyncHook("tcp", "127.0.0.1:1", "transport_test", WithTransport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	if err := hook.Fire(&logrus.Entry{Message: "hello", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(0); err != nil {
+		t.Fatal(err)
+	}
+	if events := transport.Events(); len(events) != 1 || events[0]["message"] != "hello" {
+		t.Errorf("expected the message 'hello' but got %v", events)
+	}
+	if err := hook.Reset(); err != nil {
+		t.Errorf("expected Reset to keep the transport but got %v", err)
+	}
+
+	// A broken transport isn't replaced by a connection to the address.
+	hook.MaxReconnectRetries = 1
+	transport.FailNext(&net.OpError{Op: "write", Net: "tcp", Err: errors.New("broken pipe")})
+	if err := hook.Fire(&logrus.Entry{Message: "lost", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(0); err != nil {
+		t.Fatal(err)
+	}
+	if hook.getConn() != nil || hook.ConnectionState() != StateConnected {
+		t.Errorf("expected the hook to keep using the transport but got state %s", hook.ConnectionState())
+	}
+	if n := atomic.LoadUint64(&hook.droppedByReason[DropReasonWriteFailed]); n != 1 {
+		t.Errorf("expected the message to be dropped but got %d drops", n)
+	}
+}
+
+func TestWithTransportWAL(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewHook("tcp", "127.0.0.1:1", "transport_test", WithTransport(transport), WithWAL(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	fireMessages(t, hook, "one", "two")
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	events := transport.Events()
+	if len(events) != 2 || events[0]["message"] != "one" || events[1]["message"] != "two" {
+		t.Errorf("expected the messages 'one' and 'two' but got %v", events)
+	}
+}
+
+func TestWithTransportWALRejected(t *testing.T) {
+	transport := logrustashtest.NewTransport()
+	hook, err := NewHook("tcp", "127.0.0.1:1", "transport_test", WithTransport(transport), WithWAL(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	var dropped []DropReason
+	hook.OnDropped(func(entry *logrus.Entry, reason DropReason) {
+		dropped = append(dropped, reason)
+	})
+
+	// A record the transport rejects is dropped, so the records after it are still sent.
+	transport.FailNext(errors.New("rejected"))
+	fireMessages(t, hook, "poison", "good")
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	events := transport.Events()
+	if len(events) != 1 || events[0]["message"] != "good" {
+		t.Errorf("expected the message 'good' but got %v", events)
+	}
+	if transport.Attempts() != 2 {
+		t.Errorf("expected the rejected record not to be retried but got %d attempts", transport.Attempts())
+	}
+	if hook.DroppedCount() != 1 || len(dropped) != 1 || dropped[0] != DropReasonWriteFailed {
+		t.Errorf("expected the rejected record to be dropped with reason write_failed but got %d drops: %v", hook.DroppedCount(), dropped)
+	}
+}
@@ -0,0 +1,105 @@
+package logrustash
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxDatagramSize is the maximum size of a UDP message used if MaxDatagramSize is not set.
+// It is the largest payload of an IPv4 UDP datagram.
+const DefaultMaxDatagramSize = 65507
+
+// DefaultUDPMaxPacketSize is the UDPMaxPacketSize used if it is not set:
+// the Ethernet MTU (1500 bytes) without the IPv4 (20 bytes) and UDP (8 bytes) headers.
+const DefaultUDPMaxPacketSize = 1472
+
+// truncationMarker is appended to the messages truncated by WithUDPMessageTruncation.
+const truncationMarker = "…"
+
+// ErrMessageTooLong is returned when a message doesn't fit into a single datagram.
+// Such messages are dropped, so they don't block the messages after them.
+var ErrMessageTooLong = errors.New("Message is too long for a datagram")
+
+// maxDatagramSize returns the maximum size of a message which can be sent with transport
+// or 0 if it doesn't send the messages over a datagram connection.
+func (h *Hook) maxDatagramSize(transport Transport) int {
+	if _, ok := transport.(connTransport); !ok {
+		return 0
+	}
+	conn := h.getConn()
+	if conn == nil || conn.LocalAddr() == nil || !strings.HasPrefix(conn.LocalAddr().Network(), "udp") {
+		return 0
+	}
+	if h.MaxDatagramSize > 0 {
+		return h.MaxDatagramSize
+	}
+
+	return DefaultMaxDatagramSize
+}
+
+// WithUDPMessageTruncation makes the hook truncate the message of an entry
+// which doesn't fit into UDPMaxPacketSize, so the datagram isn't fragmented.
+// The truncated message ends with "…". Without this option such entries are sent as is with a warning.
+func WithUDPMessageTruncation() Option {
+	return func(h *Hook) {
+		h.truncateUDPMessages = true
+	}
+}
+
+func (h *Hook) udpMaxPacketSize() int {
+	if h.UDPMaxPacketSize != 0 {
+		return h.UDPMaxPacketSize
+	}
+
+	return DefaultUDPMaxPacketSize
+}
+
+// checkPacketSize warns (once) if data, the formatted entry, is sent over UDP and is larger than
+// UDPMaxPacketSize, so it would be fragmented or dropped by the network. With WithUDPMessageTruncation
+// it returns entry formatted with its message truncated to fit instead, if possible.
+func (h *Hook) checkPacketSize(transport Transport, entry *logrus.Entry, data []byte) []byte {
+	max := h.udpMaxPacketSize()
+	if max <= 0 || len(data) <= max || h.maxDatagramSize(transport) == 0 {
+		return data
+	}
+
+	if _, raw := rawMessage(entry); h.truncateUDPMessages && !raw {
+		// Each byte of the message takes at least a byte in JSON.
+		excess := len(data) - max + len(truncationMarker)
+		if message := entry.Message; excess < len(message) {
+			entry.Message = strings.ToValidUTF8(message[:len(message)-excess], "") + truncationMarker
+			if truncated, err := h.formatEntry(entry); err == nil && len(truncated) <= max {
+				return truncated
+			}
+			entry.Message = message
+		}
+	}
+
+	h.warnOnce("udp_max_packet_size", "the message to logstash is %d bytes, which is more than UDPMaxPacketSize (%d bytes), "+
+		"so it may be fragmented; the other messages larger than UDPMaxPacketSize aren't reported", len(data), max)
+	return data
+}
+
+// checkDatagramSize returns an error and counts the message as oversized
+// if data doesn't fit into a single datagram of transport.
+func (h *Hook) checkDatagramSize(transport Transport, data []byte) error {
+	if max := h.maxDatagramSize(transport); max > 0 && len(data) > max {
+		atomic.AddUint64(&h.oversizedCount, 1)
+		return &MessageTooLargeError{Size: len(data), Limit: max}
+	}
+
+	return nil
+}
+
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
+
+// OversizedCount returns how many messages have been dropped because they didn't fit into a single datagram.
+func (h *Hook) OversizedCount() uint64 {
+	return atomic.LoadUint64(&h.oversizedCount)
+}
@@ -0,0 +1,152 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// udpConnMock is a ConnMock with a UDP address.
+type udpConnMock struct {
+	ConnMock
+}
+
+func (udpConnMock) LocalAddr() net.Addr {
+	return &net.UDPAddr{}
+}
+
+func TestOversizedDatagram(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hook, err := NewHook("udp", listener.LocalAddr().String(), "udp_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	hook.MaxReconnectRetries = 3
+
+	huge := strings.Repeat("x", 70000)
+	fire := func(message string) error {
+		return hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{"payload": huge}})
+	}
+
+	// Rejected by the check of the size.
+	if err := fire("huge"); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("expected error to be '%v' but got '%v'", ErrMessageTooLong, err)
+	}
+	// Rejected by the OS.
+	hook.MaxDatagramSize = 1 << 20
+	if err := fire("huge"); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("expected error to be '%v' but got '%v'", ErrMessageTooLong, err)
+	}
+	if hook.OversizedCount() != 2 {
+		t.Errorf("expected 2 oversized messages but got %d", hook.OversizedCount())
+	}
+
+	huge = ""
+	if err := fire("small"); err != nil {
+		t.Error(err)
+	}
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, DefaultMaxDatagramSize)
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]string
+	if err := json.Unmarshal(buf[:n], &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "small" {
+		t.Errorf("expected message to be '%s' but got '%s'", "small", res["message"])
+	}
+}
+
+func TestUDPMessageTruncation(t *testing.T) {
+	for _, truncate := range []bool{false, true} {
+		conn := udpConnMock{ConnMock{buff: bytes.NewBufferString("")}}
+		var opts []Option
+		if truncate {
+			opts = append(opts, WithUDPMessageTruncation())
+		}
+		hook, err := NewHookWithConn(conn, "udp_test", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := strings.Repeat("ü", DefaultUDPMaxPacketSize)
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+
+		data := conn.buff.Bytes()
+		var res map[string]string
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatal(err)
+		}
+		if !truncate {
+			if res["message"] != message {
+				t.Error("expected the message to be sent as is")
+			}
+			continue
+		}
+		if len(data) > DefaultUDPMaxPacketSize || len(data) < DefaultUDPMaxPacketSize-8 {
+			t.Errorf("expected the message to be truncated to fit into %d bytes but got %d bytes", DefaultUDPMaxPacketSize, len(data))
+		}
+		if !strings.HasSuffix(res["message"], "ü…") {
+			t.Errorf("expected the truncated message to end with '…' but got '%s'", res["message"])
+		}
+	}
+}
+
+// captureStdout returns what fn prints to the standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestUDPMaxPacketSizeWarning(t *testing.T) {
+	conn := udpConnMock{ConnMock{buff: bytes.NewBufferString("")}}
+	hook, err := NewHookWithConn(conn, "udp_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() {
+		for i := 0; i < 3; i++ {
+			if err := hook.Fire(&logrus.Entry{Message: strings.Repeat("x", DefaultUDPMaxPacketSize), Data: logrus.Fields{}}); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	// The warning is printed for the first message only.
+	if n := strings.Count(output, "Warning: "); n != 1 {
+		t.Errorf("expected a single warning but got %q", output)
+	}
+}
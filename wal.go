@@ -0,0 +1,638 @@
+package logrustash
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWALSegmentSize = 64 << 20
+	defaultWALMaxSize     = 1 << 30
+
+	walSegmentSuffix   = ".wal"
+	walOffsetFile      = "offset"
+	walRecordHeaderLen = 8 // the length and the CRC-32 of the payload
+	walPersistInterval = time.Second
+)
+
+// ErrWALFull is returned by Fire when the write-ahead log has reached its maximum size
+// (see WithWALMaxSize), so the entry can't be written to it.
+var ErrWALFull = errors.New("Write-ahead log of the hook is full")
+
+// writeAheadLog stores the formatted messages on the disk until they are sent to logstash.
+//
+// The log is a sequence of records in segment files named by the offset of their first record
+// in the whole log. A record is the length and the CRC-32 of the message, followed by the message.
+// The offset after the last sent record is persisted in the file "offset" once in a while,
+// so at most the records sent during the last second are sent again after a crash.
+type writeAheadLog struct {
+	sync.Mutex   // protects the fields below
+	dir          string
+	syncInterval time.Duration // fsync after each record if zero, never if negative
+	segmentSize  int64
+	maxSize      int64
+	segments     []int64  // offsets of the first records of the segments, the active segment last
+	file         *os.File // the active segment, nil if the log is closed
+	end          int64    // offset after the last record
+	acked        int64    // offset after the last sent record
+	persisted    int64    // acked as of the last time it was persisted
+	replayEnd    int64    // end when the log was opened: the records before it are replayed
+	pending      int      // records appended since the log was opened and not sent yet
+	dirty        bool     // whether the active segment has records which haven't been synced
+	notify       chan struct{}
+
+	running sync.Mutex // held by the running loop, so Close can wait for it and a loop restarted by Reset waits for the previous one
+}
+
+// WithWAL makes the hook write every entry to a write-ahead log in dir before sending it,
+// for at-least-once delivery across the restarts of the process: Fire appends the formatted
+// entry to the log and a background sender sends the records to logstash in order,
+// retrying each of them until it is sent. A record which can never be sent (e.g. it doesn't fit
+// into a datagram or the transport rejects it with an error which isn't a net.Error) is dropped
+// instead, so it doesn't hold up the records after it; OnDropped gets a nil entry for it. The records which haven't been sent before the hook
+// was closed or the process crashed are sent by the next hook with the same dir before the new ones,
+// so some records may be sent twice. Only one hook may use dir at a time.
+// The buffer of the async mode isn't used, and Flush waits until the records fired
+// by this hook are sent. WithWAL has no effect on filter hooks.
+func WithWAL(dir string) Option {
+	return func(h *Hook) {
+		h.writeAheadLog().dir = dir
+	}
+}
+
+// WithWALSyncInterval sets how often the records written to the log set by WithWAL are synced
+// to the disk: zero (the default) syncs each record before Fire returns, a negative interval
+// leaves syncing to the OS.
+func WithWALSyncInterval(interval time.Duration) Option {
+	return func(h *Hook) {
+		h.writeAheadLog().syncInterval = interval
+	}
+}
+
+// WithWALSegmentSize sets the size of the segment files of the log set by WithWAL
+// after which a new segment is started. Segments are removed once all their records are sent.
+// Default: 64 MiB.
+func WithWALSegmentSize(size int64) Option {
+	return func(h *Hook) {
+		h.writeAheadLog().segmentSize = size
+	}
+}
+
+// WithWALMaxSize sets the maximum size of the log set by WithWAL on the disk. When it is reached,
+// Fire drops the new entries with ErrWALFull until the records are sent. Zero means no limit.
+// Default: 1 GiB.
+func WithWALMaxSize(size int64) Option {
+	return func(h *Hook) {
+		h.writeAheadLog().maxSize = size
+	}
+}
+
+func (h *Hook) writeAheadLog() *writeAheadLog {
+	if h.wal == nil {
+		h.wal = &writeAheadLog{
+			segmentSize: defaultWALSegmentSize,
+			maxSize:     defaultWALMaxSize,
+			notify:      make(chan struct{}, 1),
+		}
+	}
+
+	return h.wal
+}
+
+// startWAL opens the write-ahead log, if any, and starts sending its records.
+func (h *Hook) startWAL() error {
+	if h.wal == nil {
+		return nil
+	}
+	if err := h.wal.open(); err != nil {
+		return fmt.Errorf("Couldn't open the write-ahead log: %w", err)
+	}
+	go h.wal.loop(h, h.closeChan)
+
+	return nil
+}
+
+// appendWAL formats entry and appends it to the write-ahead log.
+// entry must not be shared with anyone else (see copyEntry).
+func (h *Hook) appendWAL(entry *logrus.Entry) error {
+	return h.processEntry(entry, h.formatAndAppendWAL)
+}
+
+// formatAndAppendWAL formats entry, which has passed the middlewares, and appends it to the write-ahead log.
+func (h *Hook) formatAndAppendWAL(entry *logrus.Entry) error {
+	data, err := h.formatMessage(entry)
+	if err != nil {
+		h.reportError(err, OperationFormat)
+		return err
+	}
+
+	h.inFlight.add()
+	if err := h.wal.append(data); err != nil {
+		h.inFlight.done()
+		if err == ErrWALFull {
+			h.drop(entry, DropReasonWALFull)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// sendWALRecord sends data, a record of the write-ahead log, with the transport of the hook.
+// It returns true if the record is done with: it has been sent, or it can never be sent
+// (it is too large or the transport has rejected it), so it is dropped rather than retried forever.
+func (h *Hook) sendWALRecord(data []byte) (bool, error) {
+	transport, err := h.connectedSender()
+	if err != nil {
+		return false, err
+	}
+	if transport == nil {
+		return false, ErrNotConnected
+	}
+
+	written := 0
+	for sendRetries := 0; ; sendRetries++ {
+		n, err := h.send(context.Background(), transport, data, written, h.Timeout)
+		if err == nil {
+			h.statsd.count("sent", 1)
+			h.health.success(h.clock())
+			return true, nil
+		}
+		written = n
+		h.reportError(err, OperationWrite)
+		if isMessageTooLong(err) {
+			// Neither resending nor reconnecting help, see processSendError.
+			atomic.AddUint64(&h.oversizedCount, 1)
+			h.drop(nil, DropReasonOversized)
+			return true, &MessageTooLargeError{Size: len(data), Err: err}
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) {
+			h.drop(nil, DropReasonWriteFailed)
+			return true, &DroppedError{Reason: "rejected", Err: err}
+		}
+		if h.isNeedToResendMessage(netErr, sendRetries) {
+			continue
+		}
+		if conn, ok := transport.(connTransport); ok {
+			// The rest of the record can't follow its beginning over a new connection,
+			// so the whole record is sent again. If reconnecting fails, the next attempt retries it.
+			conn.reconnect(0)
+		}
+		return false, err
+	}
+}
+
+func walSegmentPath(dir string, start int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", start, walSegmentSuffix))
+}
+
+// open reads the state of the log from its directory, creating it if needed,
+// and truncates the active segment after its last valid record.
+func (w *writeAheadLog) open() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return err
+	}
+	names, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	w.segments = w.segments[:0]
+	for _, name := range names {
+		if !strings.HasSuffix(name.Name(), walSegmentSuffix) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(name.Name(), walSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		w.segments = append(w.segments, start)
+	}
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i] < w.segments[j] })
+
+	w.acked = 0
+	if data, err := os.ReadFile(filepath.Join(w.dir, walOffsetFile)); err == nil {
+		if w.acked, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return fmt.Errorf("invalid offset file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if len(w.segments) == 0 {
+		w.segments = append(w.segments, w.acked)
+	}
+
+	active := w.segments[len(w.segments)-1]
+	path := walSegmentPath(w.dir, active)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	valid, err := validWALSize(file)
+	if err == nil {
+		// Remove the tail of a record which has been written partially before a crash.
+		err = file.Truncate(valid)
+	}
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.end = active + valid
+	if w.acked < w.segments[0] {
+		// The segments with the records sent before have been removed.
+		w.acked = w.segments[0]
+	}
+	if w.acked > w.end {
+		w.acked = w.end
+	}
+	w.persisted = w.acked
+	w.replayEnd = w.end
+	w.pending = 0
+	w.dirty = false
+
+	return nil
+}
+
+// validWALSize returns the size of the valid records at the beginning of a segment.
+func validWALSize(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int64
+	for {
+		_, size, err := readWALRecord(file, offset, info.Size())
+		if err == io.EOF || err == errCorruptWALRecord {
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	}
+}
+
+var errCorruptWALRecord = errors.New("corrupt record")
+
+// readWALRecord reads the record at offset in a segment with limit bytes of records
+// and returns its message and size. It returns io.EOF if there is no record at offset
+// and errCorruptWALRecord if the record is invalid.
+func readWALRecord(r io.ReaderAt, offset, limit int64) ([]byte, int64, error) {
+	if offset >= limit {
+		return nil, 0, io.EOF
+	}
+	if offset+walRecordHeaderLen > limit {
+		return nil, 0, errCorruptWALRecord
+	}
+	var header [walRecordHeaderLen]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return nil, 0, err
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if offset+walRecordHeaderLen+length > limit {
+		return nil, 0, errCorruptWALRecord
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset+walRecordHeaderLen); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, errCorruptWALRecord
+	}
+
+	return data, walRecordHeaderLen + length, nil
+}
+
+// append writes a record with data to the active segment.
+func (w *writeAheadLog) append(data []byte) error {
+	record := make([]byte, walRecordHeaderLen+len(data))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:walRecordHeaderLen], crc32.ChecksumIEEE(data))
+	copy(record[walRecordHeaderLen:], data)
+	size := int64(len(record))
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return ErrHookClosed
+	}
+	if w.maxSize > 0 && w.end-w.segments[0]+size > w.maxSize {
+		w.removeSentSegments()
+		if w.end-w.segments[0]+size > w.maxSize {
+			return ErrWALFull
+		}
+	}
+	if active := w.segments[len(w.segments)-1]; w.end > active && w.end-active+size > w.segmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.file.Write(record); err != nil {
+		// Don't leave a partial record before the next one.
+		w.file.Truncate(w.end - w.segments[len(w.segments)-1])
+		w.file.Seek(0, io.SeekEnd)
+		return err
+	}
+	w.end += size
+	w.pending++
+	if w.syncInterval == 0 {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+	} else {
+		w.dirty = true
+	}
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rotate starts a new active segment.
+func (w *writeAheadLog) rotate() error {
+	file, err := os.OpenFile(walSegmentPath(w.dir, w.end), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if w.syncInterval >= 0 {
+		w.file.Sync()
+	}
+	w.file.Close()
+	w.file = file
+	w.dirty = false
+	w.segments = append(w.segments, w.end)
+	return nil
+}
+
+// removeSentSegments removes the segments, except the active one, which have been sent completely.
+func (w *writeAheadLog) removeSentSegments() {
+	for len(w.segments) > 1 && w.segments[1] <= w.acked {
+		if err := os.Remove(walSegmentPath(w.dir, w.segments[0])); err != nil && !os.IsNotExist(err) {
+			fmt.Println("Error during removing a segment of the write-ahead log:", err)
+			return
+		}
+		w.segments = w.segments[1:]
+	}
+}
+
+// next returns the record at offset and the offset of the next record,
+// or nil if there are no more records. segment is the segment read before
+// and is replaced if the record is in another one.
+func (w *writeAheadLog) next(offset int64, segment *walSegmentReader) ([]byte, int64, error) {
+	for {
+		w.Lock()
+		if offset < w.segments[0] {
+			offset = w.segments[0]
+		}
+		end := w.end
+		i := sort.Search(len(w.segments), func(i int) bool { return w.segments[i] > offset }) - 1
+		start, segmentEnd := w.segments[i], end
+		if i+1 < len(w.segments) {
+			segmentEnd = w.segments[i+1]
+		}
+		w.Unlock()
+		if offset >= end {
+			return nil, offset, nil
+		}
+
+		if err := segment.open(w.dir, start); err != nil {
+			return nil, offset, err
+		}
+		data, size, err := readWALRecord(segment.file, offset-start, segmentEnd-start)
+		if (err == io.EOF || err == errCorruptWALRecord) && segmentEnd < end {
+			if err == errCorruptWALRecord {
+				fmt.Printf("Error during reading the write-ahead log: skipping the corrupt rest of the segment from offset %d\n", offset)
+			}
+			offset = segmentEnd
+			continue
+		}
+		if err != nil {
+			return nil, offset, err
+		}
+
+		return data, offset + size, nil
+	}
+}
+
+// walSegmentReader is the file of a segment opened for reading.
+type walSegmentReader struct {
+	start int64
+	file  *os.File
+}
+
+func (r *walSegmentReader) open(dir string, start int64) error {
+	if r.file != nil && r.start == start {
+		return nil
+	}
+	r.close()
+	file, err := os.Open(walSegmentPath(dir, start))
+	if err != nil {
+		return err
+	}
+	r.start = start
+	r.file = file
+	return nil
+}
+
+func (r *walSegmentReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// ack marks the records before offset as sent and reports
+// if the last of them has been appended by this hook.
+func (w *writeAheadLog) ack(recordOffset, offset int64) (appended bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	w.acked = offset
+	if recordOffset >= w.replayEnd && w.pending > 0 {
+		w.pending--
+		return true
+	}
+	return false
+}
+
+// maintain syncs the active segment, persists the offset of the sent records
+// and removes the segments which have been sent, if needed.
+func (w *writeAheadLog) maintain() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	if w.dirty && w.syncInterval >= 0 {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+		w.dirty = false
+	}
+	if w.acked == w.persisted {
+		return nil
+	}
+
+	path := filepath.Join(w.dir, walOffsetFile)
+	if err := os.WriteFile(path+".tmp", []byte(strconv.FormatInt(w.acked, 10)), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	w.persisted = w.acked
+	w.removeSentSegments()
+	return nil
+}
+
+// close persists the state of the log and closes it.
+// It returns how many records appended by this hook haven't been sent.
+func (w *writeAheadLog) close() (pending int, err error) {
+	err = w.maintain()
+
+	w.Lock()
+	defer w.Unlock()
+	if w.file != nil {
+		if closeErr := w.file.Close(); err == nil {
+			err = closeErr
+		}
+		w.file = nil
+	}
+	pending = w.pending
+	w.pending = 0
+	return pending, err
+}
+
+// loop sends the records of the log in order until closeChan is closed.
+func (w *writeAheadLog) loop(h *Hook, closeChan <-chan struct{}) {
+	w.running.Lock()
+	defer w.running.Unlock()
+
+	interval := walPersistInterval
+	if w.syncInterval > 0 && w.syncInterval < interval {
+		interval = w.syncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var segment walSegmentReader
+	defer segment.close()
+	defer func() {
+		pending, err := w.close()
+		if err != nil {
+			fmt.Println("Error during closing the write-ahead log:", err)
+		}
+		// The records left in the log aren't in flight anymore, they are sent by the next hook.
+		for ; pending > 0; pending-- {
+			h.inFlight.done()
+		}
+	}()
+
+	w.Lock()
+	offset := w.acked
+	w.Unlock()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.maintain(); err != nil {
+				fmt.Println("Error during syncing the write-ahead log:", err)
+			}
+		case <-closeChan:
+			return
+		default:
+		}
+		if paused, changed := h.pause.state(); paused {
+			// The records wait in the log while the hook is paused.
+			select {
+			case <-changed:
+			case <-ticker.C:
+				if err := w.maintain(); err != nil {
+					fmt.Println("Error during syncing the write-ahead log:", err)
+				}
+			case <-closeChan:
+				return
+			}
+			continue
+		}
+
+		data, next, err := w.next(offset, &segment)
+		if err != nil {
+			fmt.Println("Error during reading the write-ahead log:", err)
+		}
+		if data == nil {
+			offset = next
+			select {
+			case <-w.notify:
+			case <-ticker.C:
+				if err := w.maintain(); err != nil {
+					fmt.Println("Error during syncing the write-ahead log:", err)
+				}
+			case <-closeChan:
+				return
+			}
+			continue
+		}
+
+		done, err := h.sendWALRecord(data)
+		if err != nil {
+			fmt.Println("Error during sending message to logstash:", err)
+		}
+		if !done {
+			if !h.waitReconnectDelay(closeChan) {
+				return
+			}
+			continue
+		}
+		if w.ack(next-int64(len(data))-walRecordHeaderLen, next) {
+			h.inFlight.done()
+		}
+		offset = next
+	}
+}
+
+// waitReconnectDelay waits before the next attempt to send a record of the write-ahead log.
+// It returns false if closeChan is closed while waiting.
+func (h *Hook) waitReconnectDelay(closeChan <-chan struct{}) bool {
+	delay := h.ReconnectBaseDelay
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-closeChan:
+		return false
+	}
+}
@@ -0,0 +1,236 @@
+package logrustash
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/xaionaro-go/logrustash/logrustashtest"
+)
+
+func fireMessages(t *testing.T, hook *Hook, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkEventMessages(t *testing.T, server *logrustashtest.Server, messages ...string) {
+	t.Helper()
+	events, err := server.WaitForEvents(len(messages), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, message := range messages {
+		if events[i]["message"] != message {
+			t.Errorf("expected message %d to be '%s' but got '%v'", i, message, events[i]["message"])
+		}
+	}
+}
+
+func walSegments(t *testing.T, dir string) []string {
+	t.Helper()
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+walSegmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return segments
+}
+
+func TestWAL(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	dir := t.TempDir()
+	hook, err := NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireMessages(t, hook, "one", "two", "three")
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkEventMessages(t, server, "one", "two", "three")
+
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	offset, err := os.ReadFile(filepath.Join(dir, walOffsetFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(offset) != fmt.Sprint(hook.wal.end) {
+		t.Errorf("expected the offset %d to be persisted but got '%s'", hook.wal.end, offset)
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	server.Stop()
+	dir := t.TempDir()
+
+	// Logstash is down, so the entries stay in the log.
+	hook, err := NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireMessages(t, hook, "one", "two")
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	hook, err = NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	fireMessages(t, hook, "three")
+	checkEventMessages(t, server, "one", "two", "three")
+}
+
+func TestWALCorruptTail(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	server.Stop()
+	dir := t.TempDir()
+
+	hook, err := NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireMessages(t, hook, "one", "two")
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A record which has been written partially.
+	segment := walSegments(t, dir)[0]
+	info, err := os.Stat(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 1, 2, 3, 4, '{', '"'})
+	f.Close()
+
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	hook, err = NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	fireMessages(t, hook, "three")
+	checkEventMessages(t, server, "one", "two", "three")
+	if events := server.Events(); len(events) != 3 {
+		t.Errorf("expected 3 events but got %v", events)
+	}
+	if hook.wal.replayEnd != info.Size() {
+		t.Errorf("expected the log to be truncated to %d bytes but got %d", info.Size(), hook.wal.replayEnd)
+	}
+}
+
+func TestWALSegments(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	server.Stop()
+	dir := t.TempDir()
+
+	hook, err := NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir), WithWALSegmentSize(300), WithWALMaxSize(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fired []string
+	for i := 0; ; i++ {
+		message := fmt.Sprintf("message %d", i)
+		err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}})
+		if err == ErrWALFull {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		fired = append(fired, message)
+	}
+	if n := hook.droppedByReason[DropReasonWALFull]; n != 1 {
+		t.Errorf("expected 1 entry dropped because the log is full but got %d", n)
+	}
+	if segments := walSegments(t, dir); len(segments) < 3 {
+		t.Errorf("expected several segments but got %v", segments)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkEventMessages(t, server, fired...)
+	if err := hook.wal.maintain(); err != nil {
+		t.Fatal(err)
+	}
+	if segments := walSegments(t, dir); len(segments) != 1 {
+		t.Errorf("expected the sent segments to be removed but got %v", segments)
+	}
+	hook.Close()
+}
+
+// TestWALCrashHelper is the process killed by TestWALCrashRecovery.
+func TestWALCrashHelper(t *testing.T) {
+	dir, addr := os.Getenv("LOGRUSTASH_WAL_DIR"), os.Getenv("LOGRUSTASH_WAL_ADDR")
+	if dir == "" {
+		t.Skip("run by TestWALCrashRecovery")
+	}
+
+	hook, err := NewHook("tcp", addr, "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireMessages(t, hook, "one", "two", "three")
+	fmt.Println("fired")
+	select {}
+}
+
+func TestWALCrashRecovery(t *testing.T) {
+	server := logrustashtest.NewServer(t, "tcp")
+	server.Stop()
+	dir := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestWALCrashHelper$")
+	cmd.Env = append(os.Environ(), "LOGRUSTASH_WAL_DIR="+dir, "LOGRUSTASH_WAL_ADDR="+server.Addr())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && !strings.Contains(scanner.Text(), "fired") {
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := NewHook("tcp", server.Addr(), "wal_test", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	fireMessages(t, hook, "four")
+	checkEventMessages(t, server, "one", "two", "three", "four")
+}
@@ -0,0 +1,111 @@
+package logrustash
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewHookWithWriter creates a new hook which writes the formatted messages to w
+// (e.g. a file tailed by Filebeat) instead of sending them over the network.
+// Failed writes are retried up to MaxSendRetries times, then the message is dropped
+// and Fire returns a *DroppedError. Close doesn't close w unless WithWriterClose is used.
+func NewHookWithWriter(w io.Writer, appName string, opts ...Option) (*Hook, error) {
+	hook := newHook(nil, appName, make(logrus.Fields), "", opts)
+	hook.setConn(&writerConn{w: w, close: hook.closeWriter})
+	hook.setState(StateConnected)
+	if err := hook.startWAL(); err != nil {
+		hook.Close()
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// NewAsyncHookWithWriter creates a new hook which writes the formatted messages to w.
+// Logs will be written asynchronously.
+func NewAsyncHookWithWriter(w io.Writer, appName string, opts ...Option) (*Hook, error) {
+	hook, err := NewHookWithWriter(w, appName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hook.AsyncBufferSize = 8192
+	hook.makeAsync()
+
+	return hook, nil
+}
+
+// WithWriterClose makes Close of a hook created with NewHookWithWriter close the writer
+// if it implements io.Closer. By default the writer is left open, since the hook doesn't own it.
+func WithWriterClose(enabled bool) Option {
+	return func(h *Hook) {
+		h.closeWriter = enabled
+	}
+}
+
+// writerError marks a failed write to the writer of the hook as temporary,
+// so the message is written again.
+type writerError struct {
+	error
+}
+
+func (e writerError) Timeout() bool   { return false }
+func (e writerError) Temporary() bool { return true }
+func (e writerError) Unwrap() error   { return e.error }
+
+// writerConn is a net.Conn which writes to an io.Writer.
+type writerConn struct {
+	w     io.Writer
+	close bool
+}
+
+type writerAddr struct{}
+
+func (writerAddr) Network() string {
+	return "writer"
+}
+
+func (writerAddr) String() string {
+	return "writer"
+}
+
+func (c *writerConn) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	if err != nil {
+		return n, writerError{err}
+	}
+	return n, nil
+}
+
+func (c *writerConn) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (c *writerConn) Close() error {
+	if closer, ok := c.w.(io.Closer); ok && c.close {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *writerConn) LocalAddr() net.Addr {
+	return writerAddr{}
+}
+
+func (c *writerConn) RemoteAddr() net.Addr {
+	return writerAddr{}
+}
+
+func (c *writerConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *writerConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *writerConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
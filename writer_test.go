@@ -0,0 +1,102 @@
+package logrustash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// flakyWriter fails every other write.
+type flakyWriter struct {
+	bytes.Buffer
+	writes int
+	closed bool
+}
+
+func (w *flakyWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if w.writes%2 == 1 {
+		return 0, errors.New("disk quota exceeded")
+	}
+	return w.Buffer.Write(b)
+}
+
+func (w *flakyWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	buff := &bytes.Buffer{}
+	hook, err := NewHookWithWriter(buff, "writer_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first", "second"} {
+		if err := hook.Fire(&logrus.Entry{Message: message, Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := json.NewDecoder(buff)
+	for _, expected := range []string{"first", "second"} {
+		var res map[string]string
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res["message"] != expected || res["type"] != "writer_test" {
+			t.Errorf("expected message '%s' of type 'writer_test' but got '%s' of type '%s'", expected, res["message"], res["type"])
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := &flakyWriter{}
+	hook, err := NewHookWithWriter(w, "writer_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hook.Fire(&logrus.Entry{Message: "dropped", Data: logrus.Fields{}})
+	var dropped *DroppedError
+	if !errors.As(err, &dropped) {
+		t.Errorf("expected *DroppedError but got %#v", err)
+	}
+	if hook.DroppedCount() != 1 {
+		t.Errorf("expected 1 dropped entry but got %d", hook.DroppedCount())
+	}
+
+	hook.MaxSendRetries = 1
+	if err := hook.Fire(&logrus.Entry{Message: "retried", Data: logrus.Fields{}}); err != nil {
+		t.Error(err)
+	}
+	var res map[string]string
+	if err := json.NewDecoder(&w.Buffer).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res["message"] != "retried" {
+		t.Errorf("expected message to be 'retried' but got '%s'", res["message"])
+	}
+
+	hook.Close()
+	if w.closed {
+		t.Error("expected the writer to be left open")
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	w := &flakyWriter{}
+	hook, err := NewAsyncHookWithWriter(w, "writer_test", WithWriterClose(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.closed {
+		t.Error("expected the writer to be closed")
+	}
+}